@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Config - nsctl config file
+//
+//	currentProfile: lab
+//	profiles:
+//	  lab:
+//	    address: https://10.3.199.254:8443
+//	    username: admin
+//	    passwordEnv: NS_LAB_PASSWORD
+//	  cluster:
+//	    address: https://10.3.199.252:8443,https://10.3.199.253:8443
+//	    username: admin
+//	    password: Nexenta@1
+//	    insecureSkipVerify: true
+type Config struct {
+	CurrentProfile string             `yaml:"currentProfile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile - appliance or cluster access settings, cluster nodes are listed as comma separated addresses
+type Profile struct {
+	Address  string `yaml:"address"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// environment variable to read password from, takes precedence over Password
+	PasswordEnv        string `yaml:"passwordEnv,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
+}
+
+func (profile Profile) getPassword() string {
+	if profile.PasswordEnv != "" {
+		if password := os.Getenv(profile.PasswordEnv); password != "" {
+			return password
+		}
+	}
+	return profile.Password
+}
+
+// loadConfig reads config file, missing file is treated as empty config
+func loadConfig(path string) (Config, error) {
+	config := Config{Profiles: map[string]Profile{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("Cannot read config file '%s': %s", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("Cannot parse config file '%s': %s", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+
+	return config, nil
+}
+
+func (config Config) save(path string) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	// config may contain passwords
+	return os.WriteFile(path, data, 0600)
+}
+
+// getProfile returns profile by name, current profile is returned if name is empty
+func (config Config) getProfile(name string) (Profile, error) {
+	if name == "" {
+		name = config.CurrentProfile
+	}
+	if name == "" {
+		return Profile{}, fmt.Errorf(
+			"No profile selected, use --profile, --address or 'nsctl profile use' to select NexentaStor",
+		)
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return profile, fmt.Errorf("Profile '%s' not found in config", name)
+	}
+
+	return profile, nil
+}
+
+func (config Config) profileNames() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newProfileCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage appliance profiles",
+	}
+
+	completeProfiles := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		config, err := loadConfig(a.configPath)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return config.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(a.configPath)
+			if err != nil {
+				return err
+			}
+			type profileRow struct {
+				Name    string `json:"name"`
+				Current bool   `json:"current"`
+				Address string `json:"address"`
+				User    string `json:"username"`
+			}
+			rows := [][]string{}
+			list := []profileRow{}
+			for _, name := range config.profileNames() {
+				profile := config.Profiles[name]
+				current := name == config.CurrentProfile
+				list = append(list, profileRow{name, current, profile.Address, profile.Username})
+				rows = append(rows, []string{name, formatBool(current), profile.Address, profile.Username})
+			}
+			return a.print(list, []string{"NAME", "CURRENT", "ADDRESS", "USERNAME"}, rows)
+		},
+	})
+
+	var profile Profile
+	var passwordStdin bool
+	add := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add or replace profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(a.configPath)
+			if err != nil {
+				return err
+			}
+			if profile.Address == "" {
+				return fmt.Errorf("Profile address is required")
+			}
+			if passwordStdin {
+				profile.Password, err = readPassword(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+			}
+			config.Profiles[args[0]] = profile
+			if config.CurrentProfile == "" {
+				config.CurrentProfile = args[0]
+			}
+			return config.save(a.configPath)
+		},
+	}
+	add.Flags().StringVar(&profile.Address, "address", "", "comma separated NexentaStor REST API addresses")
+	add.Flags().StringVar(&profile.Username, "username", "admin", "NexentaStor REST API username")
+	add.Flags().BoolVar(&passwordStdin, "password-stdin", false,
+		"read NexentaStor REST API password from stdin, it's stored in the config as is")
+	add.Flags().StringVar(&profile.PasswordEnv, "password-env", "", "environment variable to read password from")
+	add.Flags().BoolVar(&profile.InsecureSkipVerify, "insecure", false, "skip TLS certificate verification")
+	cmd.AddCommand(add)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "use <name>",
+		Short:             "Set current profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(a.configPath)
+			if err != nil {
+				return err
+			}
+			if _, err := config.getProfile(args[0]); err != nil {
+				return err
+			}
+			config.CurrentProfile = args[0]
+			return config.save(a.configPath)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "remove <name>",
+		Short:             "Remove profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(a.configPath)
+			if err != nil {
+				return err
+			}
+			if _, err := config.getProfile(args[0]); err != nil {
+				return err
+			}
+			delete(config.Profiles, args[0])
+			if config.CurrentProfile == args[0] {
+				config.CurrentProfile = ""
+			}
+			return config.save(a.configPath)
+		},
+	})
+
+	return cmd
+}
+
+// readPassword reads password from the first line of the reader
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("Cannot read password: %s", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("Password read from stdin is empty")
+	}
+	return password, nil
+}
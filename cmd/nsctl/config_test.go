@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("loadConfig() should return empty config if file doesn't exist", func(t *testing.T) {
+		config, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		} else if config.Profiles == nil || len(config.Profiles) != 0 {
+			t.Errorf("expected empty profiles, but got: %+v", config)
+		}
+	})
+
+	t.Run("loadConfig() should return an error for invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte("profiles: ["), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+	})
+
+	t.Run("save() should write config readable by loadConfig() and private to the user", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		config := Config{
+			CurrentProfile: "lab",
+			Profiles: map[string]Profile{
+				"lab":     {Address: "https://10.3.199.254:8443", Username: "admin", PasswordEnv: "NS_LAB_PASSWORD"},
+				"cluster": {Address: "https://a:8443,https://b:8443", Password: "pass", InsecureSkipVerify: true},
+			},
+		}
+		if err := config.save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := loadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(loaded, config) {
+			t.Errorf("expected %+v, but got %+v", config, loaded)
+		}
+		if info, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if info.Mode().Perm() != 0600 {
+			t.Errorf("expected 0600 permissions, but got %s", info.Mode().Perm())
+		}
+	})
+
+	t.Run("getProfile() should return current profile if name is not set", func(t *testing.T) {
+		config := Config{
+			CurrentProfile: "lab",
+			Profiles:       map[string]Profile{"lab": {Address: "lab"}, "prod": {Address: "prod"}},
+		}
+		if profile, err := config.getProfile(""); err != nil || profile.Address != "lab" {
+			t.Errorf("expected 'lab' profile, but got: %+v, %v", profile, err)
+		}
+		if profile, err := config.getProfile("prod"); err != nil || profile.Address != "prod" {
+			t.Errorf("expected 'prod' profile, but got: %+v, %v", profile, err)
+		}
+		if _, err := config.getProfile("unknown"); err == nil {
+			t.Error("expected an error for unknown profile, but got nil")
+		}
+		if _, err := (Config{}).getProfile(""); err == nil {
+			t.Error("expected an error if no profile is selected, but got nil")
+		}
+	})
+
+	t.Run("profile commands should manage profiles in the config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		run := func(args ...string) {
+			t.Helper()
+			cmd := newRootCommand(&app{out: &bytes.Buffer{}})
+			cmd.SetArgs(append([]string{"--config", path}, args...))
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("%v: %s", args, err)
+			}
+		}
+
+		run("profile", "add", "lab", "--address", "https://lab:8443", "--password-env", "NS_LAB_PASSWORD")
+		run("profile", "add", "prod", "--address", "https://prod:8443")
+		run("profile", "use", "prod")
+		run("profile", "remove", "lab")
+
+		config, err := loadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Config{
+			CurrentProfile: "prod",
+			Profiles:       map[string]Profile{"prod": {Address: "https://prod:8443", Username: "admin"}},
+		}
+		if !reflect.DeepEqual(config, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, config)
+		}
+	})
+	t.Run("profile add should read password from stdin", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		cmd := newRootCommand(&app{out: &bytes.Buffer{}})
+		cmd.SetIn(strings.NewReader("Nexenta@1\n"))
+		cmd.SetArgs([]string{"--config", path, "profile", "add", "lab", "--address", "https://lab:8443", "--password-stdin"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		} else if password := config.Profiles["lab"].Password; password != "Nexenta@1" {
+			t.Errorf("expected 'Nexenta@1' password, but got '%s'", password)
+		}
+
+		cmd = newRootCommand(&app{out: &bytes.Buffer{}})
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetArgs([]string{"--config", path, "profile", "add", "lab", "--address", "https://lab:8443", "--password-stdin"})
+		if err := cmd.Execute(); err == nil {
+			t.Error("expected an error for empty password, but got nil")
+		}
+	})
+
+	t.Run("profile add should not accept --password flag", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		cmd := newRootCommand(&app{out: &bytes.Buffer{}})
+		cmd.SetArgs([]string{"--config", path, "profile", "add", "lab", "--address", "https://lab:8443", "--password", "secret"})
+		if err := cmd.Execute(); err == nil {
+			t.Error("expected unknown flag error, but got nil")
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+var diskHeaders = []string{"NAME", "SERIAL", "MODEL", "SIZE", "LOCATION", "SMART", "USAGE", "POOL", "STATE", "LED"}
+
+func diskRows(disks []ns.Disk) [][]string {
+	rows := [][]string{}
+	for _, disk := range disks {
+		pool := disk.Pool
+		if pool == "" {
+			pool = "-"
+		}
+		state := disk.State
+		if state == "" {
+			state = "-"
+		}
+		rows = append(rows, []string{
+			disk.Name,
+			disk.Serial,
+			disk.Model,
+			formatSize(disk.Size),
+			disk.Location(),
+			disk.SmartHealth,
+			string(disk.Usage),
+			pool,
+			state,
+			formatBool(disk.LocateLED),
+		})
+	}
+	return rows
+}
+
+func newDiskCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk",
+		Short: "Show disks and find them in enclosures",
+	}
+
+	var failed, unused bool
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List disks with their location, SMART health and pool usage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			var disks []ns.Disk
+			if failed {
+				disks, err = p.GetFailedDisks()
+			} else {
+				disks, err = p.GetDisks()
+			}
+			if err != nil {
+				return err
+			}
+			if unused {
+				unusedDisks := []ns.Disk{}
+				for _, disk := range disks {
+					if disk.Usage == ns.DiskUsageUnused {
+						unusedDisks = append(unusedDisks, disk)
+					}
+				}
+				disks = unusedDisks
+			}
+			return a.print(disks, diskHeaders, diskRows(disks))
+		},
+	}
+	list.Flags().BoolVar(&failed, "failed", false, "show only disks failed in pools or with failure predicted by SMART")
+	list.Flags().BoolVar(&unused, "unused", false, "show only disks not used by pools")
+	cmd.AddCommand(list)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <name|serial>",
+		Short: "Show disk",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			disk, err := p.GetDisk(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(disk, diskHeaders, diskRows([]ns.Disk{disk}))
+		},
+	})
+
+	var off bool
+	locate := &cobra.Command{
+		Use:   "locate <name|serial>",
+		Short: "Turn disk locate LED on to find the disk in the enclosure",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			disk, err := p.GetDisk(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.SetDiskLocateLED(disk.Name, !off); err != nil {
+				return err
+			}
+			state := "on"
+			if off {
+				state = "off"
+			}
+			return a.printDone("disk '%s' (%s) locate LED is %s", disk.Name, disk.Location(), state)
+		},
+	}
+	locate.Flags().BoolVar(&off, "off", false, "turn locate LED off")
+	cmd.AddCommand(locate)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "enclosures",
+		Short: "List disk enclosures",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			enclosures, err := p.GetEnclosures()
+			if err != nil {
+				return err
+			}
+			rows := [][]string{}
+			for _, enclosure := range enclosures {
+				rows = append(rows, []string{
+					enclosure.Id,
+					enclosure.Vendor,
+					enclosure.Model,
+					enclosure.Serial,
+					strconv.Itoa(enclosure.Slots),
+				})
+			}
+			return a.print(enclosures, []string{"ID", "VENDOR", "MODEL", "SERIAL", "SLOTS"}, rows)
+		},
+	})
+
+	return cmd
+}
+
+func newSpareCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "spare",
+		Short: "Manage pool hot spares",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add <pool> <disk>...",
+		Short: "Assign disks as pool hot spares",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.AddPoolSpares(args[0], args[1:]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' spares added: %v", args[0], args[1:])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <pool> <disk>",
+		Short: "Remove hot spare from pool",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.RemovePoolSpare(args[0], args[1]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' spare removed: %s", args[0], args[1])
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func filesystemRows(filesystems []ns.Filesystem) [][]string {
+	rows := [][]string{}
+	for _, fs := range filesystems {
+		quota := "-"
+		if fs.ReferencedQuotaSize != 0 {
+			quota = formatSize(fs.ReferencedQuotaSize)
+		}
+		rows = append(rows, []string{
+			fs.Path,
+			formatSize(fs.BytesUsed),
+			formatSize(fs.BytesAvailable),
+			quota,
+			formatBool(fs.SharedOverNfs),
+			formatBool(fs.SharedOverSmb),
+			fs.MountPoint,
+		})
+	}
+	return rows
+}
+
+var filesystemHeaders = []string{"PATH", "USED", "AVAILABLE", "QUOTA", "NFS", "SMB", "MOUNTPOINT"}
+
+func newFilesystemCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "filesystem",
+		Aliases: []string{"fs"},
+		Short:   "Manage filesystems",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list <parent>",
+		Short: "List child filesystems",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			filesystems, err := p.GetFilesystems(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(filesystems, filesystemHeaders, filesystemRows(filesystems))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <path>",
+		Short: "Show filesystem",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			filesystem, err := p.GetFilesystem(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(filesystem, filesystemHeaders, filesystemRows([]ns.Filesystem{filesystem}))
+		},
+	})
+
+	var quota sizeFlag
+	create := &cobra.Command{
+		Use:   "create <path>",
+		Short: "Create filesystem, existing filesystem is updated to match the options",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(filepath.Dir(args[0]))
+			if err != nil {
+				return err
+			}
+			params := ns.EnsureFilesystemParams{Path: args[0]}
+			if cmd.Flags().Changed("quota") {
+				size := int64(quota)
+				params.ReferencedQuotaSize = &size
+			}
+			filesystem, _, err := p.EnsureFilesystem(params)
+			if err != nil {
+				return err
+			}
+			return a.print(filesystem, filesystemHeaders, filesystemRows([]ns.Filesystem{filesystem}))
+		},
+	}
+	create.Flags().Var(&quota, "quota", "referenced quota size, e.g. 10G, 0 removes the quota")
+	cmd.AddCommand(create)
+
+	var destroyParams ns.DestroyFilesystemParams
+	destroy := &cobra.Command{
+		Use:   "destroy <path>",
+		Short: "Destroy filesystem",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DestroyFilesystem(args[0], destroyParams); err != nil {
+				return err
+			}
+			return a.printDone("filesystem '%s' destroyed", args[0])
+		},
+	}
+	destroy.Flags().BoolVar(&destroyParams.DestroySnapshots, "destroy-snapshots", false, "destroy filesystem snapshots")
+	destroy.Flags().BoolVar(
+		&destroyParams.PromoteMostRecentCloneIfExists, "promote-clone", false,
+		"promote the most recent snapshot clone to keep dependent clones",
+	)
+	cmd.AddCommand(destroy)
+
+	var treeParams ns.DestroyTreeParams
+	destroyTree := &cobra.Command{
+		Use:   "destroy-tree <path>",
+		Short: "Destroy filesystem with all children, shares, volumes, snapshots and LUN mappings",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			result, err := p.DestroyTree(args[0], treeParams)
+			rows := [][]string{}
+			for _, action := range result.Plan {
+				rows = append(rows, []string{action.String()})
+			}
+			if printErr := a.print(result, []string{"ACTION"}, rows); printErr != nil {
+				return printErr
+			}
+			return err
+		},
+	}
+	destroyTree.Flags().BoolVar(&treeParams.DryRun, "dry-run", false, "print destroy plan only")
+	destroyTree.Flags().BoolVar(
+		&treeParams.PromoteMostRecentCloneIfExists, "promote-clone", false,
+		"promote snapshot clones located outside of the tree instead of failing",
+	)
+	cmd.AddCommand(destroyTree)
+
+	return cmd
+}
@@ -0,0 +1,233 @@
+// nsctl - NexentaStor command-line tool built on go-nexentastor library
+//
+// Usage examples:
+//
+//	nsctl profile add lab --address https://10.3.199.254:8443 --username admin --password-env NS_PASSWORD
+//	nsctl fs list pool/tenant
+//	nsctl volume create pool/tenant/vg/db --size 10G -o json
+//	nsctl apply -f state.yaml --dry-run
+//	source <(nsctl completion bash)
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/ns"
+)
+
+// app - global CLI options and lazily created NexentaStor resolver
+type app struct {
+	configPath  string
+	profileName string
+	output      string
+	address     string
+	username    string
+	passwordEnv string
+	insecure    bool
+	debug       bool
+
+	out      io.Writer
+	log      logger.Logger
+	resolver *ns.Resolver
+}
+
+func main() {
+	a := &app{out: os.Stdout}
+	if err := newRootCommand(a).Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand(a *app) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "nsctl",
+		Short:         "NexentaStor command-line tool",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !isOutputFormat(a.output) {
+				return fmt.Errorf("Unknown output format '%s', supported: %s", a.output, strings.Join(outputFormats, ", "))
+			}
+			l := logrus.New()
+			l.SetOutput(os.Stderr)
+			l.SetLevel(logrus.WarnLevel)
+			if a.debug {
+				l.SetLevel(logrus.DebugLevel)
+			}
+			a.log = logger.NewLogrusLogger(l.WithField("cmp", "nsctl"))
+			return nil
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&a.configPath, "config", defaultConfigPath(), "config file with appliance profiles")
+	flags.StringVarP(&a.profileName, "profile", "p", os.Getenv("NSCTL_PROFILE"), "profile name, current profile is used if not set")
+	flags.StringVarP(&a.output, "output", "o", outputTable, "output format: "+strings.Join(outputFormats, ", "))
+	flags.StringVar(&a.address, "address", "", "comma separated NexentaStor REST API addresses, overrides profile")
+	flags.StringVar(&a.username, "username", "", "NexentaStor REST API username, overrides profile")
+	flags.StringVar(&a.passwordEnv, "password-env", "NSCTL_PASSWORD",
+		"environment variable to read NexentaStor REST API password from, overrides profile if set")
+	flags.BoolVar(&a.insecure, "insecure", false, "skip TLS certificate verification")
+	flags.BoolVar(&a.debug, "debug", false, "print debug logs to stderr")
+
+	root.RegisterFlagCompletionFunc("output", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return outputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	root.RegisterFlagCompletionFunc("profile", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		config, err := loadConfig(a.configPath)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return config.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newProfileCommand(a),
+		newFilesystemCommand(a),
+		newVolumeCommand(a),
+		newSnapshotCommand(a),
+		newShareCommand(a),
+		newSanCommand(a),
+		newPoolCommand(a),
+		newDiskCommand(a),
+		newStatsCommand(a),
+		newRSFCommand(a),
+		newJobCommand(a),
+		newApplyCommand(a),
+	)
+
+	return root
+}
+
+func defaultConfigPath() string {
+	if path := os.Getenv("NSCTL_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".nsctl.yaml"
+	}
+	return filepath.Join(home, ".nsctl.yaml")
+}
+
+// getProfile returns selected profile with command line options applied, Password is set to the password to use:
+// --password-env variable value, profile PasswordEnv variable value or profile Password
+func (a *app) getProfile() (Profile, error) {
+	profile := Profile{}
+	if a.address == "" {
+		config, err := loadConfig(a.configPath)
+		if err != nil {
+			return profile, err
+		}
+		profile, err = config.getProfile(a.profileName)
+		if err != nil {
+			return profile, err
+		}
+	} else {
+		profile.Address = a.address
+	}
+
+	if a.username != "" {
+		profile.Username = a.username
+	}
+	profile.Password = profile.getPassword()
+	profile.PasswordEnv = ""
+	if a.passwordEnv != "" {
+		if password := os.Getenv(a.passwordEnv); password != "" {
+			profile.Password = password
+		}
+	}
+	profile.InsecureSkipVerify = a.insecure || profile.InsecureSkipVerify
+
+	return profile, nil
+}
+
+// getResolver creates resolver for selected profile
+func (a *app) getResolver() (*ns.Resolver, error) {
+	if a.resolver != nil {
+		return a.resolver, nil
+	}
+
+	profile, err := a.getProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := ns.NewResolver(ns.ResolverArgs{
+		Address:            profile.Address,
+		Username:           profile.Username,
+		Password:           profile.Password,
+		Log:                a.log,
+		InsecureSkipVerify: profile.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.resolver = resolver
+
+	return resolver, nil
+}
+
+// provider returns provider of the node holding the dataset, first node is used if path is empty
+func (a *app) provider(path string) (ns.ProviderInterface, error) {
+	resolver, err := a.getResolver()
+	if err != nil {
+		return nil, err
+	}
+
+	if path == "" || len(resolver.Nodes) == 1 {
+		return resolver.Nodes[0], nil
+	}
+
+	provider, err := resolver.Resolve(strings.SplitN(path, "@", 2)[0])
+	if err != nil {
+		return nil, err
+	} else if provider == nil {
+		return nil, fmt.Errorf("Dataset '%s' not found on any of profile nodes", path)
+	}
+
+	return provider, nil
+}
+
+// volumeProvider returns provider of the node holding parent volumeGroup of the volume
+func (a *app) volumeProvider(path string) (ns.ProviderInterface, error) {
+	resolver, err := a.getResolver()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resolver.Nodes) == 1 {
+		return resolver.Nodes[0], nil
+	}
+
+	volumeGroup := filepath.Dir(strings.SplitN(path, "@", 2)[0])
+	provider, err := resolver.ResolveFromVg(volumeGroup)
+	if err != nil {
+		return nil, err
+	} else if provider == nil {
+		return nil, fmt.Errorf("VolumeGroup '%s' not found on any of profile nodes", volumeGroup)
+	}
+
+	return provider, nil
+}
+
+// datasetProvider returns provider of the node holding filesystem or volume
+func (a *app) datasetProvider(path string) (ns.ProviderInterface, error) {
+	provider, err := a.provider(path)
+	if err == nil {
+		return provider, nil
+	}
+	if volumeProvider, volumeErr := a.volumeProvider(path); volumeErr == nil {
+		return volumeProvider, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestApp_getProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := Config{
+		CurrentProfile: "lab",
+		Profiles: map[string]Profile{
+			"lab":  {Address: "https://lab:8443", Username: "admin", Password: "stored", PasswordEnv: "NSCTL_TEST_LAB_PASSWORD"},
+			"prod": {Address: "https://prod:8443", Username: "root", Password: "stored", InsecureSkipVerify: true},
+		},
+	}
+	if err := config.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		app      app
+		env      map[string]string
+		expected Profile
+	}{
+		{
+			name:     "current profile with stored password",
+			app:      app{configPath: path},
+			expected: Profile{Address: "https://lab:8443", Username: "admin", Password: "stored"},
+		},
+		{
+			name:     "profile password env over stored password",
+			app:      app{configPath: path},
+			env:      map[string]string{"NSCTL_TEST_LAB_PASSWORD": "profile-env"},
+			expected: Profile{Address: "https://lab:8443", Username: "admin", Password: "profile-env"},
+		},
+		{
+			name: "--password-env over profile password env",
+			app:  app{configPath: path, passwordEnv: "NSCTL_TEST_PASSWORD"},
+			env: map[string]string{
+				"NSCTL_TEST_LAB_PASSWORD": "profile-env",
+				"NSCTL_TEST_PASSWORD":     "flag-env",
+			},
+			expected: Profile{Address: "https://lab:8443", Username: "admin", Password: "flag-env"},
+		},
+		{
+			name:     "empty --password-env variable is ignored",
+			app:      app{configPath: path, passwordEnv: "NSCTL_TEST_PASSWORD"},
+			expected: Profile{Address: "https://lab:8443", Username: "admin", Password: "stored"},
+		},
+		{
+			name:     "--profile and --username over current profile",
+			app:      app{configPath: path, profileName: "prod", username: "admin"},
+			expected: Profile{Address: "https://prod:8443", Username: "admin", Password: "stored", InsecureSkipVerify: true},
+		},
+		{
+			name:     "--address without profile",
+			app:      app{configPath: filepath.Join(t.TempDir(), "missing.yaml"), address: "https://other:8443", insecure: true},
+			expected: Profile{Address: "https://other:8443", InsecureSkipVerify: true},
+		},
+	} {
+		test := test
+		t.Run("getProfile() should resolve "+test.name, func(t *testing.T) {
+			for _, name := range []string{"NSCTL_TEST_LAB_PASSWORD", "NSCTL_TEST_PASSWORD"} {
+				t.Setenv(name, test.env[name])
+			}
+			profile, err := test.app.getProfile()
+			if err != nil {
+				t.Fatal(err)
+			} else if profile != test.expected {
+				t.Errorf("expected %+v, but got %+v", test.expected, profile)
+			}
+		})
+	}
+
+	t.Run("getProfile() should fail if no profile is selected", func(t *testing.T) {
+		a := app{configPath: filepath.Join(t.TempDir(), "missing.yaml")}
+		if _, err := a.getProfile(); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+	})
+
+	t.Run("--password flag should not be accepted", func(t *testing.T) {
+		cmd := newRootCommand(&app{out: &bytes.Buffer{}})
+		cmd.SetArgs([]string{"--config", path, "--password", "secret", "profile", "list"})
+		if err := cmd.Execute(); err == nil {
+			t.Error("expected unknown flag error, but got nil")
+		}
+	})
+}
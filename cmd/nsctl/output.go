@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// print writes value in selected output format, headers and rows are used for table output only
+func (a *app) print(value interface{}, headers []string, rows [][]string) error {
+	switch a.output {
+	case outputJSON:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(a.out, string(data))
+		return err
+	case outputYAML:
+		// convert through JSON to keep NexentaStor field names from json tags
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(a.out, string(data))
+		return err
+	default:
+		w := tabwriter.NewWriter(a.out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+}
+
+// printDone prints result of an operation without data
+func (a *app) printDone(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	return a.print(map[string]string{"result": message}, []string{"RESULT"}, [][]string{{message}})
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "-"
+}
+
+// formatSize returns human readable size, e.g. 1.5G
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return strconv.FormatInt(size, 10)
+	}
+	value := float64(size)
+	suffixes := "KMGTPE"
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return strconv.FormatFloat(math.Round(value*10)/10, 'f', -1, 64) + string(suffixes[i])
+}
+
+// parseSize parses size with optional K, M, G, T, P suffix (powers of 1024)
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	multiplier := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGTP", s[len(s)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * uint(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid size '%s', expected number with optional K, M, G, T or P suffix", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// sizeFlag - pflag.Value for sizes with K, M, G, T, P suffixes
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*f), 10)
+}
+
+func (f *sizeFlag) Set(s string) error {
+	size, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*f = sizeFlag(size)
+	return nil
+}
+
+func (f *sizeFlag) Type() string {
+	return "size"
+}
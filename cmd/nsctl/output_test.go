@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestApp_print(t *testing.T) {
+	type row struct {
+		Name string `json:"name"`
+		Size int64  `json:"bytesUsed"`
+	}
+	value := []row{{"p/fs", 1536}}
+	headers := []string{"NAME", "USED"}
+	rows := [][]string{{"p/fs", formatSize(1536)}}
+
+	for format, expected := range map[string]string{
+		outputTable: "NAME   USED\np/fs   1.5K\n",
+		outputJSON:  "[\n  {\n    \"name\": \"p/fs\",\n    \"bytesUsed\": 1536\n  }\n]\n",
+		outputYAML:  "- bytesUsed: 1536\n  name: p/fs\n",
+	} {
+		t.Run("print() should write "+format+" output", func(t *testing.T) {
+			out := &bytes.Buffer{}
+			a := &app{out: out, output: format}
+			if err := a.print(value, headers, rows); err != nil {
+				t.Fatal(err)
+			} else if out.String() != expected {
+				t.Errorf("expected:\n%q\nbut got:\n%q", expected, out.String())
+			}
+		})
+	}
+
+	t.Run("isOutputFormat() should accept known formats only", func(t *testing.T) {
+		for _, format := range outputFormats {
+			if !isOutputFormat(format) {
+				t.Errorf("format '%s' should be accepted", format)
+			}
+		}
+		if isOutputFormat("xml") {
+			t.Error("format 'xml' should not be accepted")
+		}
+	})
+}
+
+func TestSize(t *testing.T) {
+	for size, expected := range map[int64]string{
+		0:                  "0",
+		1023:               "1023",
+		1024:               "1K",
+		1536:               "1.5K",
+		10 * 1024 * 1024:   "10M",
+		1024 * 1024 * 1024: "1G",
+	} {
+		if formatted := formatSize(size); formatted != expected {
+			t.Errorf("formatSize(%d): expected '%s', but got '%s'", size, expected, formatted)
+		}
+	}
+
+	for s, expected := range map[string]int64{
+		"512":   512,
+		"1k":    1024,
+		"1.5K":  1536,
+		"10M":   10 * 1024 * 1024,
+		"2GiB":  2 * 1024 * 1024 * 1024,
+		"1TB":   1024 * 1024 * 1024 * 1024,
+		" 1G  ": 1024 * 1024 * 1024,
+	} {
+		if size, err := parseSize(s); err != nil || size != expected {
+			t.Errorf("parseSize('%s'): expected %d, but got %d, %v", s, expected, size, err)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "-1G", "1X"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize('%s'): expected an error, but got nil", s)
+		}
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func newSanCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "san",
+		Short: "Manage SAN objects: iSCSI targets, groups, LUN mappings and logical units",
+	}
+
+	cmd.AddCommand(
+		newLunMappingCommand(a),
+		newHostGroupCommand(a),
+		newTargetGroupCommand(a),
+		newISCSITargetCommand(a),
+		newLogicalUnitCommand(a),
+		newExportCommand(a),
+		newUnexportCommand(a),
+	)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "sessions",
+		Short: "List active iSCSI sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			sessions, err := p.GetISCSISessions()
+			if err != nil {
+				return err
+			}
+			rows := [][]string{}
+			for _, session := range sessions {
+				rows = append(rows, []string{session.Initiator, session.Target})
+			}
+			return a.print(sessions, []string{"INITIATOR", "TARGET"}, rows)
+		},
+	})
+
+	return cmd
+}
+
+var lunMappingHeaders = []string{"ID", "VOLUME", "HOSTGROUP", "TARGETGROUP", "LUN"}
+
+func lunMappingRows(lunMappings []ns.LunMapping) [][]string {
+	rows := [][]string{}
+	for _, m := range lunMappings {
+		rows = append(rows, []string{m.Id, m.Volume, m.HostGroup, m.TargetGroup, strconv.Itoa(m.Lun)})
+	}
+	return rows
+}
+
+func newLunMappingCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "lun-mapping",
+		Aliases: []string{"lm"},
+		Short:   "Manage LUN mappings",
+	}
+
+	var filter ns.GetLunMappingsParams
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List LUN mappings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			lunMappings, err := p.GetLunMappings(filter)
+			if err != nil {
+				return err
+			}
+			return a.print(lunMappings, lunMappingHeaders, lunMappingRows(lunMappings))
+		},
+	}
+	list.Flags().StringVar(&filter.Volume, "volume", "", "filter by volume")
+	list.Flags().StringVar(&filter.HostGroup, "host-group", "", "filter by hostGroup")
+	list.Flags().StringVar(&filter.TargetGroup, "target-group", "", "filter by targetGroup")
+	cmd.AddCommand(list)
+
+	var params ns.CreateLunMappingParams
+	var lun int
+	create := &cobra.Command{
+		Use:   "create <volume>",
+		Short: "Create LUN mapping, existing mapping is not treated as an error",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			createParams := params
+			createParams.Volume = args[0]
+			if cmd.Flags().Changed("lun") {
+				createParams.Lun = &lun
+			}
+			lunMapping, err := p.CreateLunMapping(createParams)
+			if err != nil {
+				return err
+			}
+			return a.print(lunMapping, lunMappingHeaders, lunMappingRows([]ns.LunMapping{lunMapping}))
+		},
+	}
+	create.Flags().StringVar(&params.HostGroup, "host-group", "All", "hostGroup name")
+	create.Flags().StringVar(&params.TargetGroup, "target-group", "All", "targetGroup name")
+	create.Flags().IntVar(&lun, "lun", 0, "LUN number, first free one is used if not set")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete LUN mapping",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			if err := p.DestroyLunMapping(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("LUN mapping '%s' deleted", args[0])
+		},
+	})
+
+	return cmd
+}
+
+// groupCommandOperations - hostGroup and targetGroup specific calls
+type groupCommandOperations struct {
+	list   func(p ns.ProviderInterface) ([]ns.HostGroup, error)
+	get    func(p ns.ProviderInterface, name string) (ns.HostGroup, error)
+	add    func(p ns.ProviderInterface, name string, members []string) error
+	remove func(p ns.ProviderInterface, name string, members []string) error
+	delete func(p ns.ProviderInterface, name string) error
+}
+
+func newGroupCommand(a *app, use, kind string, aliases []string, ops groupCommandOperations) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     use,
+		Aliases: aliases,
+		Short:   fmt.Sprintf("Manage %ss", kind),
+	}
+
+	headers := []string{"NAME", "MEMBERS"}
+	rows := func(groups []ns.HostGroup) [][]string {
+		rows := [][]string{}
+		for _, group := range groups {
+			rows = append(rows, []string{group.Name, strings.Join(group.Members, ",")})
+		}
+		return rows
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: fmt.Sprintf("List %ss", kind),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			groups, err := ops.list(p)
+			if err != nil {
+				return err
+			}
+			return a.print(groups, headers, rows(groups))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <name>",
+		Short: fmt.Sprintf("Show %s", kind),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			group, err := ops.get(p, args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(group, headers, rows([]ns.HostGroup{group}))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add-members <name> <member>...",
+		Short: fmt.Sprintf("Add members to %s, the %s is created if it doesn't exist", kind, kind),
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			if err := ops.add(p, args[0], args[1:]); err != nil {
+				return err
+			}
+			return a.printDone("%s '%s' members added: %s", kind, args[0], strings.Join(args[1:], ","))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove-members <name> <member>...",
+		Short: fmt.Sprintf("Remove members from %s", kind),
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			if err := ops.remove(p, args[0], args[1:]); err != nil {
+				return err
+			}
+			return a.printDone("%s '%s' members removed: %s", kind, args[0], strings.Join(args[1:], ","))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: fmt.Sprintf("Delete %s", kind),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			if err := ops.delete(p, args[0]); err != nil {
+				return err
+			}
+			return a.printDone("%s '%s' deleted", kind, args[0])
+		},
+	})
+
+	return cmd
+}
+
+func newHostGroupCommand(a *app) *cobra.Command {
+	return newGroupCommand(a, "host-group", "hostGroup", []string{"hg"}, groupCommandOperations{
+		list: func(p ns.ProviderInterface) ([]ns.HostGroup, error) {
+			return p.GetHostGroups()
+		},
+		get: func(p ns.ProviderInterface, name string) (ns.HostGroup, error) {
+			return p.GetHostGroup(name)
+		},
+		add: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.AddHostGroupMembers(name, members)
+		},
+		remove: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.RemoveHostGroupMembers(name, members)
+		},
+		delete: func(p ns.ProviderInterface, name string) error {
+			return p.DeleteHostGroup(name)
+		},
+	})
+}
+
+func newTargetGroupCommand(a *app) *cobra.Command {
+	return newGroupCommand(a, "target-group", "targetGroup", []string{"tg"}, groupCommandOperations{
+		list: func(p ns.ProviderInterface) ([]ns.HostGroup, error) {
+			targetGroups, err := p.GetTargetGroups()
+			groups := []ns.HostGroup{}
+			for _, targetGroup := range targetGroups {
+				groups = append(groups, ns.HostGroup{Name: targetGroup.Name, Members: targetGroup.Members})
+			}
+			return groups, err
+		},
+		get: func(p ns.ProviderInterface, name string) (ns.HostGroup, error) {
+			targetGroup, err := p.GetTargetGroup(name)
+			return ns.HostGroup{Name: targetGroup.Name, Members: targetGroup.Members}, err
+		},
+		add: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.AddTargetGroupMembers(name, members)
+		},
+		remove: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.RemoveTargetGroupMembers(name, members)
+		},
+		delete: func(p ns.ProviderInterface, name string) error {
+			return p.DeleteTargetGroup(name)
+		},
+	})
+}
+
+// parsePortals parses portals in "<address>[:<port>]" format
+func parsePortals(list []string) ([]ns.Portal, error) {
+	portals := []ns.Portal{}
+	for _, item := range list {
+		host, port, err := net.SplitHostPort(item)
+		if err != nil {
+			portals = append(portals, ns.Portal{Address: item, Port: 3260})
+			continue
+		}
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid portal '%s', expected '<address>[:<port>]'", item)
+		}
+		portals = append(portals, ns.Portal{Address: host, Port: portNumber})
+	}
+	return portals, nil
+}
+
+func formatPortals(portals []ns.Portal) string {
+	list := []string{}
+	for _, portal := range portals {
+		list = append(list, net.JoinHostPort(portal.Address, strconv.Itoa(portal.Port)))
+	}
+	return strings.Join(list, ",")
+}
+
+func newISCSITargetCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "target",
+		Short: "Manage iSCSI targets",
+	}
+
+	headers := []string{"NAME", "STATE", "AUTHENTICATION", "PORTALS"}
+	rows := func(targets []ns.ISCSITarget) [][]string {
+		rows := [][]string{}
+		for _, target := range targets {
+			rows = append(rows, []string{target.Name, target.State, target.Authentication, formatPortals(target.Portals)})
+		}
+		return rows
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List iSCSI targets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			targets, err := p.GetISCSITargets("")
+			if err != nil {
+				return err
+			}
+			return a.print(targets, headers, rows(targets))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <name>",
+		Short: "Show iSCSI target",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			target, err := p.GetISCSITarget(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(target, headers, rows([]ns.ISCSITarget{target}))
+		},
+	})
+
+	var portals []string
+	var params ns.CreateISCSITargetParams
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create iSCSI target",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			createParams := params
+			createParams.Name = args[0]
+			if createParams.Portals, err = parsePortals(portals); err != nil {
+				return err
+			}
+			target, err := p.CreateISCSITarget(createParams)
+			if err != nil {
+				return err
+			}
+			return a.print(target, headers, rows([]ns.ISCSITarget{target}))
+		},
+	}
+	create.Flags().StringSliceVar(&portals, "portal", nil, "portal address with optional port, e.g. 10.0.0.1:3260")
+	create.Flags().StringVar(&params.Alias, "alias", "", "target alias")
+	create.Flags().StringVar(&params.Authentication, "authentication", "", "authentication method: none or chap")
+	create.Flags().StringVar(&params.ChapUser, "chap-user", "", "target CHAP user for mutual CHAP")
+	create.Flags().StringVar(&params.ChapSecret, "chap-secret", "", "target CHAP secret for mutual CHAP")
+	create.Flags().BoolVar(&params.Ensure, "ensure", false, "update existing target to match the options")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete iSCSI target",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			if err := p.DeleteISCSITarget(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("iSCSI target '%s' deleted", args[0])
+		},
+	})
+
+	return cmd
+}
+
+func newLogicalUnitCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "logical-unit",
+		Aliases: []string{"lu"},
+		Short:   "Show logical units",
+	}
+
+	headers := []string{"GUID", "VOLUME", "STATE", "MAPPINGS"}
+	rows := func(logicalUnits []ns.LogicalUnit) [][]string {
+		rows := [][]string{}
+		for _, lu := range logicalUnits {
+			rows = append(rows, []string{lu.Guid, lu.Volume, lu.State, strconv.Itoa(lu.MappingCount)})
+		}
+		return rows
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List logical units",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			logicalUnits, err := p.GetLogicalUnits()
+			if err != nil {
+				return err
+			}
+			return a.print(logicalUnits, headers, rows(logicalUnits))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <volume>",
+		Short: "Show logical unit of a volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			logicalUnit, err := p.GetLogicalUnitByVolume(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(logicalUnit, headers, rows([]ns.LogicalUnit{logicalUnit}))
+		},
+	})
+
+	return cmd
+}
+
+func newExportCommand(a *app) *cobra.Command {
+	var params ns.ExportVolumeISCSIParams
+	var portals, initiators []string
+	var lun int
+
+	cmd := &cobra.Command{
+		Use:   "export <volume>",
+		Short: "Expose volume over iSCSI to initiators",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			exportParams := params
+			if exportParams.Portals, err = parsePortals(portals); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("lun") {
+				exportParams.Lun = &lun
+			}
+			result, err := p.ExportVolumeISCSI(args[0], initiators, exportParams)
+			if err != nil {
+				return err
+			}
+			return a.print(result, []string{"TARGET", "PORTALS", "LUN", "WWN"}, [][]string{{
+				result.TargetIQN,
+				formatPortals(result.Portals),
+				strconv.Itoa(result.Lun),
+				result.DeviceWWN,
+			}})
+		},
+	}
+	cmd.Flags().StringSliceVar(&initiators, "initiator", nil, "initiator IQN allowed to access the volume")
+	cmd.MarkFlagRequired("initiator")
+	cmd.Flags().StringVar(&params.Target, "target", "", "iSCSI target name, created if it doesn't exist")
+	cmd.MarkFlagRequired("target")
+	cmd.Flags().StringSliceVar(&portals, "portal", nil, "target portal address with optional port")
+	cmd.Flags().StringVar(&params.TargetGroup, "target-group", "", "targetGroup name, 'tg-<target>' by default")
+	cmd.Flags().StringVar(&params.HostGroup, "host-group", "", "hostGroup name, generated from initiators by default")
+	cmd.Flags().IntVar(&lun, "lun", 0, "LUN number, first free one is used if not set")
+	cmd.Flags().StringVar(&params.ChapUser, "chap-user", "", "initiator CHAP user")
+	cmd.Flags().StringVar(&params.ChapSecret, "chap-secret", "", "initiator CHAP secret")
+
+	return cmd
+}
+
+func newUnexportCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unexport <volume>",
+		Short: "Remove iSCSI exposure of volume and SAN objects left unused",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			result, err := p.UnexportVolumeISCSI(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(result, []string{"LUN MAPPINGS", "HOSTGROUPS", "TARGETGROUPS", "TARGETS"}, [][]string{{
+				strconv.Itoa(len(result.RemovedLunMappings)),
+				strings.Join(result.RemovedHostGroups, ","),
+				strings.Join(result.RemovedTargetGroups, ","),
+				strings.Join(result.RemovedTargets, ","),
+			}})
+		},
+	}
+}
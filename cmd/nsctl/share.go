@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func newShareCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "share",
+		Short: "Manage NFS and SMB shares of filesystems",
+	}
+	cmd.AddCommand(newNfsShareCommand(a), newSmbShareCommand(a))
+	return cmd
+}
+
+// parseNfsRules parses NFS rules in "<entity>[/<mask>]" format, entity type is detected by the value:
+// "*" and host names are "fqdn", IP addresses with mask are "network"
+func parseNfsRules(rules []string) ([]ns.NfsRuleList, error) {
+	list := []ns.NfsRuleList{}
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, "/", 2)
+		if len(parts) == 1 {
+			list = append(list, ns.NfsRuleList{Etype: "fqdn", Entity: parts[0]})
+			continue
+		}
+		mask, err := strconv.Atoi(parts[1])
+		if err != nil || mask < 0 || mask > 128 {
+			return nil, fmt.Errorf("Invalid NFS rule '%s', expected '<host>' or '<network>/<mask>'", rule)
+		}
+		list = append(list, ns.NfsRuleList{Etype: "network", Entity: parts[0], Mask: mask})
+	}
+	return list, nil
+}
+
+func formatNfsRules(rules []ns.NfsRuleList) string {
+	list := []string{}
+	for _, rule := range rules {
+		if rule.Etype == "network" {
+			list = append(list, fmt.Sprintf("%s/%d", rule.Entity, rule.Mask))
+		} else {
+			list = append(list, rule.Entity)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+func newNfsShareCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nfs",
+		Short: "Manage NFS shares",
+	}
+
+	headers := []string{"FILESYSTEM", "READ-WRITE", "READ-ONLY"}
+	printShare := func(share ns.NfsShare) error {
+		return a.print(share, headers, [][]string{{
+			share.Filesystem,
+			formatNfsRules(share.ReadWriteList),
+			formatNfsRules(share.ReadOnlyList),
+		}})
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <filesystem>",
+		Short: "Show NFS share",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			share, err := p.GetNfsShare(args[0])
+			if err != nil {
+				return err
+			}
+			return printShare(share)
+		},
+	})
+
+	var readWrite, readOnly []string
+	create := &cobra.Command{
+		Use:   "create <filesystem>",
+		Short: "Share filesystem over NFS, existing share access lists are updated",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			params := ns.CreateNfsShareParams{Filesystem: args[0]}
+			if params.ReadWriteList, err = parseNfsRules(readWrite); err != nil {
+				return err
+			}
+			if params.ReadOnlyList, err = parseNfsRules(readOnly); err != nil {
+				return err
+			}
+			result, err := p.EnsureNfsShare(params)
+			if err != nil {
+				return err
+			}
+			return a.printDone("NFS share of '%s' %s", args[0], result)
+		},
+	}
+	create.Flags().StringSliceVar(&readWrite, "rw", nil, "read-write access rules: '*', host or network/mask")
+	create.Flags().StringSliceVar(&readOnly, "ro", nil, "read-only access rules: '*', host or network/mask")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <filesystem>",
+		Short: "Delete NFS share",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DeleteNfsShare(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("NFS share of '%s' deleted", args[0])
+		},
+	})
+
+	return cmd
+}
+
+func newSmbShareCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "smb",
+		Short: "Manage SMB shares",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <filesystem>",
+		Short: "Show SMB share name",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			shareName, err := p.GetSmbShareName(args[0])
+			if err != nil {
+				return err
+			}
+			share := ns.CreateSmbShareParams{Filesystem: args[0], ShareName: shareName}
+			return a.print(share, []string{"FILESYSTEM", "SHARE NAME"}, [][]string{{args[0], shareName}})
+		},
+	})
+
+	var shareName string
+	create := &cobra.Command{
+		Use:   "create <filesystem>",
+		Short: "Share filesystem over SMB, existing share name is updated",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			result, err := p.EnsureSmbShare(ns.CreateSmbShareParams{Filesystem: args[0], ShareName: shareName})
+			if err != nil {
+				return err
+			}
+			return a.printDone("SMB share of '%s' %s", args[0], result)
+		},
+	}
+	create.Flags().StringVar(&shareName, "name", "", "share name, generated by NexentaStor if not set")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <filesystem>",
+		Short: "Delete SMB share",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DeleteSmbShare(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("SMB share of '%s' deleted", args[0])
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+var snapshotHeaders = []string{"PATH", "CREATED", "CLONES"}
+
+func snapshotRows(snapshots []ns.Snapshot) [][]string {
+	rows := [][]string{}
+	for _, snapshot := range snapshots {
+		clones := strings.Join(snapshot.Clones, ",")
+		if clones == "" {
+			clones = "-"
+		}
+		rows = append(rows, []string{snapshot.Path, snapshot.CreationTime.Format(time.RFC3339), clones})
+	}
+	return rows
+}
+
+func newSnapshotCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "snapshot",
+		Aliases: []string{"snap"},
+		Short:   "Manage snapshots",
+	}
+
+	var recursive bool
+	list := &cobra.Command{
+		Use:   "list <dataset>",
+		Short: "List snapshots of filesystem or volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.datasetProvider(args[0])
+			if err != nil {
+				return err
+			}
+			snapshots, err := p.GetSnapshots(args[0], recursive)
+			if err != nil {
+				return err
+			}
+			return a.print(snapshots, snapshotHeaders, snapshotRows(snapshots))
+		},
+	}
+	list.Flags().BoolVarP(&recursive, "recursive", "r", false, "include snapshots of child datasets")
+	cmd.AddCommand(list)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <dataset@snapshot>",
+		Short: "Show snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.datasetProvider(args[0])
+			if err != nil {
+				return err
+			}
+			snapshot, err := p.GetSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(snapshot, snapshotHeaders, snapshotRows([]ns.Snapshot{snapshot}))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "create <dataset@snapshot>",
+		Short: "Create snapshot, existing snapshot is not treated as an error",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.datasetProvider(args[0])
+			if err != nil {
+				return err
+			}
+			snapshot, _, err := p.EnsureSnapshot(ns.CreateSnapshotParams{Path: args[0]})
+			if err != nil {
+				return err
+			}
+			return a.print(snapshot, snapshotHeaders, snapshotRows([]ns.Snapshot{snapshot}))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "destroy <dataset@snapshot>",
+		Short: "Destroy snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.datasetProvider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DestroySnapshot(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("snapshot '%s' destroyed", args[0])
+		},
+	})
+
+	var quota sizeFlag
+	clone := &cobra.Command{
+		Use:   "clone <dataset@snapshot> <target>",
+		Short: "Clone snapshot to a new filesystem or volume",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.datasetProvider(args[0])
+			if err != nil {
+				return err
+			}
+			result, err := p.CloneSnapshot(args[0], ns.CloneSnapshotParams{
+				TargetPath:          args[1],
+				ReferencedQuotaSize: int64(quota),
+			})
+			if err != nil {
+				return err
+			}
+			return a.print(result, []string{"PATH", "TYPE"}, [][]string{{result.Path, string(result.Type)}})
+		},
+	}
+	clone.Flags().Var(&quota, "quota", "referenced quota size of filesystem clone")
+	cmd.AddCommand(clone)
+
+	return cmd
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+var analyticsMetrics = []string{
+	string(ns.AnalyticsMetricIOPS),
+	string(ns.AnalyticsMetricThroughput),
+	string(ns.AnalyticsMetricLatency),
+}
+
+func formatAnalyticsValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+func newStatsCommand(a *app) *cobra.Command {
+	var pool, dataset, volume, protocol, children string
+	var since, resolution time.Duration
+
+	cmd := &cobra.Command{
+		Use:       "stats <iops|throughput|latency>",
+		Short:     "Show performance analytics of pool, dataset, volume or protocol",
+		Example:   "  nsctl stats iops --dataset pool1/tenant/fs1 --since 6h --resolution 5m\n  nsctl stats throughput --children pool1/tenant --since 24h --resolution 1h",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: analyticsMetrics,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := ns.AnalyticsQuery{
+				Metric:     ns.AnalyticsMetric(args[0]),
+				End:        time.Now(),
+				Resolution: resolution,
+			}
+			query.Start = query.End.Add(-since)
+
+			var path string
+			switch {
+			case children != "":
+				path = children
+			case pool != "":
+				query.EntityType, query.Entity, path = ns.AnalyticsEntityPool, pool, pool
+			case dataset != "":
+				query.EntityType, query.Entity, path = ns.AnalyticsEntityDataset, dataset, dataset
+			case volume != "":
+				query.EntityType, query.Entity = ns.AnalyticsEntityVolume, volume
+			case protocol != "":
+				query.EntityType, query.Entity = ns.AnalyticsEntityProtocol, protocol
+			default:
+				return fmt.Errorf("One of --pool, --dataset, --volume, --protocol or --children is required")
+			}
+
+			var p ns.ProviderInterface
+			var err error
+			if volume != "" {
+				p, err = a.volumeProvider(volume)
+			} else {
+				p, err = a.provider(path)
+			}
+			if err != nil {
+				return err
+			}
+
+			if children != "" {
+				list, err := p.GetDatasetsAnalytics(children, query)
+				if err != nil {
+					return err
+				}
+				sort.SliceStable(list, func(i, j int) bool {
+					return list[i].Average().Total() > list[j].Average().Total()
+				})
+				rows := [][]string{}
+				for _, series := range list {
+					average := series.Average()
+					rows = append(rows, []string{
+						series.Entity,
+						formatAnalyticsValue(average.Read),
+						formatAnalyticsValue(average.Write),
+						formatAnalyticsValue(series.Max().Total()),
+						series.Unit,
+					})
+				}
+				return a.print(list, []string{"DATASET", "AVG READ", "AVG WRITE", "MAX TOTAL", "UNIT"}, rows)
+			}
+
+			series, err := p.GetAnalytics(query)
+			if err != nil {
+				return err
+			}
+			rows := [][]string{}
+			for _, point := range series.Points {
+				rows = append(rows, []string{
+					point.Time.Format(time.RFC3339),
+					formatAnalyticsValue(point.Read),
+					formatAnalyticsValue(point.Write),
+					formatAnalyticsValue(point.Total()),
+				})
+			}
+			unit := fmt.Sprintf(" (%s)", series.Unit)
+			return a.print(series, []string{"TIME", "READ" + unit, "WRITE" + unit, "TOTAL" + unit}, rows)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&pool, "pool", "", "pool name")
+	flags.StringVar(&dataset, "dataset", "", "filesystem path")
+	flags.StringVar(&volume, "volume", "", "volume path")
+	flags.StringVar(&protocol, "protocol", "", "protocol: nfs, smb or iscsi")
+	flags.StringVar(&children, "children", "", "show averages of all child datasets of the filesystem, highest load first")
+	flags.DurationVar(&since, "since", time.Hour, "time range ending now")
+	flags.DurationVar(&resolution, "resolution", time.Minute, "interval between points")
+	cmd.MarkFlagsMutuallyExclusive("pool", "dataset", "volume", "protocol", "children")
+	cmd.RegisterFlagCompletionFunc("protocol", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{ns.AnalyticsProtocolNFS, ns.AnalyticsProtocolSMB, ns.AnalyticsProtocolISCSI},
+			cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
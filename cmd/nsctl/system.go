@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+	"go-nexentastor/pkg/state"
+)
+
+var poolHeaders = []string{"NAME", "HEALTH", "SIZE", "ALLOCATED", "FREE", "FRAG", "ERRORS", "SCAN"}
+
+func poolRow(pool ns.Pool) []string {
+	scan := string(pool.Scan.State)
+	if pool.Scan.IsRunning() {
+		scan = fmt.Sprintf("%s %.1f%%", pool.Scan.Function, pool.Scan.Progress())
+	} else if scan == "" {
+		scan = "-"
+	}
+	return []string{
+		pool.Name,
+		pool.Health,
+		formatSize(pool.Size),
+		formatSize(pool.Allocated),
+		formatSize(pool.Free),
+		fmt.Sprintf("%d%%", pool.Fragmentation),
+		strconv.FormatInt(pool.Errors().Total(), 10),
+		scan,
+	}
+}
+
+// parseVdevs parses vdevs in "<type>:<device>,<device>..." format, e.g. "mirror:c1t1d0,c1t2d0"
+func parseVdevs(list []string) ([]ns.VdevSpec, error) {
+	vdevs := []ns.VdevSpec{}
+	for _, item := range list {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid vdev '%s', expected '<type>:<device>,<device>...'", item)
+		}
+		vdevs = append(vdevs, ns.VdevSpec{Type: ns.VdevType(parts[0]), Devices: strings.Split(parts[1], ",")})
+	}
+	return vdevs, nil
+}
+
+func newPoolCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pool",
+		Short: "Manage pools",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List pools of all profile nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := a.getResolver()
+			if err != nil {
+				return err
+			}
+			type nodePool struct {
+				ns.Pool
+				Node string `json:"node"`
+			}
+			pools := []nodePool{}
+			rows := [][]string{}
+			for _, node := range resolver.Nodes {
+				nodePools, err := node.GetPools()
+				if err != nil {
+					return err
+				}
+				for _, pool := range nodePools {
+					pools = append(pools, nodePool{pool, fmt.Sprint(node)})
+					rows = append(rows, append(poolRow(pool), fmt.Sprint(node)))
+				}
+			}
+			return a.print(pools, append(poolHeaders, "NODE"), rows)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <name>",
+		Short: "Show pool",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			pool, err := p.GetPool(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(pool, poolHeaders, [][]string{poolRow(pool)})
+		},
+	})
+
+	var data, log []string
+	var createParams ns.CreatePoolParams
+	create := &cobra.Command{
+		Use:     "create <name>",
+		Short:   "Create pool with specified vdev layout",
+		Example: "  nsctl pool create pool1 --vdev mirror:c1t1d0,c1t2d0 --vdev mirror:c1t3d0,c1t4d0 --spare c1t5d0",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			params := createParams
+			params.Name = args[0]
+			if params.Layout.Data, err = parseVdevs(data); err != nil {
+				return err
+			}
+			if params.Layout.Log, err = parseVdevs(log); err != nil {
+				return err
+			}
+			pool, err := p.CreatePool(params)
+			if err != nil {
+				return err
+			}
+			return a.print(pool, poolHeaders, [][]string{poolRow(pool)})
+		},
+	}
+	create.Flags().StringArrayVar(&data, "vdev", nil, "data vdev '<type>:<device>,<device>...', types: disk, mirror, raidz1, raidz2, raidz3")
+	create.MarkFlagRequired("vdev")
+	create.Flags().StringArrayVar(&log, "log", nil, "log vdev '<type>:<device>,<device>...'")
+	create.Flags().StringSliceVar(&createParams.Layout.Cache, "cache", nil, "cache devices")
+	create.Flags().StringSliceVar(&createParams.Layout.Spare, "spare", nil, "spare devices")
+	create.Flags().BoolVar(&createParams.Force, "force", false, "use devices even if they contain data of another pool")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "destroy <name>",
+		Short: "Destroy pool and all its data",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DestroyPool(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' destroyed", args[0])
+		},
+	})
+
+	var importParams ns.ImportPoolParams
+	importCmd := &cobra.Command{
+		Use:   "import <name>",
+		Short: "Import pool",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			params := importParams
+			params.Name = args[0]
+			pool, err := p.ImportPool(params)
+			if err != nil {
+				return err
+			}
+			return a.print(pool, poolHeaders, [][]string{poolRow(pool)})
+		},
+	}
+	importCmd.Flags().StringVar(&importParams.NewName, "new-name", "", "import pool under a new name")
+	importCmd.Flags().BoolVar(&importParams.Force, "force", false, "import pool even if it is in use by another system")
+	cmd.AddCommand(importCmd)
+
+	var exportParams ns.ExportPoolParams
+	exportCmd := &cobra.Command{
+		Use:   "export <name>",
+		Short: "Export pool",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.ExportPool(args[0], exportParams); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' exported", args[0])
+		},
+	}
+	exportCmd.Flags().BoolVar(&exportParams.Force, "force", false, "export pool even if its datasets are in use")
+	cmd.AddCommand(exportCmd)
+
+	cmd.AddCommand(newScrubCommand(a), newSpareCommand(a), newVdevCommand(a))
+
+	return cmd
+}
+
+func newScrubCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scrub",
+		Short: "Manage pool scrub",
+	}
+
+	printScan := func(name string, scan ns.PoolScan) error {
+		return a.print(scan, []string{"POOL", "FUNCTION", "STATE", "PROGRESS", "ERRORS"}, [][]string{{
+			name,
+			scan.Function,
+			string(scan.State),
+			fmt.Sprintf("%.1f%%", scan.Progress()),
+			strconv.FormatInt(scan.Errors, 10),
+		}})
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "start <pool>",
+		Short: "Start pool scrub",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.StartPoolScrub(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' scrub started", args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "stop <pool>",
+		Short: "Stop pool scrub",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.StopPoolScrub(args[0]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' scrub stopped", args[0])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status <pool>",
+		Short: "Show last pool scan status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			scan, err := p.GetPoolScanStatus(args[0])
+			if err != nil {
+				return err
+			}
+			return printScan(args[0], scan)
+		},
+	})
+
+	return cmd
+}
+
+func newVdevCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vdev",
+		Short: "Change pool vdevs: add, remove, attach, detach and replace devices",
+	}
+
+	var data, log, cache []string
+	add := &cobra.Command{
+		Use:     "add <pool>",
+		Short:   "Add data vdevs, log vdevs and cache devices to pool",
+		Example: "  nsctl pool vdev add pool1 --vdev mirror:c1t5d0,c1t6d0 --cache c2t2d0",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			layout := ns.PoolLayout{Cache: cache}
+			if layout.Data, err = parseVdevs(data); err != nil {
+				return err
+			}
+			if layout.Log, err = parseVdevs(log); err != nil {
+				return err
+			}
+			if err := p.AddPoolVdevs(args[0], layout); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' vdevs added", args[0])
+		},
+	}
+	add.Flags().StringArrayVar(&data, "vdev", nil, "data vdev '<type>:<device>,<device>...'")
+	add.Flags().StringArrayVar(&log, "log", nil, "log vdev '<type>:<device>,<device>...'")
+	add.Flags().StringSliceVar(&cache, "cache", nil, "cache devices")
+	cmd.AddCommand(add)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <pool> <device>",
+		Short: "Remove cache device, log device or log vdev from pool",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.RemovePoolDevice(args[0], args[1]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' device removed: %s", args[0], args[1])
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "detach <pool> <device>",
+		Short: "Detach device from mirror",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			if err := p.DetachPoolDevice(args[0], args[1]); err != nil {
+				return err
+			}
+			return a.printDone("pool '%s' device detached: %s", args[0], args[1])
+		},
+	})
+
+	var wait bool
+	var timeout time.Duration
+	resilverCommand := func(use, short string, start func(p ns.ProviderInterface, args []string) (ns.PoolJob, error)) *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.ExactArgs(3),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				p, err := a.provider(args[0])
+				if err != nil {
+					return err
+				}
+				job, err := start(p, args)
+				if err != nil {
+					return err
+				}
+				if !wait {
+					return a.printDone("pool '%s' resilver started, job: '%s'", args[0], job.JobID)
+				}
+				status, err := job.Wait(timeout, func(status ns.PoolJobStatus) {
+					fmt.Fprintf(os.Stderr, "pool '%s' resilver progress: %.1f%%\n", args[0], status.Progress)
+				})
+				if err != nil {
+					return err
+				}
+				return a.printDone("pool '%s' resilver done, errors: %d", args[0], status.Scan.Errors)
+			},
+		}
+		cmd.Flags().BoolVar(&wait, "wait", false, "wait for resilver completion")
+		cmd.Flags().DurationVar(&timeout, "timeout", 24*time.Hour, "max time to wait for resilver")
+		return cmd
+	}
+
+	cmd.AddCommand(resilverCommand(
+		"attach <pool> <device> <new-device>",
+		"Attach new device to existing one making a mirror",
+		func(p ns.ProviderInterface, args []string) (ns.PoolJob, error) {
+			return p.AttachPoolDevice(args[0], args[1], args[2])
+		},
+	))
+
+	cmd.AddCommand(resilverCommand(
+		"replace <pool> <device> <new-device>",
+		"Replace pool device with a new one",
+		func(p ns.ProviderInterface, args []string) (ns.PoolJob, error) {
+			return p.ReplacePoolDevice(args[0], args[1], args[2])
+		},
+	))
+
+	return cmd
+}
+
+func newRSFCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rsf",
+		Short: "Show RSF clusters",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List RSF clusters",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			clusters, err := p.GetRSFClusters()
+			if err != nil {
+				return err
+			}
+			rows := [][]string{}
+			for _, cluster := range clusters {
+				services := []string{}
+				for _, service := range cluster.Services {
+					services = append(services, service.ServiceName)
+				}
+				rows = append(rows, []string{
+					cluster.Name,
+					cluster.Health.ClusterHealth,
+					cluster.Health.NodesHealth,
+					cluster.Health.ServicesHealth,
+					strings.Join(services, ","),
+				})
+			}
+			return a.print(clusters, []string{"NAME", "HEALTH", "NODES", "SERVICES HEALTH", "SERVICES"}, rows)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "is-cluster",
+		Short: "Check if profile nodes belong to the same RSF cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := a.getResolver()
+			if err != nil {
+				return err
+			}
+			isCluster, err := resolver.IsCluster()
+			if err != nil {
+				return err
+			}
+			return a.print(
+				map[string]bool{"cluster": isCluster},
+				[]string{"CLUSTER"},
+				[][]string{{formatBool(isCluster)}},
+			)
+		},
+	})
+
+	return cmd
+}
+
+func newJobCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "job",
+		Short: "Check NexentaStor async jobs",
+	}
+
+	status := func(p ns.ProviderInterface, id string) error {
+		done, err := p.IsJobDone(id)
+		if err != nil {
+			return err
+		}
+		state := "running"
+		if done {
+			state = "done"
+		}
+		return a.print(map[string]string{"id": id, "state": state}, []string{"ID", "STATE"}, [][]string{{id, state}})
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status <id>",
+		Short: "Show job status",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			return status(p, args[0])
+		},
+	})
+
+	var timeout time.Duration
+	wait := &cobra.Command{
+		Use:   "wait <id>",
+		Short: "Wait for job completion",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			deadline := time.Now().Add(timeout)
+			for {
+				done, err := p.IsJobDone(args[0])
+				if err != nil {
+					return err
+				} else if done {
+					return status(p, args[0])
+				} else if time.Now().After(deadline) {
+					return fmt.Errorf("Job '%s' is not done after %s", args[0], timeout)
+				}
+				time.Sleep(3 * time.Second)
+			}
+		},
+	}
+	wait.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "max time to wait")
+	cmd.AddCommand(wait)
+
+	return cmd
+}
+
+func newApplyCommand(a *app) *cobra.Command {
+	var file string
+	var params state.ApplyParams
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Converge appliance to desired state described in YAML or JSON file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			desired, err := state.Load(file)
+			if err != nil {
+				return err
+			}
+			p, err := a.provider("")
+			if err != nil {
+				return err
+			}
+			result, applyErr := state.Apply(p, desired, params)
+
+			changes := result.Plan.Changes
+			if !params.DryRun {
+				changes = result.Done
+			}
+			rows := [][]string{}
+			list := []string{}
+			for _, change := range changes {
+				rows = append(rows, []string{change.String()})
+				list = append(list, change.String())
+			}
+			if result.Failed != nil {
+				rows = append(rows, []string{"FAILED: " + result.Failed.Error()})
+			}
+			if err := a.print(list, []string{"CHANGE"}, rows); err != nil {
+				return err
+			}
+			return applyErr
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "desired state file, JSON if has .json extension, YAML otherwise")
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagFilename("file", "yaml", "yml", "json")
+	cmd.Flags().BoolVar(&params.DryRun, "dry-run", false, "print planned changes only")
+	cmd.Flags().BoolVar(&params.Prune, "prune", false, "remove resources missing in the desired state")
+
+	return cmd
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"go-nexentastor/pkg/ns"
+)
+
+var volumeHeaders = []string{"PATH", "SIZE", "USED", "BLOCKSIZE", "SPARSE", "COMPRESSION", "SYNC", "ORIGIN"}
+
+func volumeRows(volumes []ns.Volume) [][]string {
+	rows := [][]string{}
+	for _, volume := range volumes {
+		origin := volume.Origin
+		if origin == "" {
+			origin = "-"
+		}
+		rows = append(rows, []string{
+			volume.Path,
+			formatSize(volume.VolumeSize),
+			formatSize(volume.BytesUsed),
+			formatSize(volume.VolumeBlockSize),
+			formatBool(volume.IsSparse()),
+			volume.CompressionMode,
+			volume.SyncMode,
+			origin,
+		})
+	}
+	return rows
+}
+
+func newVolumeCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "volume",
+		Aliases: []string{"vol"},
+		Short:   "Manage volumes and volumeGroups",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list <volumeGroup>",
+		Short: "List volumes of volumeGroup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0] + "/")
+			if err != nil {
+				return err
+			}
+			volumes, err := p.GetVolumes(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(volumes, volumeHeaders, volumeRows(volumes))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <path>",
+		Short: "Show volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			volume, err := p.GetVolume(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(volume, volumeHeaders, volumeRows([]ns.Volume{volume}))
+		},
+	})
+
+	var size, blockSize, reservation sizeFlag
+	var ensureParams ns.EnsureVolumeParams
+	var writebackCacheDisabled bool
+	create := &cobra.Command{
+		Use:   "create <path>",
+		Short: "Create volume, existing volume is updated to match the options",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			params := ensureParams
+			params.Path = args[0]
+			params.VolumeSize = int64(size)
+			params.VolumeBlockSize = int64(blockSize)
+			if cmd.Flags().Changed("reservation") {
+				r := int64(reservation)
+				params.ReferencedReservationSize = &r
+			}
+			if cmd.Flags().Changed("writeback-cache-disabled") {
+				params.WritebackCacheDisabled = &writebackCacheDisabled
+			}
+			volume, _, err := p.EnsureVolume(params)
+			if err != nil {
+				return err
+			}
+			return a.print(volume, volumeHeaders, volumeRows([]ns.Volume{volume}))
+		},
+	}
+	create.Flags().Var(&size, "size", "volume size, e.g. 10G")
+	create.Flags().Var(&blockSize, "block-size", "volume block size, cannot be changed after creation")
+	create.Flags().Var(&reservation, "reservation", "referenced reservation size")
+	create.Flags().BoolVar(&ensureParams.SparseVolume, "sparse", false, "create thin provisioned volume")
+	create.Flags().StringVar(&ensureParams.CompressionMode, "compression", "", "compression mode: off, on, lz4, gzip, etc.")
+	create.Flags().StringVar(&ensureParams.DedupMode, "dedup", "", "deduplication mode: off, on, verify, etc.")
+	create.Flags().StringVar(&ensureParams.SyncMode, "sync", "", "sync mode: standard, always or disabled")
+	create.Flags().BoolVar(&writebackCacheDisabled, "writeback-cache-disabled", false, "disable writeback cache")
+	cmd.AddCommand(create)
+
+	var resizeSize sizeFlag
+	var resizeParams ns.ResizeVolumeParams
+	resize := &cobra.Command{
+		Use:   "resize <path>",
+		Short: "Resize volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			params := resizeParams
+			params.VolumeSize = int64(resizeSize)
+			result, err := p.ResizeVolume(args[0], params)
+			if err != nil {
+				return err
+			}
+			return a.print(result, []string{"OLD SIZE", "NEW SIZE"}, [][]string{{
+				formatSize(result.OldSize),
+				formatSize(result.NewSize),
+			}})
+		},
+	}
+	resize.Flags().Var(&resizeSize, "size", "new volume size, e.g. 20G")
+	resize.MarkFlagRequired("size")
+	resize.Flags().BoolVar(&resizeParams.AllowShrink, "allow-shrink", false, "allow to shrink volume, may corrupt data")
+	resize.Flags().Float64Var(&resizeParams.MaxOvercommitRatio, "max-overcommit-ratio", 0, "max pool overcommit ratio")
+	cmd.AddCommand(resize)
+
+	var destroyParams ns.DestroyVolumeParams
+	destroy := &cobra.Command{
+		Use:   "destroy <path>",
+		Short: "Destroy volume",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0])
+			if err != nil {
+				return err
+			}
+			result, err := p.DestroyVolume(args[0], destroyParams)
+			if err != nil {
+				return err
+			}
+			return a.print(result, []string{"VOLUME", "LUN MAPPINGS REMOVED", "LOGICAL UNITS REMOVED"}, [][]string{{
+				args[0],
+				strconv.Itoa(len(result.RemovedLunMappings)),
+				strconv.Itoa(len(result.RemovedLogicalUnits)),
+			}})
+		},
+	}
+	destroy.Flags().BoolVar(&destroyParams.DestroySnapshots, "destroy-snapshots", false, "destroy volume snapshots")
+	destroy.Flags().BoolVar(
+		&destroyParams.PromoteMostRecentCloneIfExists, "promote-clone", false,
+		"promote the most recent snapshot clone to keep dependent clones",
+	)
+	destroy.Flags().BoolVar(&destroyParams.DestroyLunMappings, "destroy-lun-mappings", false, "remove LUN mappings")
+	destroy.Flags().BoolVar(&destroyParams.DestroyLogicalUnit, "destroy-logical-unit", false, "remove logical unit")
+	destroy.Flags().BoolVar(&destroyParams.Force, "force", false, "remove SAN exposure even if initiators are connected")
+	cmd.AddCommand(destroy)
+
+	cmd.AddCommand(newVolumeGroupCommand(a))
+
+	return cmd
+}
+
+func newVolumeGroupCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "group",
+		Aliases: []string{"vg"},
+		Short:   "Manage volumeGroups",
+	}
+
+	headers := []string{"PATH", "USED", "AVAILABLE", "BLOCKSIZE", "COMPRESSION"}
+	rows := func(volumeGroups []ns.VolumeGroup) [][]string {
+		rows := [][]string{}
+		for _, vg := range volumeGroups {
+			rows = append(rows, []string{
+				vg.Path,
+				formatSize(vg.BytesUsed),
+				formatSize(vg.BytesAvailable),
+				formatSize(vg.VolumeBlockSize),
+				vg.CompressionMode,
+			})
+		}
+		return rows
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list <parent>",
+		Short: "List volumeGroups of a filesystem",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(args[0])
+			if err != nil {
+				return err
+			}
+			volumeGroups, err := p.GetVolumeGroups(args[0])
+			if err != nil {
+				return err
+			}
+			return a.print(volumeGroups, headers, rows(volumeGroups))
+		},
+	})
+
+	var blockSize sizeFlag
+	var createParams ns.CreateVolumeGroupParams
+	create := &cobra.Command{
+		Use:   "create <path>",
+		Short: "Create volumeGroup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.provider(filepath.Dir(args[0]))
+			if err != nil {
+				return err
+			}
+			params := createParams
+			params.Path = args[0]
+			params.VolumeBlockSize = int64(blockSize)
+			volumeGroup, err := p.CreateVolumeGroup(params)
+			if err != nil {
+				return err
+			}
+			return a.print(volumeGroup, headers, rows([]ns.VolumeGroup{volumeGroup}))
+		},
+	}
+	create.Flags().Var(&blockSize, "block-size", "default block size of volumes")
+	create.Flags().StringVar(&createParams.CompressionMode, "compression", "", "default compression mode of volumes")
+	cmd.AddCommand(create)
+
+	var destroyParams ns.DestroyVolumeGroupParams
+	destroy := &cobra.Command{
+		Use:   "destroy <path>",
+		Short: "Destroy volumeGroup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := a.volumeProvider(args[0] + "/")
+			if err != nil {
+				return err
+			}
+			if err := p.DestroyVolumeGroup(args[0], destroyParams); err != nil {
+				return err
+			}
+			return a.printDone("volumeGroup '%s' destroyed", args[0])
+		},
+	}
+	destroy.Flags().BoolVar(&destroyParams.Recursive, "recursive", false, "destroy volumes of the volumeGroup")
+	destroy.Flags().BoolVar(&destroyParams.DestroySnapshots, "destroy-snapshots", false, "destroy snapshots")
+	destroy.Flags().BoolVar(
+		&destroyParams.PromoteMostRecentCloneIfExists, "promote-clone", false,
+		"promote the most recent snapshot clone to keep dependent clones",
+	)
+	cmd.AddCommand(destroy)
+
+	return cmd
+}
@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-nexentastor/pkg/ns"
+	"go-nexentastor/pkg/rest"
+)
+
+// ClientMetrics - ns.Hooks implementation collecting client side request metrics,
+// pass it as ProviderArgs.Hooks or ResolverArgs.Hooks and register as prometheus.Collector
+type ClientMetrics struct {
+	ns.NoopHooks
+
+	requestDuration *prometheus.HistogramVec
+	nefErrors       *prometheus.CounterVec
+	logins          *prometheus.CounterVec
+	jobWaitDuration *prometheus.HistogramVec
+}
+
+// NewClientMetrics creates ClientMetrics
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "client",
+			Name:      "request_duration_seconds",
+			Help:      "NexentaStor API request latency by method and path template",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "path"}),
+		nefErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "client",
+			Name:      "nef_errors_total",
+			Help:      "NexentaStor API error responses by NEF error code",
+		}, []string{"method", "path", "code"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "client",
+			Name:      "logins_total",
+			Help:      "NexentaStor API login attempts by result",
+		}, []string{"result"}),
+		jobWaitDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "client",
+			Name:      "job_wait_duration_seconds",
+			Help:      "Time spent waiting for NexentaStor async jobs by result",
+			Buckets:   []float64{1, 3, 5, 10, 20, 30, 45, 60, 90},
+		}, []string{"result"}),
+	}
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObserveRequest records request duration, implements rest.Hooks
+func (m *ClientMetrics) ObserveRequest(info rest.RequestInfo, statusCode int, duration time.Duration, err error) {
+	m.requestDuration.WithLabelValues(info.Method, info.PathTemplate).Observe(duration.Seconds())
+}
+
+// ObserveNefError counts NEF error codes, implements ns.Hooks
+func (m *ClientMetrics) ObserveNefError(method, pathTemplate, code string) {
+	m.nefErrors.WithLabelValues(method, pathTemplate, code).Inc()
+}
+
+// ObserveLogin counts login attempts, implements ns.Hooks
+func (m *ClientMetrics) ObserveLogin(err error) {
+	m.logins.WithLabelValues(resultLabel(err)).Inc()
+}
+
+// ObserveJobWait records async job wait duration, implements ns.Hooks
+func (m *ClientMetrics) ObserveJobWait(duration time.Duration, err error) {
+	m.jobWaitDuration.WithLabelValues(resultLabel(err)).Observe(duration.Seconds())
+}
+
+// Describe implements prometheus.Collector
+func (m *ClientMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requestDuration.Describe(ch)
+	m.nefErrors.Describe(ch)
+	m.logins.Describe(ch)
+	m.jobWaitDuration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *ClientMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requestDuration.Collect(ch)
+	m.nefErrors.Collect(ch)
+	m.logins.Collect(ch)
+	m.jobWaitDuration.Collect(ch)
+}
@@ -0,0 +1,447 @@
+// Package exporter exposes NexentaStor metrics in Prometheus format
+//
+// Example:
+//
+//	nsResolver, err := ns.NewResolver(ns.ResolverArgs{...})
+//	e, err := exporter.NewExporter(exporter.ExporterArgs{Resolver: nsResolver, Timeout: 10 * time.Second})
+//	http.Handle("/metrics", e.Handler())
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/ns"
+)
+
+const (
+	namespace      = "nexentastor"
+	defaultTimeout = 10 * time.Second
+)
+
+// licenseExpiresLayouts - supported formats of NS license expiration date
+var licenseExpiresLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// Exporter - prometheus.Collector reporting capacity, license, RSF and LUN mapping metrics of NexentaStor nodes,
+// nodes are scraped in parallel, a node not responded within the timeout is reported as down.
+// At most one scrape per node runs at a time: a scrape exceeded the timeout keeps running in background,
+// and next collections wait for it instead of starting a new one, so a hung node doesn't pile up requests.
+type Exporter struct {
+	nodes   []ns.ProviderInterface
+	parents []string
+	timeout time.Duration
+	log     logger.Logger
+
+	descs map[string]*prometheus.Desc
+
+	// running scrapes by node index
+	scrapesMu sync.Mutex
+	scrapes   map[int]*nodeScrape
+}
+
+// nodeScrape - running node scrape, metrics are set when done is closed
+type nodeScrape struct {
+	done    chan struct{}
+	metrics []prometheus.Metric
+}
+
+// ExporterArgs - params to create Exporter, Resolver or Provider is required
+type ExporterArgs struct {
+	// all resolver nodes are scraped
+	Resolver *ns.Resolver
+	// single node to scrape if Resolver is not set
+	Provider ns.ProviderInterface
+	// max time to scrape a node, 10s by default
+	Timeout time.Duration
+	// filesystems to report capacity of all nested filesystems and volumes, all pools by default
+	Parents []string
+	// logger, messages are discarded if not set
+	Log logger.Logger
+}
+
+// NewExporter creates Exporter
+func NewExporter(args ExporterArgs) (*Exporter, error) {
+	var nodes []ns.ProviderInterface
+	if args.Resolver != nil {
+		nodes = args.Resolver.Nodes
+	} else if args.Provider != nil {
+		nodes = []ns.ProviderInterface{args.Provider}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("Exporter requires Resolver or Provider with at least one node, got: %+v", args)
+	}
+
+	timeout := args.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Exporter{
+		nodes:   nodes,
+		parents: args.Parents,
+		timeout: timeout,
+		log:     logger.OrNoop(args.Log).WithField("cmp", "exporter"),
+		descs:   newDescs(),
+		scrapes: map[int]*nodeScrape{},
+	}, nil
+}
+
+func newDescs() map[string]*prometheus.Desc {
+	descs := map[string]*prometheus.Desc{}
+	add := func(name, help string, labels ...string) {
+		descs[name] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", name),
+			help,
+			append([]string{"node"}, labels...),
+			nil,
+		)
+	}
+
+	add("up", "1 if the node has been scraped within the timeout")
+	add("scrape_duration_seconds", "Time spent to scrape the node")
+	add("collector_success", "1 if the collector has succeeded", "collector")
+
+	add("pool_size_bytes", "Pool size", "pool")
+	add("pool_allocated_bytes", "Pool allocated space", "pool")
+	add("pool_free_bytes", "Pool free space", "pool")
+	add("pool_health", "Pool health, value is always 1", "pool", "health")
+
+	add("filesystem_used_bytes", "Filesystem used space", "path")
+	add("filesystem_available_bytes", "Filesystem available space", "path")
+	add("filesystem_quota_bytes", "Filesystem referenced quota, 0 if not set", "path")
+
+	add("volume_size_bytes", "Volume size", "path")
+	add("volume_used_bytes", "Volume used space", "path")
+
+	add("license_valid", "1 if the license is valid")
+	add("license_expiry_timestamp_seconds", "License expiration time")
+
+	add("rsf_health", "RSF cluster component health, value is always 1", "cluster", "component", "health")
+	add("rsf_service_status", "RSF service status on cluster node, value is always 1",
+		"cluster", "service", "service_node", "status")
+
+	add("lun_mappings", "Number of LUN mappings", "host_group", "target_group")
+
+	return descs
+}
+
+// Describe sends metric descriptors, implements prometheus.Collector
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range e.descs {
+		ch <- desc
+	}
+}
+
+// Collect scrapes all nodes in parallel, implements prometheus.Collector
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for i := range e.nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, metric := range e.scrapeWithTimeout(i) {
+				ch <- metric
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Handler returns HTTP handler of Prometheus text endpoint with exporter metrics only
+func (e *Exporter) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promhttpLogger{e.log}})
+}
+
+// scrapeWithTimeout returns node metrics or only "up" metric set to 0 if the node hasn't responded in time,
+// the late scrape keeps running and is reused by the next call, its results are returned if it's done by then
+func (e *Exporter) scrapeWithTimeout(i int) []prometheus.Metric {
+	startTime := time.Now()
+	nodeName := fmt.Sprint(e.nodes[i])
+	scrape := e.startScrape(i)
+
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-scrape.done:
+		return append(
+			append([]prometheus.Metric{}, scrape.metrics...),
+			e.metric("up", 1, nodeName),
+			e.metric("scrape_duration_seconds", time.Since(startTime).Seconds(), nodeName),
+		)
+	case <-timer.C:
+		e.log.Warnf("node '%s' scrape timeout exceeded (%s), the scrape is left running", nodeName, e.timeout)
+		return []prometheus.Metric{
+			e.metric("up", 0, nodeName),
+			e.metric("scrape_duration_seconds", time.Since(startTime).Seconds(), nodeName),
+		}
+	}
+}
+
+// startScrape starts node scrape or returns the running one
+func (e *Exporter) startScrape(i int) *nodeScrape {
+	e.scrapesMu.Lock()
+	defer e.scrapesMu.Unlock()
+
+	if scrape, ok := e.scrapes[i]; ok {
+		e.log.Debugf("node '%s' is still being scraped, waiting for the running scrape", e.nodes[i])
+		return scrape
+	}
+
+	scrape := &nodeScrape{done: make(chan struct{})}
+	e.scrapes[i] = scrape
+	go func() {
+		scrape.metrics = e.scrape(e.nodes[i])
+		e.scrapesMu.Lock()
+		delete(e.scrapes, i)
+		e.scrapesMu.Unlock()
+		close(scrape.done)
+	}()
+
+	return scrape
+}
+
+// promhttpLogger - promhttp.Logger adapter writing errors to exporter logger
+type promhttpLogger struct {
+	log logger.Logger
+}
+
+func (l promhttpLogger) Println(v ...interface{}) {
+	l.log.Errorf("%s", fmt.Sprint(v...))
+}
+
+func (e *Exporter) metric(name string, value float64, labels ...string) prometheus.Metric {
+	return prometheus.MustNewConstMetric(e.descs[name], prometheus.GaugeValue, value, labels...)
+}
+
+// collector - collects a group of node metrics
+type collector struct {
+	name    string
+	collect func(node *scrapeNode, add func(name string, value float64, labels ...string)) error
+}
+
+func (e *Exporter) collectors() []collector {
+	return []collector{
+		{"pools", e.collectPools},
+		{"datasets", e.collectDatasets},
+		{"license", e.collectLicense},
+		{"rsf", e.collectRSF},
+		{"lun_mappings", e.collectLunMappings},
+	}
+}
+
+// scrapeNode - node being scraped, keeps data shared by collectors so it's requested once per scrape
+type scrapeNode struct {
+	ns.ProviderInterface
+
+	pools       []ns.Pool
+	poolsErr    error
+	poolsLoaded bool
+}
+
+// getPools returns node pools, pools are requested by the first call only
+func (n *scrapeNode) getPools() ([]ns.Pool, error) {
+	if !n.poolsLoaded {
+		n.pools, n.poolsErr = n.GetPools()
+		n.poolsLoaded = true
+	}
+	return n.pools, n.poolsErr
+}
+
+// scrape runs all collectors for the node, failed collector doesn't stop others
+func (e *Exporter) scrape(provider ns.ProviderInterface) []prometheus.Metric {
+	nodeName := fmt.Sprint(provider)
+	l := e.log.WithField("node", nodeName)
+	node := &scrapeNode{ProviderInterface: provider}
+
+	metrics := []prometheus.Metric{}
+	add := func(name string, value float64, labels ...string) {
+		metrics = append(metrics, e.metric(name, value, append([]string{nodeName}, labels...)...))
+	}
+
+	for _, c := range e.collectors() {
+		success := 1.0
+		if err := c.collect(node, add); err != nil {
+			l.Errorf("collector '%s' failed: %s", c.name, err)
+			success = 0
+		}
+		add("collector_success", success, c.name)
+	}
+
+	return metrics
+}
+
+func (e *Exporter) collectPools(node *scrapeNode, add func(string, float64, ...string)) error {
+	pools, err := node.getPools()
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools {
+		add("pool_size_bytes", float64(pool.Size), pool.Name)
+		add("pool_allocated_bytes", float64(pool.Allocated), pool.Name)
+		add("pool_free_bytes", float64(pool.Free), pool.Name)
+		add("pool_health", 1, pool.Name, pool.Health)
+	}
+	return nil
+}
+
+// collectDatasets reports all filesystems and volumes under the parents, nested ones included,
+// a dataset under several overlapping parents is reported once
+func (e *Exporter) collectDatasets(node *scrapeNode, add func(string, float64, ...string)) error {
+	parents := e.parents
+	if len(parents) == 0 {
+		pools, err := node.getPools()
+		if err != nil {
+			return err
+		}
+		for _, pool := range pools {
+			parents = append(parents, pool.Name)
+		}
+	}
+
+	w := datasetsWalk{node: node, add: add, walked: map[string]bool{}, seen: map[string]bool{}}
+	for _, parent := range parents {
+		if err := w.walk(parent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// datasetsWalk - state of datasets tree walk
+type datasetsWalk struct {
+	node *scrapeNode
+	add  func(string, float64, ...string)
+	// filesystems which children are already listed
+	walked map[string]bool
+	// paths of already reported datasets
+	seen map[string]bool
+}
+
+// walk reports child filesystems and volumes of the filesystem and walks child filesystems,
+// a filesystem not found is skipped: it belongs to another node or has been destroyed during the walk
+func (w *datasetsWalk) walk(parent string) error {
+	if w.walked[parent] {
+		return nil
+	}
+	w.walked[parent] = true
+
+	filesystems, err := w.node.GetFilesystems(parent)
+	if ns.IsNotExistNefError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	volumeGroups, err := w.node.GetVolumeGroups(parent)
+	if ns.IsNotExistNefError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, volumeGroup := range volumeGroups {
+		volumes, err := w.node.GetVolumes(volumeGroup.Path)
+		if ns.IsNotExistNefError(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, volume := range volumes {
+			if w.seen[volume.Path] {
+				continue
+			}
+			w.seen[volume.Path] = true
+			w.add("volume_size_bytes", float64(volume.VolumeSize), volume.Path)
+			w.add("volume_used_bytes", float64(volume.BytesUsed), volume.Path)
+		}
+	}
+
+	for _, filesystem := range filesystems {
+		if !w.seen[filesystem.Path] {
+			w.seen[filesystem.Path] = true
+			w.add("filesystem_used_bytes", float64(filesystem.BytesUsed), filesystem.Path)
+			w.add("filesystem_available_bytes", float64(filesystem.BytesAvailable), filesystem.Path)
+			w.add("filesystem_quota_bytes", float64(filesystem.ReferencedQuotaSize), filesystem.Path)
+		}
+		if err := w.walk(filesystem.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Exporter) collectLicense(node *scrapeNode, add func(string, float64, ...string)) error {
+	license, err := node.GetLicense()
+	if err != nil {
+		return err
+	}
+
+	valid := 0.0
+	if license.Valid {
+		valid = 1
+	}
+	add("license_valid", valid)
+
+	if license.Expires == "" {
+		return nil
+	}
+	for _, layout := range licenseExpiresLayouts {
+		if expires, err := time.Parse(layout, license.Expires); err == nil {
+			add("license_expiry_timestamp_seconds", float64(expires.Unix()))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Cannot parse license expiration date '%s'", license.Expires)
+}
+
+func (e *Exporter) collectRSF(node *scrapeNode, add func(string, float64, ...string)) error {
+	clusters, err := node.GetRSFClusters()
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		for component, health := range map[string]string{
+			"cluster":            cluster.Health.ClusterHealth,
+			"nodes":              cluster.Health.NodesHealth,
+			"services":           cluster.Health.ServicesHealth,
+			"network_heartbeats": cluster.Health.NetworkHeartbeatsHealth,
+		} {
+			add("rsf_health", 1, cluster.Name, component, health)
+		}
+		for _, service := range cluster.Services {
+			for _, status := range service.Status {
+				add("rsf_service_status", 1, cluster.Name, service.ServiceName, status.Node, status.Status)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) collectLunMappings(node *scrapeNode, add func(string, float64, ...string)) error {
+	lunMappings, err := node.GetAllLunMappings()
+	if err != nil {
+		return err
+	}
+
+	type groups struct{ hostGroup, targetGroup string }
+	counts := map[groups]int{}
+	for _, lunMapping := range lunMappings {
+		counts[groups{lunMapping.HostGroup, lunMapping.TargetGroup}]++
+	}
+	for g, count := range counts {
+		add("lun_mappings", float64(count), g.hostGroup, g.targetGroup)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package logger defines the logging interface used by rest, ns and exporter packages,
+// with adapters for logrus and log/slog.
+//
+// Adapters pass field names as is, so the same "cmp", "ns", "func", "req" and "reqID" fields
+// are reported by any logging library, e.g.:
+//
+//	nsProvider, err := ns.NewProvider(ns.ProviderArgs{
+//		Address: "https://10.3.199.252:8443",
+//		Log:     logger.NewSlogLogger(slog.Default()),
+//	})
+package logger
+
+// Fields - set of log fields
+type Fields map[string]interface{}
+
+// Logger - leveled logger with structured fields
+type Logger interface {
+	// WithField returns a logger adding the field to all messages
+	WithField(key string, value interface{}) Logger
+	// WithFields returns a logger adding the fields to all messages
+	WithFields(fields Fields) Logger
+
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NoopLogger - logger discarding all messages
+type NoopLogger struct{}
+
+// NewNoopLogger creates logger discarding all messages
+func NewNoopLogger() Logger {
+	return NoopLogger{}
+}
+
+// WithField returns the same logger
+func (l NoopLogger) WithField(key string, value interface{}) Logger {
+	return l
+}
+
+// WithFields returns the same logger
+func (l NoopLogger) WithFields(fields Fields) Logger {
+	return l
+}
+
+// Debugf does nothing
+func (NoopLogger) Debugf(format string, args ...interface{}) {}
+
+// Infof does nothing
+func (NoopLogger) Infof(format string, args ...interface{}) {}
+
+// Warnf does nothing
+func (NoopLogger) Warnf(format string, args ...interface{}) {}
+
+// Errorf does nothing
+func (NoopLogger) Errorf(format string, args ...interface{}) {}
+
+// OrNoop returns NoopLogger if l is nil
+func OrNoop(l Logger) Logger {
+	if l == nil {
+		return NoopLogger{}
+	}
+	return l
+}
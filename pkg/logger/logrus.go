@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// logrusLogger - Logger adapter for logrus
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogrusLogger creates Logger writing to logrus entry, NoopLogger is returned for nil entry.
+// Use logrus.NewEntry() to wrap *logrus.Logger.
+func NewLogrusLogger(entry *logrus.Entry) Logger {
+	if entry == nil {
+		return NoopLogger{}
+	}
+	return logrusLogger{entry}
+}
+
+func (l logrusLogger) WithField(key string, value interface{}) Logger {
+	return logrusLogger{l.entry.WithField(key, value)}
+}
+
+func (l logrusLogger) WithFields(fields Fields) Logger {
+	return logrusLogger{l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l logrusLogger) Debugf(format string, args ...interface{}) {
+	l.entry.Debugf(format, args...)
+}
+
+func (l logrusLogger) Infof(format string, args ...interface{}) {
+	l.entry.Infof(format, args...)
+}
+
+func (l logrusLogger) Warnf(format string, args ...interface{}) {
+	l.entry.Warnf(format, args...)
+}
+
+func (l logrusLogger) Errorf(format string, args ...interface{}) {
+	l.entry.Errorf(format, args...)
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// slogLogger - Logger adapter for log/slog
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates Logger writing to slog logger, NoopLogger is returned for nil logger
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		return NoopLogger{}
+	}
+	return slogLogger{logger}
+}
+
+func (l slogLogger) WithField(key string, value interface{}) Logger {
+	return slogLogger{l.logger.With(key, value)}
+}
+
+// WithFields adds fields sorted by name to keep the output stable
+func (l slogLogger) WithFields(fields Fields) Logger {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, len(fields))
+	for _, key := range keys {
+		args = append(args, slog.Any(key, fields[key]))
+	}
+
+	return slogLogger{l.logger.With(args...)}
+}
+
+// log formats the message only if the level is enabled
+func (l slogLogger) log(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, format, args...)
+}
+
+func (l slogLogger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, format, args...)
+}
+
+func (l slogLogger) Warnf(format string, args ...interface{}) {
+	l.log(slog.LevelWarn, format, args...)
+}
+
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args...)
+}
@@ -0,0 +1,244 @@
+package ns
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AnalyticsMetric - performance metric collected by NS analytics
+type AnalyticsMetric string
+
+const (
+	// AnalyticsMetricIOPS - I/O operations per second
+	AnalyticsMetricIOPS AnalyticsMetric = "iops"
+	// AnalyticsMetricThroughput - bytes per second
+	AnalyticsMetricThroughput AnalyticsMetric = "throughput"
+	// AnalyticsMetricLatency - average I/O latency in microseconds
+	AnalyticsMetricLatency AnalyticsMetric = "latency"
+)
+
+var analyticsMetricUnits = map[AnalyticsMetric]string{
+	AnalyticsMetricIOPS:       "ops/s",
+	AnalyticsMetricThroughput: "bytes/s",
+	AnalyticsMetricLatency:    "us",
+}
+
+// AnalyticsEntityType - type of object the metric is collected for
+type AnalyticsEntityType string
+
+const (
+	// AnalyticsEntityPool - pool name, e.g. "pool1"
+	AnalyticsEntityPool AnalyticsEntityType = "pool"
+	// AnalyticsEntityDataset - filesystem path, e.g. "pool1/tenant/fs1"
+	AnalyticsEntityDataset AnalyticsEntityType = "dataset"
+	// AnalyticsEntityVolume - volume path, e.g. "pool1/vg/vol1"
+	AnalyticsEntityVolume AnalyticsEntityType = "volume"
+	// AnalyticsEntityProtocol - one of AnalyticsProtocol* values
+	AnalyticsEntityProtocol AnalyticsEntityType = "protocol"
+)
+
+const (
+	// AnalyticsProtocolNFS - NFS protocol entity
+	AnalyticsProtocolNFS = "nfs"
+	// AnalyticsProtocolSMB - SMB protocol entity
+	AnalyticsProtocolSMB = "smb"
+	// AnalyticsProtocolISCSI - iSCSI protocol entity
+	AnalyticsProtocolISCSI = "iscsi"
+)
+
+// defaultAnalyticsResolution - resolution used if query doesn't specify it
+const defaultAnalyticsResolution = time.Minute
+
+// maxAnalyticsPoints - max number of points in a series NS may return for a single query
+const maxAnalyticsPoints = 10000
+
+// AnalyticsQuery - params to get metric time series
+type AnalyticsQuery struct {
+	Metric     AnalyticsMetric
+	EntityType AnalyticsEntityType
+	// pool name, dataset or volume path, or protocol name
+	Entity string
+	// time range, End is current time if not set
+	Start time.Time
+	End   time.Time
+	// interval between points, one minute if not set, rounded to seconds
+	Resolution time.Duration
+}
+
+func (query *AnalyticsQuery) validate() error {
+	if _, ok := analyticsMetricUnits[query.Metric]; !ok {
+		return fmt.Errorf("Unknown analytics metric '%s'", query.Metric)
+	}
+
+	switch query.EntityType {
+	case AnalyticsEntityPool, AnalyticsEntityDataset, AnalyticsEntityVolume:
+		if query.Entity == "" {
+			return fmt.Errorf("Analytics %s name is required", query.EntityType)
+		}
+	case AnalyticsEntityProtocol:
+		switch query.Entity {
+		case AnalyticsProtocolNFS, AnalyticsProtocolSMB, AnalyticsProtocolISCSI:
+		default:
+			return fmt.Errorf("Unknown analytics protocol '%s'", query.Entity)
+		}
+	default:
+		return fmt.Errorf("Unknown analytics entity type '%s'", query.EntityType)
+	}
+
+	if query.End.IsZero() {
+		query.End = time.Now()
+	}
+	if query.Resolution == 0 {
+		query.Resolution = defaultAnalyticsResolution
+	}
+	query.Resolution = query.Resolution.Round(time.Second)
+
+	if query.Start.IsZero() || !query.Start.Before(query.End) {
+		return fmt.Errorf("Analytics time range start must be before its end, got %s - %s", query.Start, query.End)
+	} else if query.Resolution < time.Second {
+		return fmt.Errorf("Analytics resolution must be at least 1s, got %s", query.Resolution)
+	} else if points := query.End.Sub(query.Start) / query.Resolution; points > maxAnalyticsPoints {
+		return fmt.Errorf(
+			"Analytics query requests %d points, max is %d, increase resolution or reduce time range",
+			points,
+			maxAnalyticsPoints,
+		)
+	}
+
+	return nil
+}
+
+// AnalyticsPoint - metric values at a point of time
+type AnalyticsPoint struct {
+	Time  time.Time `json:"time"`
+	Read  float64   `json:"read"`
+	Write float64   `json:"write"`
+}
+
+// Total returns sum of read and write values, for latency use AnalyticsSeries.Average() instead
+func (point AnalyticsPoint) Total() float64 {
+	return point.Read + point.Write
+}
+
+// AnalyticsSeries - metric time series of an entity
+type AnalyticsSeries struct {
+	Metric     AnalyticsMetric     `json:"metric"`
+	EntityType AnalyticsEntityType `json:"entityType"`
+	Entity     string              `json:"entity"`
+	// unit of point values: "ops/s", "bytes/s" or "us"
+	Unit       string           `json:"unit"`
+	Resolution time.Duration    `json:"resolution"`
+	Points     []AnalyticsPoint `json:"points"`
+}
+
+// Average returns average read and write values of the series
+func (series AnalyticsSeries) Average() AnalyticsPoint {
+	average := AnalyticsPoint{}
+	if len(series.Points) == 0 {
+		return average
+	}
+	for _, point := range series.Points {
+		average.Read += point.Read
+		average.Write += point.Write
+	}
+	average.Read /= float64(len(series.Points))
+	average.Write /= float64(len(series.Points))
+	return average
+}
+
+// Max returns the point with the highest total value
+func (series AnalyticsSeries) Max() AnalyticsPoint {
+	max := AnalyticsPoint{}
+	for i, point := range series.Points {
+		if i == 0 || point.Total() > max.Total() {
+			max = point
+		}
+	}
+	return max
+}
+
+// Sum returns total amount over the time range for rate metrics (operations or bytes),
+// each point value is multiplied by series resolution
+func (series AnalyticsSeries) Sum() AnalyticsPoint {
+	sum := AnalyticsPoint{}
+	seconds := series.Resolution.Seconds()
+	for _, point := range series.Points {
+		sum.Read += point.Read * seconds
+		sum.Write += point.Write * seconds
+	}
+	return sum
+}
+
+type nefAnalyticsSeriesResponse struct {
+	Data []struct {
+		// unix time in seconds
+		Timestamp int64   `json:"timestamp"`
+		Read      float64 `json:"read"`
+		Write     float64 `json:"write"`
+	} `json:"data"`
+}
+
+// GetAnalytics returns metric time series of pool, dataset, volume or protocol
+func (p *Provider) GetAnalytics(query AnalyticsQuery) (AnalyticsSeries, error) {
+	if err := query.validate(); err != nil {
+		return AnalyticsSeries{}, &NefError{Code: "EBADARG", Err: err}
+	}
+
+	uri := p.RestClient.BuildURI("analytics/series", map[string]string{
+		"metric":     string(query.Metric),
+		"entityType": string(query.EntityType),
+		"entity":     query.Entity,
+		"from":       strconv.FormatInt(query.Start.Unix(), 10),
+		"to":         strconv.FormatInt(query.End.Unix(), 10),
+		"resolution": strconv.FormatInt(int64(query.Resolution.Seconds()), 10),
+	})
+
+	response := nefAnalyticsSeriesResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return AnalyticsSeries{}, err
+	}
+
+	series := AnalyticsSeries{
+		Metric:     query.Metric,
+		EntityType: query.EntityType,
+		Entity:     query.Entity,
+		Unit:       analyticsMetricUnits[query.Metric],
+		Resolution: query.Resolution,
+		Points:     []AnalyticsPoint{},
+	}
+	for _, point := range response.Data {
+		series.Points = append(series.Points, AnalyticsPoint{
+			Time:  time.Unix(point.Timestamp, 0),
+			Read:  point.Read,
+			Write: point.Write,
+		})
+	}
+
+	return series, nil
+}
+
+// GetDatasetsAnalytics returns metric time series of each child dataset of the parent filesystem,
+// useful to find datasets with the highest load
+func (p *Provider) GetDatasetsAnalytics(parent string, query AnalyticsQuery) ([]AnalyticsSeries, error) {
+	filesystems, err := p.GetFilesystems(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []AnalyticsSeries{}
+	for _, filesystem := range filesystems {
+		datasetQuery := query
+		datasetQuery.EntityType = AnalyticsEntityDataset
+		datasetQuery.Entity = filesystem.Path
+		series, err := p.GetAnalytics(datasetQuery)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get '%s' analytics: %s", filesystem.Path, err)
+		}
+		list = append(list, series)
+	}
+
+	return list, nil
+}
@@ -1,121 +1,145 @@
 package ns
 
 import (
-    "encoding/json"
-    "fmt"
-    "net/http"
-    "net/url"
-    "strconv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // NexentaStor filesystem list limit (<=)
 // TODO change this limit base on specified NS version
 const nsFilesystemListLimit = 100
 
+// default iSCSI portal port
+const defaultISCSIPort = 3260
+
+// CHAP secret length limits
+const (
+	minChapSecretLength = 12
+	maxChapSecretLength = 16
+)
+
+// NexentaStor volumeGroup fields to request
+const nsVolumeGroupFields = "path,bytesAvailable,bytesUsed,volumeBlockSize,compressionMode"
+
+// NexentaStor volume fields to request
+const nsVolumeFields = "path,bytesAvailable,bytesUsed,volumeSize,volumeBlockSize,compressionMode,dedupMode," +
+	"syncMode,writebackCacheDisabled,referencedReservationSize,creationTime,origin"
+
 // LogIn logs in to NexentaStor API and get auth token
 func (p *Provider) LogIn() error {
-    l := p.Log.WithField("func", "LogIn()")
-
-    data := nefAuthLoginRequest{
-        Username: p.Username,
-        Password: p.Password,
-    }
-
-    p.RestClient.SetAuthToken("")
-    _, bodyBytes, err := p.RestClient.Send(http.MethodPost, "auth/login", data)
-    if err != nil {
-        // try to parse error from rest response
-        nefError := p.parseNefError(bodyBytes, "Login request")
-        if nefError != nil {
-            if IsAuthNefError(nefError) {
-                l.Errorf(
-                    "login to NexentaStor %s failed (username: '%s'), "+
-                        "please make sure to use correct address and password",
-                    p.Address,
-                    p.Username)
-            }
-            return nefError
-        }
-
-        return fmt.Errorf("Login request: failed, response: %s; error: %s", bodyBytes, err)
-    }
-
-    response := nefAuthLoginResponse{}
-    if err := json.Unmarshal(bodyBytes, &response); err != nil {
-        return fmt.Errorf("Login request: cannot unmarshal JSON from: '%s' to '%+v': %s", bodyBytes, response, err)
-    } else if response.Token == "" {
-        return fmt.Errorf("Login request: token not found in response: '%s'", bodyBytes)
-    }
-
-    p.RestClient.SetAuthToken(response.Token)
-    l.Debugf("login token has been updated")
-    return nil
+	err := p.logIn()
+	p.hooks().ObserveLogin(err)
+	return err
+}
+
+func (p *Provider) logIn() error {
+	l := p.Log.WithField("func", "LogIn()")
+
+	data := nefAuthLoginRequest{
+		Username: p.Username,
+		Password: p.Password,
+	}
+
+	p.RestClient.SetAuthToken("")
+	_, bodyBytes, err := p.RestClient.Send(http.MethodPost, "auth/login", data)
+	if err != nil {
+		// try to parse error from rest response
+		nefError := p.parseNefError(bodyBytes, "Login request")
+		if nefError != nil {
+			if IsAuthNefError(nefError) {
+				l.Errorf(
+					"login to NexentaStor %s failed (username: '%s'), "+
+						"please make sure to use correct address and password",
+					p.Address,
+					p.Username)
+			}
+			return nefError
+		}
+
+		return fmt.Errorf("Login request: failed, response: %s; error: %s", bodyBytes, err)
+	}
+
+	response := nefAuthLoginResponse{}
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		return fmt.Errorf("Login request: cannot unmarshal JSON from: '%s' to '%+v': %s", bodyBytes, response, err)
+	} else if response.Token == "" {
+		return fmt.Errorf("Login request: token not found in response: '%s'", bodyBytes)
+	}
+
+	p.RestClient.SetAuthToken(response.Token)
+	l.Debugf("login token has been updated")
+	return nil
 }
 
 // GetLicense returns NexentaStor license
 func (p *Provider) GetLicense() (license License, err error) {
-    err = p.sendRequestWithStruct(http.MethodGet, "settings/license", nil, &license)
-    return license, err
+	err = p.sendRequestWithStruct(http.MethodGet, "settings/license", nil, &license)
+	return license, err
 }
 
 // GetPools returns NexentaStor pools
 func (p *Provider) GetPools() ([]Pool, error) {
-    uri := p.RestClient.BuildURI("storage/pools", map[string]string{
-        "fields": "poolName,health,status",
-    })
+	uri := p.RestClient.BuildURI("storage/pools", map[string]string{
+		"fields": poolFields,
+	})
 
-    response := nefStoragePoolsResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return nil, err
-    }
+	response := nefStoragePoolsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
 
-    return response.Data, nil
+	return response.Data, nil
 }
 
 // GetFilesystemAvailableCapacity returns NexentaStor filesystem available size by its path
 func (p *Provider) GetFilesystemAvailableCapacity(path string) (int64, error) {
-    uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
-        "path":   path,
-        "fields": "bytesAvailable",
-    })
+	uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
+		"path":   path,
+		"fields": "bytesAvailable",
+	})
 
-    response := nefStorageFilesystemsResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return 0, err
-    }
+	response := nefStorageFilesystemsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return 0, err
+	}
 
-    var availableSize int64
-    if len(response.Data) > 0 {
-        availableSize = int64(response.Data[0].BytesAvailable)
-    }
+	var availableSize int64
+	if len(response.Data) > 0 {
+		availableSize = int64(response.Data[0].BytesAvailable)
+	}
 
-    return availableSize, nil
+	return availableSize, nil
 }
 
 // GetFilesystem returns NexentaStor filesystem by its path
 func (p *Provider) GetFilesystem(path string) (filesystem Filesystem, err error) {
-    if path == "" {
-        return filesystem, fmt.Errorf("Filesystem path is empty")
-    }
+	if path == "" {
+		return filesystem, fmt.Errorf("Filesystem path is empty")
+	}
 
-    uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
-        "path":   path,
-        "fields": "path,mountPoint,bytesAvailable,bytesUsed,sharedOverNfs,sharedOverSmb",
-    })
+	uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
+		"path":   path,
+		"fields": "path,mountPoint,bytesAvailable,bytesUsed,sharedOverNfs,sharedOverSmb,referencedQuotaSize",
+	})
 
-    response := nefStorageFilesystemsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return filesystem, err
-    }
+	response := nefStorageFilesystemsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return filesystem, err
+	}
 
-    if len(response.Data) == 0 {
-        return filesystem, &NefError{Code: "ENOENT", Err: fmt.Errorf("Filesystem '%s' not found", path)}
-    }
+	if len(response.Data) == 0 {
+		return filesystem, &NefError{Code: "ENOENT", Err: fmt.Errorf("Filesystem '%s' not found", path)}
+	}
 
-    return response.Data[0], nil
+	return response.Data[0], nil
 }
 
 // GetVolumesWithStartingToken returns volumes by parent volumeGroup after specified starting token
@@ -124,85 +148,85 @@ func (p *Provider) GetFilesystem(path string) (filesystem Filesystem, err error)
 // limit - the maximum count of volumes to return in the list
 // Function may return nextToken if there is more volumes than limit value
 func (p *Provider) GetVolumesWithStartingToken(parent string, startingToken string, limit int) (
-    volumes []Volume,
-    nextToken string,
-    err error,
+	volumes []Volume,
+	nextToken string,
+	err error,
 ) {
-    startingTokenFound := false
-    if startingToken == "" {
-        // if no startingToken set then filesystem list should starts with the first one
-        startingTokenFound = true
-    }
-
-    // if no limit set then all filesystem after startingToken should be in the response
-    noLimit := limit == 0
-
-    // load volumes using slice requests
-    offset := 0
-    lastResultCount := nsFilesystemListLimit
-    for (noLimit || len(volumes) < limit) && lastResultCount >= nsFilesystemListLimit-1 {
-        volumesSlice, err := p.GetVolumesSlice(parent, nsFilesystemListLimit-1, offset)
-        if err != nil {
-            return nil, "", err
-        }
-        for _, fs := range volumesSlice {
-            if startingTokenFound {
-                volumes = append(volumes, fs)
-                if len(volumes) == limit {
-                    nextToken = fs.Path
-                    break
-                }
-            } else if fs.Path == startingToken {
-                startingTokenFound = true
-            }
-        }
-        lastResultCount = len(volumesSlice)
-        offset += lastResultCount
-    }
-
-    return volumes, nextToken, nil
+	startingTokenFound := false
+	if startingToken == "" {
+		// if no startingToken set then filesystem list should starts with the first one
+		startingTokenFound = true
+	}
+
+	// if no limit set then all filesystem after startingToken should be in the response
+	noLimit := limit == 0
+
+	// load volumes using slice requests
+	offset := 0
+	lastResultCount := nsFilesystemListLimit
+	for (noLimit || len(volumes) < limit) && lastResultCount >= nsFilesystemListLimit-1 {
+		volumesSlice, err := p.GetVolumesSlice(parent, nsFilesystemListLimit-1, offset)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, fs := range volumesSlice {
+			if startingTokenFound {
+				volumes = append(volumes, fs)
+				if len(volumes) == limit {
+					nextToken = fs.Path
+					break
+				}
+			} else if fs.Path == startingToken {
+				startingTokenFound = true
+			}
+		}
+		lastResultCount = len(volumesSlice)
+		offset += lastResultCount
+	}
+
+	return volumes, nextToken, nil
 }
 
 // GetVolumes returns all NexentaStor volumes by parent volumeGroup
 func (p *Provider) GetVolumes(parent string) ([]Volume, error) {
-    volumes := []Volume{}
+	volumes := []Volume{}
 
-    offset := 0
-    lastResultCount := nsFilesystemListLimit
-    for lastResultCount >= nsFilesystemListLimit-1 {
-        volumesSlice, err := p.GetVolumesSlice(parent, nsFilesystemListLimit-1, offset)
-        if err != nil {
-            return nil, err
-        }
-        for _, vol := range volumesSlice {
-            volumes = append(volumes, vol)
-        }
-        lastResultCount = len(volumesSlice)
-        offset += lastResultCount
-    }
+	offset := 0
+	lastResultCount := nsFilesystemListLimit
+	for lastResultCount >= nsFilesystemListLimit-1 {
+		volumesSlice, err := p.GetVolumesSlice(parent, nsFilesystemListLimit-1, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, vol := range volumesSlice {
+			volumes = append(volumes, vol)
+		}
+		lastResultCount = len(volumesSlice)
+		offset += lastResultCount
+	}
 
-    return volumes, nil
+	return volumes, nil
 }
 
 // GetFilesystems returns all NexentaStor filesystems by parent filesystem
 func (p *Provider) GetFilesystems(parent string) ([]Filesystem, error) {
-    filesystems := []Filesystem{}
+	filesystems := []Filesystem{}
 
-    offset := 1
-    lastResultCount := nsFilesystemListLimit
-    for lastResultCount >= nsFilesystemListLimit-1 {
-        filesystemsSlice, err := p.GetFilesystemsSlice(parent, nsFilesystemListLimit-1, offset)
-        if err != nil {
-            return nil, err
-        }
-        for _, fs := range filesystemsSlice {
-            filesystems = append(filesystems, fs)
-        }
-        lastResultCount = len(filesystemsSlice)
-        offset += lastResultCount
-    }
+	offset := 1
+	lastResultCount := nsFilesystemListLimit
+	for lastResultCount >= nsFilesystemListLimit-1 {
+		filesystemsSlice, err := p.GetFilesystemsSlice(parent, nsFilesystemListLimit-1, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, fs := range filesystemsSlice {
+			filesystems = append(filesystems, fs)
+		}
+		lastResultCount = len(filesystemsSlice)
+		offset += lastResultCount
+	}
 
-    return filesystems, nil
+	return filesystems, nil
 }
 
 // GetFilesystemsWithStartingToken returns filesystems by parent filesystem after specified starting token
@@ -211,700 +235,1335 @@ func (p *Provider) GetFilesystems(parent string) ([]Filesystem, error) {
 // limit - the maximum count of filesystems to return in the list
 // Function may return nextToken if there is more filesystems than limit value
 func (p *Provider) GetFilesystemsWithStartingToken(parent string, startingToken string, limit int) (
-    filesystems []Filesystem,
-    nextToken string,
-    err error,
+	filesystems []Filesystem,
+	nextToken string,
+	err error,
 ) {
-    startingTokenFound := false
-    if startingToken == "" {
-        // if no startingToken set then filesystem list should starts with the first one
-        startingTokenFound = true
-    }
-
-    // if no limit set then all filesystem after startingToken should be in the response
-    noLimit := limit == 0
-
-    // load filesystems using slice requests
-    offset := 1
-    lastResultCount := nsFilesystemListLimit
-    for (noLimit || len(filesystems) < limit) && lastResultCount >= nsFilesystemListLimit-1 {
-        filesystemsSlice, err := p.GetFilesystemsSlice(parent, nsFilesystemListLimit-1, offset)
-        if err != nil {
-            return nil, "", err
-        }
-        for _, fs := range filesystemsSlice {
-            if startingTokenFound {
-                filesystems = append(filesystems, fs)
-                if len(filesystems) == limit {
-                    nextToken = fs.Path
-                    break
-                }
-            } else if fs.Path == startingToken {
-                startingTokenFound = true
-            }
-        }
-        lastResultCount = len(filesystemsSlice)
-        offset += lastResultCount
-    }
-
-    return filesystems, nextToken, nil
+	startingTokenFound := false
+	if startingToken == "" {
+		// if no startingToken set then filesystem list should starts with the first one
+		startingTokenFound = true
+	}
+
+	// if no limit set then all filesystem after startingToken should be in the response
+	noLimit := limit == 0
+
+	// load filesystems using slice requests
+	offset := 1
+	lastResultCount := nsFilesystemListLimit
+	for (noLimit || len(filesystems) < limit) && lastResultCount >= nsFilesystemListLimit-1 {
+		filesystemsSlice, err := p.GetFilesystemsSlice(parent, nsFilesystemListLimit-1, offset)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, fs := range filesystemsSlice {
+			if startingTokenFound {
+				filesystems = append(filesystems, fs)
+				if len(filesystems) == limit {
+					nextToken = fs.Path
+					break
+				}
+			} else if fs.Path == startingToken {
+				startingTokenFound = true
+			}
+		}
+		lastResultCount = len(filesystemsSlice)
+		offset += lastResultCount
+	}
+
+	return filesystems, nextToken, nil
 }
 
 // GetFilesystemsSlice returns a slice of filesystems by parent filesystem with specified limit and offset
 // offset - the first record number of collection, that would be included in result
 func (p *Provider) GetFilesystemsSlice(parent string, limit, offset int) ([]Filesystem, error) {
-    if limit <= 0 || limit >= nsFilesystemListLimit {
-        return nil, fmt.Errorf(
-            "GetFilesystemsSlice(): parameter 'limit' must be greater that 0 and less than %d, got: %d",
-            nsFilesystemListLimit,
-            limit,
-        )
-    } else if offset < 0 {
-        return nil, fmt.Errorf(
-            "GetFilesystemsSlice(): parameter 'offset' must be greater or equal to 0, got: %d",
-            offset,
-        )
-    }
-
-    uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
-        "parent": parent,
-        "limit":  fmt.Sprint(limit + 1), // the result includes parent itself
-        "offset": fmt.Sprint(offset),
-        "fields": "path,mountPoint,bytesAvailable,bytesUsed,sharedOverNfs,sharedOverSmb",
-    })
-
-    response := nefStorageFilesystemsResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return nil, err
-    }
-
-    filesystems := []Filesystem{}
-    for _, fs := range response.Data {
-        if fs.Path != parent { // exclude parent filesystem from the list
-            filesystems = append(filesystems, fs)
-        }
-    }
-
-    return filesystems, nil
+	if limit <= 0 || limit >= nsFilesystemListLimit {
+		return nil, fmt.Errorf(
+			"GetFilesystemsSlice(): parameter 'limit' must be greater that 0 and less than %d, got: %d",
+			nsFilesystemListLimit,
+			limit,
+		)
+	} else if offset < 0 {
+		return nil, fmt.Errorf(
+			"GetFilesystemsSlice(): parameter 'offset' must be greater or equal to 0, got: %d",
+			offset,
+		)
+	}
+
+	uri := p.RestClient.BuildURI("storage/filesystems", map[string]string{
+		"parent": parent,
+		"limit":  fmt.Sprint(limit + 1), // the result includes parent itself
+		"offset": fmt.Sprint(offset),
+		"fields": "path,mountPoint,bytesAvailable,bytesUsed,sharedOverNfs,sharedOverSmb,referencedQuotaSize",
+	})
+
+	response := nefStorageFilesystemsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	filesystems := []Filesystem{}
+	for _, fs := range response.Data {
+		if fs.Path != parent { // exclude parent filesystem from the list
+			filesystems = append(filesystems, fs)
+		}
+	}
+
+	return filesystems, nil
 }
 
 // GetVolumesSlice returns a slice of volumes by parent volumeGroup with specified limit and offset
 // offset - the first record number of collection, that would be included in result
 func (p *Provider) GetVolumesSlice(parent string, limit, offset int) ([]Volume, error) {
-    if limit <= 0 || limit >= nsFilesystemListLimit {
-        return nil, fmt.Errorf(
-            "GetVolumesSlice(): parameter 'limit' must be greater that 0 and less than %d, got: %d",
-            nsFilesystemListLimit,
-            limit,
-        )
-    } else if offset < 0 {
-        return nil, fmt.Errorf(
-            "GetVolumesSlice(): parameter 'offset' must be greater or equal to 0, got: %d",
-            offset,
-        )
-    }
-
-    uri := p.RestClient.BuildURI("storage/volumes", map[string]string{
-        "parent": parent,
-        "limit":  fmt.Sprint(limit),
-        "offset": fmt.Sprint(offset),
-    })
-
-    response := nefStorageVolumesResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return nil, err
-    }
-
-    volumes := []Volume{}
-    for _, fs := range response.Data {
-        volumes = append(volumes, fs)
-    }
-
-    return volumes, nil
+	if limit <= 0 || limit >= nsFilesystemListLimit {
+		return nil, fmt.Errorf(
+			"GetVolumesSlice(): parameter 'limit' must be greater that 0 and less than %d, got: %d",
+			nsFilesystemListLimit,
+			limit,
+		)
+	} else if offset < 0 {
+		return nil, fmt.Errorf(
+			"GetVolumesSlice(): parameter 'offset' must be greater or equal to 0, got: %d",
+			offset,
+		)
+	}
+
+	uri := p.RestClient.BuildURI("storage/volumes", map[string]string{
+		"parent": parent,
+		"limit":  fmt.Sprint(limit),
+		"offset": fmt.Sprint(offset),
+		"fields": nsVolumeFields,
+	})
+
+	response := nefStorageVolumesResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := []Volume{}
+	for _, fs := range response.Data {
+		volumes = append(volumes, fs)
+	}
+
+	return volumes, nil
 }
 
 // CreateFilesystemParams - params to create filesystem
 type CreateFilesystemParams struct {
-    // filesystem path w/o leading slash
-    Path string `json:"path"`
-    // filesystem referenced quota size in bytes
-    ReferencedQuotaSize int64 `json:"referencedQuotaSize,omitempty"`
+	// filesystem path w/o leading slash
+	Path string `json:"path"`
+	// filesystem referenced quota size in bytes
+	ReferencedQuotaSize int64 `json:"referencedQuotaSize,omitempty"`
 }
 
-// CreateFilesystem creates filesystem by path
-func (p *Provider) CreateFilesystem(params CreateFilesystemParams) error {
-    if params.Path == "" {
-        return fmt.Errorf("Parameter 'CreateFilesystemParams.Path' is required")
-    }
+// CreateFilesystem creates filesystem by path, returns created filesystem
+func (p *Provider) CreateFilesystem(params CreateFilesystemParams) (Filesystem, error) {
+	if params.Path == "" {
+		return Filesystem{}, fmt.Errorf("Parameter 'CreateFilesystemParams.Path' is required")
+	}
+
+	//TODO consider to add option https://jira.nexenta.com/browse/NEX-17476?focusedCommentId=154590
 
-    //TODO consider to add option https://jira.nexenta.com/browse/NEX-17476?focusedCommentId=154590
+	err := p.sendRequest(http.MethodPost, "storage/filesystems", params)
+	if err != nil {
+		return Filesystem{}, err
+	}
 
-    return p.sendRequest(http.MethodPost, "storage/filesystems", params)
+	return p.GetFilesystem(params.Path)
 }
 
 // UpdateFilesystemParams - params to update filesystem
 type UpdateFilesystemParams struct {
-    // filesystem referenced quota size in bytes
-    ReferencedQuotaSize int64 `json:"referencedQuotaSize,omitempty"`
+	// filesystem referenced quota size in bytes, 0 removes the quota, not sent if nil
+	ReferencedQuotaSize *int64 `json:"referencedQuotaSize,omitempty"`
 }
 
 // UpdateFilesystem updates filesystem by path
 func (p *Provider) UpdateFilesystem(path string, params UpdateFilesystemParams) error {
-    if path == "" {
-        return fmt.Errorf("Parameter 'path' is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Parameter 'path' is required")
+	}
 
-    uri :=  fmt.Sprintf("storage/filesystems/%s", url.PathEscape(path))
-    return p.sendRequest(http.MethodPut, uri, params)
+	uri := fmt.Sprintf("storage/filesystems/%s", url.PathEscape(path))
+	return p.sendRequest(http.MethodPut, uri, params)
 }
 
 // DestroyFilesystemParams - filesystem deletion parameters
 type DestroyFilesystemParams struct {
-    // If set to `true`, then tries to destroy filesystem's snapshots as well.
-    // In case some snapshots have clones, the filesystem cannot be deleted
-    // without deleting all dependent clones, OR promoting one of the clones
-    // to take over the snapshots (see "PromoteMostRecentCloneIfExists" parameter).
-    DestroySnapshots bool
-
-    // If set to `true`, then tries to find the most recent snapshot clone and if found one,
-    // that clone will be promoted to take over all the snapshots from the original filesystem,
-    // then the original filesystem will be destroyed.
-    //
-    // Initial state:
-    //    [fsSource]---+                       // source filesystem
-    //                 |    [snapshot1]        // source filesystem snapshots
-    //                 |    [snapshot2]
-    //                 `--->[snapshot3]<---+
-    //                                     |
-    //    [fsClone1]-----------------------+   // filesystem clone of "snapshot3"
-    //    [fsClone2]-----------------------+   // another filesystem clone of "snapshot3"
-    //
-    // After destroy "fsSource" filesystem call (PromoteMostRecentCloneIfExists=true and DestroySnapshots=true):
-    //    [fsClone1]<----------------------+   // "fsClone1" is still linked to "snapshot3"
-    //    [fsClone2]---+                   |   // "fsClone2" is got promoted to take over snapshots of "fsSource"
-    //                 |    [snapshot1]    |
-    //                 |    [snapshot2]    |
-    //                 `--->[snapshot3]<---+
-    //
-    PromoteMostRecentCloneIfExists bool
+	// If set to `true`, then tries to destroy filesystem's snapshots as well.
+	// In case some snapshots have clones, the filesystem cannot be deleted
+	// without deleting all dependent clones, OR promoting one of the clones
+	// to take over the snapshots (see "PromoteMostRecentCloneIfExists" parameter).
+	DestroySnapshots bool
+
+	// If set to `true`, then tries to find the most recent snapshot clone and if found one,
+	// that clone will be promoted to take over all the snapshots from the original filesystem,
+	// then the original filesystem will be destroyed.
+	//
+	// Initial state:
+	//    [fsSource]---+                       // source filesystem
+	//                 |    [snapshot1]        // source filesystem snapshots
+	//                 |    [snapshot2]
+	//                 `--->[snapshot3]<---+
+	//                                     |
+	//    [fsClone1]-----------------------+   // filesystem clone of "snapshot3"
+	//    [fsClone2]-----------------------+   // another filesystem clone of "snapshot3"
+	//
+	// After destroy "fsSource" filesystem call (PromoteMostRecentCloneIfExists=true and DestroySnapshots=true):
+	//    [fsClone1]<----------------------+   // "fsClone1" is still linked to "snapshot3"
+	//    [fsClone2]---+                   |   // "fsClone2" is got promoted to take over snapshots of "fsSource"
+	//                 |    [snapshot1]    |
+	//                 |    [snapshot2]    |
+	//                 `--->[snapshot3]<---+
+	//
+	PromoteMostRecentCloneIfExists bool
 }
 
 // DestroyFilesystem destroys filesystem on NS, may destroy snapshots and promote clones (see DestroyFilesystemParams)
 // Path format: 'pool/dataset/filesystem'
 func (p *Provider) DestroyFilesystem(path string, params DestroyFilesystemParams) error {
-    err := p.destroyFilesystem(path, params.DestroySnapshots)
-    if err == nil {
-        return nil
-    } else if !params.PromoteMostRecentCloneIfExists || !IsAlreadyExistNefError(err) {
-        return err
-    }
-
-    // If here then filesystem deletion request has failed because
-    // the filesystem has dependent clones (EEXIST error code), trying
-    // to promote the most recent clone to make the filesystem independent:
-
-    maxAttemptCount := 3
-    var mostRecentError error
-
-    for i := 0; i < maxAttemptCount; i++ {
-        mostRecentError = nil
-
-        snapshots, err := p.GetSnapshots(path, true)
-        if err != nil {
-            mostRecentError = fmt.Errorf("failed to get snapshot list: %s", err)
-            break
-        }
-
-        var maxCreationTxg int
-        var mostRecentClone string
-        for _, s := range snapshots {
-            // to get "clones" and "creationTxg" fields that are not presented in the list response
-            snapshot, err := p.GetSnapshot(s.Path)
-            if err != nil {
-                mostRecentError = fmt.Errorf("failed to get '%s' snapshost's info: %s", s.Path, err)
-                break
-            }
-            creationTxg, err := strconv.Atoi(snapshot.CreationTxg)
-            if err != nil {
-                mostRecentError = fmt.Errorf(
-                    "snapshot '%s': failed to convert 'creationTxg' value '%s' to integer: %s",
-                    s.Path,
-                    snapshot.CreationTxg,
-                    err,
-                )
-                break
-            } else if len(snapshot.Clones) > 0 && creationTxg > maxCreationTxg {
-                mostRecentClone = snapshot.Clones[0]
-                maxCreationTxg = creationTxg
-            }
-        }
-        if mostRecentError != nil {
-            // Failed to determine the most recent clone.
-            // Give another chance (or exit if max attempt count exceeded) if any error happened
-            // while getting each snaphost's information. For example, the snapshot got deleted
-            // right after snapshot list request, but before requesting its information.
-            continue
-        }
-
-        if mostRecentClone != "" {
-            err := p.PromoteFilesystem(mostRecentClone)
-            if err != nil {
-                mostRecentError = fmt.Errorf("failed to promote clone '%s': %s", mostRecentClone, err)
-                continue
-            }
-        }
-
-        mostRecentError = p.destroyFilesystem(path, params.DestroySnapshots)
-        if mostRecentError == nil {
-            return nil
-        } else if !IsAlreadyExistNefError(mostRecentError) { // if EEXIST code - filesystem still has dependent clones
-            break
-        }
-    }
-
-    // if not a NefError, wrap it into an explanation
-    if !IsNefError(mostRecentError) {
-        return fmt.Errorf("Failed to delete filesystem '%s': %s", path, mostRecentError)
-    }
-
-    return mostRecentError
+	err := p.destroyFilesystem(path, params.DestroySnapshots)
+	if err == nil {
+		return nil
+	} else if !params.PromoteMostRecentCloneIfExists || !IsAlreadyExistNefError(err) {
+		return err
+	}
+
+	// If here then filesystem deletion request has failed because
+	// the filesystem has dependent clones (EEXIST error code), trying
+	// to promote the most recent clone to make the filesystem independent:
+
+	maxAttemptCount := 3
+	var mostRecentError error
+
+	for i := 0; i < maxAttemptCount; i++ {
+		mostRecentError = nil
+
+		snapshots, err := p.GetSnapshots(path, true)
+		if err != nil {
+			mostRecentError = fmt.Errorf("failed to get snapshot list: %s", err)
+			break
+		}
+
+		var maxCreationTxg int
+		var mostRecentClone string
+		for _, s := range snapshots {
+			// to get "clones" and "creationTxg" fields that are not presented in the list response
+			snapshot, err := p.GetSnapshot(s.Path)
+			if err != nil {
+				mostRecentError = fmt.Errorf("failed to get '%s' snapshost's info: %s", s.Path, err)
+				break
+			}
+			creationTxg, err := strconv.Atoi(snapshot.CreationTxg)
+			if err != nil {
+				mostRecentError = fmt.Errorf(
+					"snapshot '%s': failed to convert 'creationTxg' value '%s' to integer: %s",
+					s.Path,
+					snapshot.CreationTxg,
+					err,
+				)
+				break
+			} else if len(snapshot.Clones) > 0 && creationTxg > maxCreationTxg {
+				mostRecentClone = snapshot.Clones[0]
+				maxCreationTxg = creationTxg
+			}
+		}
+		if mostRecentError != nil {
+			// Failed to determine the most recent clone.
+			// Give another chance (or exit if max attempt count exceeded) if any error happened
+			// while getting each snaphost's information. For example, the snapshot got deleted
+			// right after snapshot list request, but before requesting its information.
+			continue
+		}
+
+		if mostRecentClone != "" {
+			err := p.PromoteFilesystem(mostRecentClone)
+			if err != nil {
+				mostRecentError = fmt.Errorf("failed to promote clone '%s': %s", mostRecentClone, err)
+				continue
+			}
+		}
+
+		mostRecentError = p.destroyFilesystem(path, params.DestroySnapshots)
+		if mostRecentError == nil {
+			return nil
+		} else if !IsAlreadyExistNefError(mostRecentError) { // if EEXIST code - filesystem still has dependent clones
+			break
+		}
+	}
+
+	// if not a NefError, wrap it into an explanation
+	if !IsNefError(mostRecentError) {
+		return fmt.Errorf("Failed to delete filesystem '%s': %s", path, mostRecentError)
+	}
+
+	return mostRecentError
 }
 
 func (p *Provider) destroyFilesystem(path string, destroySnapshots bool) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is required")
+	}
 
-    uri := p.RestClient.BuildURI(
-        fmt.Sprintf("storage/filesystems/%s", url.PathEscape(path)),
-        map[string]string{
-            "force":     "true",
-            "snapshots": strconv.FormatBool(destroySnapshots),
-        },
-    )
+	uri := p.RestClient.BuildURI(
+		fmt.Sprintf("storage/filesystems/%s", url.PathEscape(path)),
+		map[string]string{
+			"force":     "true",
+			"snapshots": strconv.FormatBool(destroySnapshots),
+		},
+	)
 
-    return p.sendRequest(http.MethodDelete, uri, nil)
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // PromoteFilesystem promotes a cloned filesystem to be no longer dependent on its original snapshot
 func (p *Provider) PromoteFilesystem(path string) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is required")
+	}
 
-    uri := fmt.Sprintf("storage/filesystems/%s/promote", url.PathEscape(path))
+	uri := fmt.Sprintf("storage/filesystems/%s/promote", url.PathEscape(path))
 
-    return p.sendRequest(http.MethodPost, uri, nil)
+	return p.sendRequest(http.MethodPost, uri, nil)
 }
 
 // PromoteVolume promotes a cloned volume to be no longer dependent on its original snapshot
 func (p *Provider) PromoteVolume(path string) error {
-    if path == "" {
-        return fmt.Errorf("Volume path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Volume path is required")
+	}
 
-    uri := fmt.Sprintf("storage/volumes/%s/promote", url.PathEscape(path))
+	uri := fmt.Sprintf("storage/volumes/%s/promote", url.PathEscape(path))
 
-    return p.sendRequest(http.MethodPost, uri, nil)
+	return p.sendRequest(http.MethodPost, uri, nil)
 }
 
 // CreateNfsShareParams - params to create NFS share
 type CreateNfsShareParams struct {
-    // filesystem path w/o leading slash
-    Filesystem          string              `json:"filesystem"`
-    ReadWriteList       []NfsRuleList       `json:"readWriteList"`
-    ReadOnlyList        []NfsRuleList       `json:"readOnlyList"`
+	// filesystem path w/o leading slash
+	Filesystem    string        `json:"filesystem"`
+	ReadWriteList []NfsRuleList `json:"readWriteList"`
+	ReadOnlyList  []NfsRuleList `json:"readOnlyList"`
 }
 
-// CreateNfsShare creates NFS share on specified filesystem
+// CreateNfsShare creates NFS share on specified filesystem, returns created share
 // CLI test:
 //
-//   showmount -e HOST
-//   mkdir -p /mnt/test && sudo mount -v -t nfs HOST:/pool/fs /mnt/test
-//   findmnt /mnt/test
-func (p *Provider) CreateNfsShare(params CreateNfsShareParams) error {
-    if params.Filesystem == "" {
-        return fmt.Errorf("CreateNfsShareParams.Filesystem is required")
-    }
-
-    defaultEtype := "fqdn"
-    if len(params.ReadWriteList) == 0 {
-        if len(params.ReadOnlyList) == 0 {
-            params.ReadOnlyList = []NfsRuleList{
-                {
-                    Entity: "none",
-                    Etype: defaultEtype,
-                },
-            }
-            params.ReadWriteList = []NfsRuleList{
-                {
-                    Entity: "*",
-                    Etype: defaultEtype,
-                },
-            }
-        } else {
-            params.ReadWriteList = []NfsRuleList{
-                {
-                    Entity: "none",
-                    Etype: defaultEtype,
-                },
-            }
-        }
-    } else if len(params.ReadOnlyList) == 0 {
-        params.ReadOnlyList = []NfsRuleList{
-            {
-                Entity: "none",
-                Etype: defaultEtype,
-            },
-        }
-    }
-
-    data := nefNasNfsRequest{
-        Filesystem: params.Filesystem,
-        Anon:       "root",
-        SecurityContexts: []nefNasNfsRequestSecurityContext{
-            {
-                SecurityModes: []string{"sys"},
-                ReadWriteList: params.ReadWriteList,
-                ReadOnlyList: params.ReadOnlyList,
-            },
-        },
-    }
-
-    return p.sendRequest(http.MethodPost, "nas/nfs", data)
+//	showmount -e HOST
+//	mkdir -p /mnt/test && sudo mount -v -t nfs HOST:/pool/fs /mnt/test
+//	findmnt /mnt/test
+func (p *Provider) CreateNfsShare(params CreateNfsShareParams) (NfsShare, error) {
+	if params.Filesystem == "" {
+		return NfsShare{}, fmt.Errorf("CreateNfsShareParams.Filesystem is required")
+	}
+
+	data := nefNasNfsRequest{
+		Filesystem:       params.Filesystem,
+		Anon:             "root",
+		SecurityContexts: getNfsSecurityContexts(params),
+	}
+
+	err := p.sendRequest(http.MethodPost, "nas/nfs", data)
+	if err != nil {
+		return NfsShare{}, err
+	}
+
+	return p.GetNfsShare(params.Filesystem)
+}
+
+// getNfsSecurityContexts returns NFS share security contexts with default rules applied:
+// read-write access for everyone if no lists are set, no access for an empty list otherwise
+func getNfsSecurityContexts(params CreateNfsShareParams) []nefNasNfsRequestSecurityContext {
+	defaultEtype := "fqdn"
+	if len(params.ReadWriteList) == 0 {
+		if len(params.ReadOnlyList) == 0 {
+			params.ReadOnlyList = []NfsRuleList{
+				{
+					Entity: "none",
+					Etype:  defaultEtype,
+				},
+			}
+			params.ReadWriteList = []NfsRuleList{
+				{
+					Entity: "*",
+					Etype:  defaultEtype,
+				},
+			}
+		} else {
+			params.ReadWriteList = []NfsRuleList{
+				{
+					Entity: "none",
+					Etype:  defaultEtype,
+				},
+			}
+		}
+	} else if len(params.ReadOnlyList) == 0 {
+		params.ReadOnlyList = []NfsRuleList{
+			{
+				Entity: "none",
+				Etype:  defaultEtype,
+			},
+		}
+	}
+
+	return []nefNasNfsRequestSecurityContext{
+		{
+			SecurityModes: []string{"sys"},
+			ReadWriteList: params.ReadWriteList,
+			ReadOnlyList:  params.ReadOnlyList,
+		},
+	}
+}
+
+// GetNfsShare returns access lists of NFS share by filesystem path
+func (p *Provider) GetNfsShare(path string) (NfsShare, error) {
+	if path == "" {
+		return NfsShare{}, fmt.Errorf("Filesystem path is required")
+	}
+
+	uri := p.RestClient.BuildURI(
+		fmt.Sprintf("nas/nfs/%s", url.PathEscape(path)),
+		map[string]string{"fields": "filesystem,securityContexts"},
+	)
+
+	response := nefNasNfsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return NfsShare{}, err
+	}
+
+	share := NfsShare{Filesystem: response.Filesystem}
+	if len(response.SecurityContexts) > 0 {
+		share.ReadWriteList = response.SecurityContexts[0].ReadWriteList
+		share.ReadOnlyList = response.SecurityContexts[0].ReadOnlyList
+	}
+
+	return share, nil
+}
+
+// UpdateNfsShare replaces access lists of NFS share on specified filesystem,
+// default access lists are the same as for CreateNfsShare()
+func (p *Provider) UpdateNfsShare(params CreateNfsShareParams) error {
+	if params.Filesystem == "" {
+		return fmt.Errorf("CreateNfsShareParams.Filesystem is required")
+	}
+
+	uri := fmt.Sprintf("nas/nfs/%s", url.PathEscape(params.Filesystem))
+	data := nefNasNfsUpdateRequest{SecurityContexts: getNfsSecurityContexts(params)}
+
+	return p.sendRequest(http.MethodPut, uri, data)
 }
 
 // DeleteNfsShare destroys NFS chare by filesystem path
 func (p *Provider) DeleteNfsShare(path string) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is empty")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is empty")
+	}
 
-    uri := fmt.Sprintf("nas/nfs/%s", url.PathEscape(path))
+	uri := fmt.Sprintf("nas/nfs/%s", url.PathEscape(path))
 
-    return p.sendRequest(http.MethodDelete, uri, nil)
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // CreateSmbShareParams - params to create SMB share
 type CreateSmbShareParams struct {
-    // filesystem path w/o leading slash
-    Filesystem string `json:"filesystem"`
-    // share name, used in mount command
-    ShareName string `json:"shareName,omitempty"`
+	// filesystem path w/o leading slash
+	Filesystem string `json:"filesystem"`
+	// share name, used in mount command
+	ShareName string `json:"shareName,omitempty"`
 }
 
-// CreateSmbShare creates SMB share (cifs) on specified filesystem
+// CreateSmbShare creates SMB share (cifs) on specified filesystem, returns created share
 // Leave shareName empty to generate default value
 // CLI test:
 //
-//   mkdir -p /mnt/test && sudo mount -v -t cifs -o username=admin,password=Nexenta@1 //HOST//pool_fs /mnt/test
-//   findmnt /mnt/test
-func (p *Provider) CreateSmbShare(params CreateSmbShareParams) error {
-    if params.Filesystem == "" {
-        return fmt.Errorf("CreateSmbShareParams.Filesystem is required")
-    }
+//	mkdir -p /mnt/test && sudo mount -v -t cifs -o username=admin,password=Nexenta@1 //HOST//pool_fs /mnt/test
+//	findmnt /mnt/test
+func (p *Provider) CreateSmbShare(params CreateSmbShareParams) (SmbShare, error) {
+	if params.Filesystem == "" {
+		return SmbShare{}, fmt.Errorf("CreateSmbShareParams.Filesystem is required")
+	}
+
+	err := p.sendRequest(http.MethodPost, "nas/smb", params)
+	if err != nil {
+		return SmbShare{}, err
+	}
+
+	return p.GetSmbShare(params.Filesystem)
+}
+
+// GetSmbShare returns SMB share by filesystem path
+func (p *Provider) GetSmbShare(path string) (SmbShare, error) {
+	if path == "" {
+		return SmbShare{}, fmt.Errorf("Filesystem path is required")
+	}
+
+	uri := p.RestClient.BuildURI(
+		fmt.Sprintf("nas/smb/%s", url.PathEscape(path)),
+		map[string]string{"fields": "shareName,shareState"}, //TODO check shareState value?
+	)
+
+	response := nefNasSmbResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return SmbShare{}, err
+	}
 
-    return p.sendRequest(http.MethodPost, "nas/smb", params)
+	return SmbShare{Filesystem: path, ShareName: response.ShareName}, nil
 }
 
 // GetSmbShareName returns share name for filesystem that shared over SMB
 func (p *Provider) GetSmbShareName(path string) (string, error) {
-    if path == "" {
-        return "", fmt.Errorf("Filesystem path is required")
-    }
+	share, err := p.GetSmbShare(path)
+	if err != nil {
+		return "", err
+	}
 
-    uri := p.RestClient.BuildURI(
-        fmt.Sprintf("nas/smb/%s", url.PathEscape(path)),
-        map[string]string{"fields": "shareName,shareState"}, //TODO check shareState value?
-    )
+	return share.ShareName, nil
+}
+
+// UpdateSmbShare changes name of SMB share on specified filesystem
+func (p *Provider) UpdateSmbShare(params CreateSmbShareParams) error {
+	if params.Filesystem == "" || params.ShareName == "" {
+		return fmt.Errorf(
+			"Parameters 'CreateSmbShareParams.Filesystem' and 'CreateSmbShareParams.ShareName' are required, "+
+				"received: %+v",
+			params,
+		)
+	}
 
-    response := nefNasSmbResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return "", err
-    }
+	uri := fmt.Sprintf("nas/smb/%s", url.PathEscape(params.Filesystem))
+	data := nefNasSmbUpdateRequest{ShareName: params.ShareName}
 
-    return response.ShareName, nil
+	return p.sendRequest(http.MethodPut, uri, data)
 }
 
 // DeleteSmbShare destroys SMB share by filesystem path
 func (p *Provider) DeleteSmbShare(path string) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is empty")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is empty")
+	}
 
-    uri := fmt.Sprintf("nas/smb/%s", url.PathEscape(path))
+	uri := fmt.Sprintf("nas/smb/%s", url.PathEscape(path))
 
-    return p.sendRequest(http.MethodDelete, uri, nil)
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // SetFilesystemACL sets filesystem ACL, so NFS share can allow user to write w/o checking UNIX user uid
 func (p *Provider) SetFilesystemACL(path string, aclRuleSet ACLRuleSet) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is required")
+	}
 
-    uri := fmt.Sprintf("storage/filesystems/%s/acl", url.PathEscape(path))
+	uri := fmt.Sprintf("storage/filesystems/%s/acl", url.PathEscape(path))
 
-    permissions := []string{}
-    if aclRuleSet == ACLReadOnly {
-        permissions = append(permissions, "read_set")
-    } else {
-        permissions = append(permissions, "full_set")
-    }
+	permissions := []string{}
+	if aclRuleSet == ACLReadOnly {
+		permissions = append(permissions, "read_set")
+	} else {
+		permissions = append(permissions, "full_set")
+	}
 
-    data := &nefStorageFilesystemsACLRequest{
-        Type:      "allow",
-        Principal: "everyone@",
-        Flags: []string{
-            "file_inherit",
-            "dir_inherit",
-        },
-        Permissions: permissions,
-    }
+	data := &nefStorageFilesystemsACLRequest{
+		Type:      "allow",
+		Principal: "everyone@",
+		Flags: []string{
+			"file_inherit",
+			"dir_inherit",
+		},
+		Permissions: permissions,
+	}
 
-    return p.sendRequest(http.MethodPost, uri, data)
+	return p.sendRequest(http.MethodPost, uri, data)
 }
 
 // CreateSnapshotParams - params to create snapshot
 type CreateSnapshotParams struct {
-    // snapshot path w/o leading slash
-    Path string `json:"path"`
+	// snapshot path w/o leading slash
+	Path string `json:"path"`
 }
 
-// CreateSnapshot creates snapshot by filesystem path
-func (p *Provider) CreateSnapshot(params CreateSnapshotParams) error {
-    if params.Path == "" {
-        return fmt.Errorf("Parameter 'CreateSnapshotParams.Path' is required")
-    }
+// CreateSnapshot creates snapshot by filesystem path, returns created snapshot
+func (p *Provider) CreateSnapshot(params CreateSnapshotParams) (Snapshot, error) {
+	if params.Path == "" {
+		return Snapshot{}, fmt.Errorf("Parameter 'CreateSnapshotParams.Path' is required")
+	}
+
+	err := p.sendRequest(http.MethodPost, "storage/snapshots", params)
+	if err != nil {
+		return Snapshot{}, err
+	}
 
-    return p.sendRequest(http.MethodPost, "storage/snapshots", params)
+	return p.GetSnapshot(params.Path)
 }
 
 // GetSnapshot returns snapshot by its path
 // path - full path to snapshot w/o leading slash (e.g. "p/d/fs@s")
 func (p *Provider) GetSnapshot(path string) (snapshot Snapshot, err error) {
-    if path == "" {
-        return snapshot, fmt.Errorf("Snapshot path is empty")
-    }
+	if path == "" {
+		return snapshot, fmt.Errorf("Snapshot path is empty")
+	}
 
-    uri := p.RestClient.BuildURI(fmt.Sprintf("storage/snapshots/%s", url.PathEscape(path)), map[string]string{
-        "fields": "path,name,parent,creationTime,clones,creationTxg",
-        //TODO return "bytesReferenced" and check on volume creation
-    })
+	uri := p.RestClient.BuildURI(fmt.Sprintf("storage/snapshots/%s", url.PathEscape(path)), map[string]string{
+		"fields": "path,name,parent,creationTime,clones,creationTxg",
+		//TODO return "bytesReferenced" and check on volume creation
+	})
 
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &snapshot)
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &snapshot)
 
-    return snapshot, err
+	return snapshot, err
 }
 
 // GetSnapshots returns snapshots by volume path
 func (p *Provider) GetSnapshots(volumePath string, recursive bool) ([]Snapshot, error) {
-    if volumePath == "" {
-        return []Snapshot{}, fmt.Errorf("Snapshots volume path is empty")
-    }
+	if volumePath == "" {
+		return []Snapshot{}, fmt.Errorf("Snapshots volume path is empty")
+	}
 
-    uri := p.RestClient.BuildURI("storage/snapshots", map[string]string{
-        "parent":    volumePath,
-        "fields":    "path,name,parent,creationTime",
-        "recursive": strconv.FormatBool(recursive),
-    })
+	uri := p.RestClient.BuildURI("storage/snapshots", map[string]string{
+		"parent":    volumePath,
+		"fields":    "path,name,parent,creationTime",
+		"recursive": strconv.FormatBool(recursive),
+	})
 
-    response := nefStorageSnapshotsResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return []Snapshot{}, err
-    }
+	response := nefStorageSnapshotsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return []Snapshot{}, err
+	}
 
-    return response.Data, nil
+	return response.Data, nil
 }
 
 // DestroySnapshot destroys snapshot by path
 func (p *Provider) DestroySnapshot(path string) error {
-    if path == "" {
-        return fmt.Errorf("Snapshot path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Snapshot path is required")
+	}
 
-    uri := fmt.Sprintf("storage/snapshots/%s", url.PathEscape(path))
+	uri := fmt.Sprintf("storage/snapshots/%s", url.PathEscape(path))
 
-    return p.sendRequest(http.MethodDelete, uri, nil)
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // CloneSnapshotParams - params to clone snapshot to filesystem
 type CloneSnapshotParams struct {
-    // filesystem path w/o leading slash
-    TargetPath string `json:"targetPath"`
-    ReferencedQuotaSize int64 `json:"referencedQuotaSize,omitempty"`
+	// filesystem path w/o leading slash
+	TargetPath          string `json:"targetPath"`
+	ReferencedQuotaSize int64  `json:"referencedQuotaSize,omitempty"`
 }
 
-// CloneSnapshot clones snapshot to FS
-func (p *Provider) CloneSnapshot(path string, params CloneSnapshotParams) error {
-    if path == "" {
-        return fmt.Errorf("Snapshot path is required")
-    }
+// CloneSnapshot clones snapshot to FS, use CloneVolumeSnapshot() to clone volume snapshots
+// Returns created dataset, which is a volume if the snapshot belongs to a volume
+func (p *Provider) CloneSnapshot(path string, params CloneSnapshotParams) (CloneSnapshotResult, error) {
+	result := CloneSnapshotResult{}
 
-    if params.TargetPath == "" {
-        return fmt.Errorf("Parameter 'CloneSnapshotParams.TargetPath' is required")
-    }
-
-    uri := fmt.Sprintf("storage/snapshots/%s/clone", url.PathEscape(path))
+	if path == "" {
+		return result, fmt.Errorf("Snapshot path is required")
+	}
 
-    return p.sendRequest(http.MethodPost, uri, params)
-}
+	if params.TargetPath == "" {
+		return result, fmt.Errorf("Parameter 'CloneSnapshotParams.TargetPath' is required")
+	}
 
-// GetRSFClusters returns RSF clusters from NS
-func (p *Provider) GetRSFClusters() ([]RSFCluster, error) {
-    uri := p.RestClient.BuildURI("rsf/clusters", map[string]string{
-        "fields": "clusterName,nodes,services,health",
-    })
+	uri := fmt.Sprintf("storage/snapshots/%s/clone", url.PathEscape(path))
 
-    response := nefRsfClustersResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return nil, err
-    }
+	err := p.sendRequest(http.MethodPost, uri, params)
+	if err != nil {
+		return result, err
+	}
+	result.Path = params.TargetPath
+
+	filesystem, err := p.GetFilesystem(params.TargetPath)
+	if err == nil {
+		result.Type = DatasetTypeFilesystem
+		result.Filesystem = &filesystem
+		return result, nil
+	} else if !IsNotExistNefError(err) {
+		return result, err
+	}
 
-    return response.Data, nil
-}
+	volume, err := p.GetVolume(params.TargetPath)
+	if err != nil {
+		return result, err
+	}
+	result.Type = DatasetTypeVolume
+	result.Volume = &volume
+
+	return result, nil
+}
+
+// CloneVolumeSnapshotParams - params to clone volume snapshot to a new volume
+type CloneVolumeSnapshotParams struct {
+	// volumeGroup path w/o leading slash to create the clone in
+	VolumeGroup string
+	// name of the new volume
+	Name string
+	// size of the new volume in bytes, must be greater or equal to the volume size recorded in the snapshot,
+	// snapshot volume size is used if not set
+	VolumeSize int64
+	// if set to `true`, no space is reserved for the new volume,
+	// otherwise the whole volume size gets reserved
+	SparseVolume bool
+
+	// optional properties to override on the new volume
+	CompressionMode        string
+	DedupMode              string
+	SyncMode               string
+	WritebackCacheDisabled *bool
+}
+
+// CloneVolumeSnapshot clones volume snapshot to a new volume in specified volumeGroup,
+// new volume gets resized and its properties overwritten according to the params.
+// Requested size is checked against the volume size recorded in the snapshot before cloning,
+// the clone is destroyed if it cannot be updated, result path is set if the clone is left on NS.
+func (p *Provider) CloneVolumeSnapshot(path string, params CloneVolumeSnapshotParams) (CloneSnapshotResult, error) {
+	result := CloneSnapshotResult{}
+
+	if path == "" {
+		return result, fmt.Errorf("Snapshot path is required")
+	} else if params.VolumeGroup == "" || params.Name == "" {
+		return result, fmt.Errorf(
+			"Parameters 'CloneVolumeSnapshotParams.VolumeGroup' and 'CloneVolumeSnapshotParams.Name' are required, "+
+				"received: %+v",
+			params,
+		)
+	}
 
-// IsJobDone checks if job is done by jobId
-func (p *Provider) IsJobDone(jobID string) (bool, error) {
-    uri := fmt.Sprintf("jobStatus/%s", jobID)
-
-    statusCode, bodyBytes, err := p.RestClient.Send(http.MethodGet, uri, nil)
-    if err != nil { // request failed
-        return false, err
-    } else if statusCode == http.StatusOK || statusCode == http.StatusCreated { // job is completed
-        return true, nil
-    } else if statusCode == http.StatusAccepted { // job is in progress (202)
-        return false, nil
-    }
-
-    // job is failed
-    nefError := p.parseNefError(bodyBytes, "Job was finished with error")
-    if nefError != nil {
-        err = nefError
-    } else {
-        err = fmt.Errorf(
-            "Job request returned %d code, but response body doesn't contain explanation: %s",
-            statusCode,
-            bodyBytes,
-        )
-    }
-
-    return false, err
-}
+	sourcePath := strings.SplitN(path, "@", 2)[0]
+	_, err := p.GetVolume(sourcePath)
+	if err != nil {
+		if IsNotExistNefError(err) {
+			return result, fmt.Errorf(
+				"Snapshot '%s' is not a volume snapshot, use CloneSnapshot() to clone filesystem snapshots: %s",
+				path,
+				err,
+			)
+		}
+		return result, err
+	}
 
-// GetVolume - returns NexentaStor volume properties
-func (p *Provider) GetVolume(path string) (volume Volume, err error) {
-    if path == "" {
-        return volume, fmt.Errorf("Volume path is empty")
-    }
+	// the clone gets the volume size recorded in the snapshot, source volume may have been resized since then
+	snapshotSize, err := p.getSnapshotVolumeSize(path)
+	if err != nil {
+		return result, err
+	}
+	volumeSize := snapshotSize
+	if params.VolumeSize != 0 {
+		if params.VolumeSize < snapshotSize {
+			return result, &NefError{
+				Code: "EBADARG",
+				Err: fmt.Errorf(
+					"Clone size %d of snapshot '%s' cannot be less than snapshot volume size %d",
+					params.VolumeSize,
+					path,
+					snapshotSize,
+				),
+			}
+		}
+		volumeSize = params.VolumeSize
+	}
 
-    uri := p.RestClient.BuildURI("storage/volumes", map[string]string{
-        "path":   path,
-    })
+	targetPath := fmt.Sprintf("%s/%s", strings.TrimSuffix(params.VolumeGroup, "/"), params.Name)
+	result, err = p.CloneSnapshot(path, CloneSnapshotParams{TargetPath: targetPath})
+	if err != nil {
+		return result, err
+	} else if result.Volume == nil {
+		return result, fmt.Errorf("Snapshot '%s' clone '%s' is not a volume", path, targetPath)
+	}
 
-    response := nefStorageVolumesResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return response.Data[0], err
-    }
+	updateParams := UpdateVolumeParams{
+		CompressionMode:        params.CompressionMode,
+		DedupMode:              params.DedupMode,
+		SyncMode:               params.SyncMode,
+		WritebackCacheDisabled: params.WritebackCacheDisabled,
+	}
+	if volumeSize != result.Volume.VolumeSize {
+		updateParams.VolumeSize = volumeSize
+	}
+	if !params.SparseVolume {
+		updateParams.ReferencedReservationSize = &volumeSize
+	}
+	if updateParams != (UpdateVolumeParams{}) {
+		err = p.UpdateVolume(targetPath, updateParams)
+		if err != nil {
+			if destroyErr := p.destroyVolume(targetPath, false); destroyErr != nil {
+				return result, fmt.Errorf(
+					"Snapshot '%s' cloned to '%s', but failed to update the clone: %s, failed to destroy the clone: %s",
+					path,
+					targetPath,
+					err,
+					destroyErr,
+				)
+			}
+			return CloneSnapshotResult{}, fmt.Errorf(
+				"Snapshot '%s' cloned to '%s', but failed to update the clone, the clone is destroyed: %s",
+				path,
+				targetPath,
+				err,
+			)
+		}
+	}
 
-    if len(response.Data) == 0 {
-        return volume, &NefError{Code: "ENOENT", Err: fmt.Errorf("VolumeGroup '%s' not found", path)}
-    }
+	volume, err := p.GetVolume(targetPath)
+	if err != nil {
+		return result, err
+	}
+	result.Volume = &volume
 
-    return response.Data[0], nil
+	return result, nil
 }
 
-// GetVolumeGroup returns NexentaStor volumeGroup by its path
-func (p *Provider) GetVolumeGroup(path string) (volumeGroup VolumeGroup,err error) {
-    if path == "" {
-        return volumeGroup, fmt.Errorf("VolumeGroup path is empty")
-    }
-
-    uri := p.RestClient.BuildURI("storage/volumeGroups", map[string]string{
-        "path":   path,
-    })
-
-    response := nefStorageVolumeGroupsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return volumeGroup, err
-    }
-
-    if len(response.Data) == 0 {
-        return volumeGroup, &NefError{Code: "ENOENT", Err: fmt.Errorf("VolumeGroup '%s' not found", path)}
-    }
+// getSnapshotVolumeSize returns the volume size recorded in volume snapshot
+func (p *Provider) getSnapshotVolumeSize(path string) (int64, error) {
+	uri := p.RestClient.BuildURI(fmt.Sprintf("storage/snapshots/%s", url.PathEscape(path)), map[string]string{
+		"fields": "volumeSize",
+	})
 
-    return response.Data[0], nil
-}
+	response := struct {
+		VolumeSize int64 `json:"volumeSize"`
+	}{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return 0, err
+	} else if response.VolumeSize == 0 {
+		return 0, fmt.Errorf("Snapshot '%s' has no volume size, it's not a volume snapshot", path)
+	}
 
-// CreateVolumeParams - params to create a volume
-type CreateVolumeParams struct {
-    // volume path w/o leading slash
-    Path                string `json:"path"`
-    VolumeSize          int64  `json:"volumeSize"`
-    SparseVolume        bool   `json:"sparseVolume"` 
+	return response.VolumeSize, nil
 }
 
-// CreateVolume creates volume by path and size
-func (p *Provider) CreateVolume(params CreateVolumeParams) error {
-    if params.Path == "" {
-        return fmt.Errorf(
-            "Parameters 'Volume.Path' is required, received %+v", params)
-    }
-
-    return p.sendRequest(http.MethodPost, "storage/volumes", params)
+// GetRSFClusters returns RSF clusters from NS
+func (p *Provider) GetRSFClusters() ([]RSFCluster, error) {
+	uri := p.RestClient.BuildURI("rsf/clusters", map[string]string{
+		"fields": "clusterName,nodes,services,health",
+	})
+
+	response := nefRsfClustersResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// IsJobDone checks if job is done by jobId
+func (p *Provider) IsJobDone(jobID string) (bool, error) {
+	uri := fmt.Sprintf("jobStatus/%s", jobID)
+
+	statusCode, bodyBytes, err := p.RestClient.Send(http.MethodGet, uri, nil)
+	if err != nil { // request failed
+		return false, err
+	} else if statusCode == http.StatusOK || statusCode == http.StatusCreated { // job is completed
+		return true, nil
+	} else if statusCode == http.StatusAccepted { // job is in progress (202)
+		return false, nil
+	}
+
+	// job is failed
+	nefError := p.parseNefError(bodyBytes, "Job was finished with error")
+	if nefError != nil {
+		p.observeNefError(http.MethodGet, uri, nefError)
+		err = nefError
+	} else {
+		err = fmt.Errorf(
+			"Job request returned %d code, but response body doesn't contain explanation: %s",
+			statusCode,
+			bodyBytes,
+		)
+	}
+
+	return false, err
+}
+
+// GetVolume - returns NexentaStor volume properties
+func (p *Provider) GetVolume(path string) (volume Volume, err error) {
+	if path == "" {
+		return volume, fmt.Errorf("Volume path is empty")
+	}
+
+	uri := p.RestClient.BuildURI("storage/volumes", map[string]string{
+		"path":   path,
+		"fields": nsVolumeFields,
+	})
+
+	response := nefStorageVolumesResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return volume, err
+	}
+
+	if len(response.Data) == 0 {
+		return volume, &NefError{Code: "ENOENT", Err: fmt.Errorf("Volume '%s' not found", path)}
+	}
+
+	return response.Data[0], nil
+}
+
+// GetVolumeGroup returns NexentaStor volumeGroup by its path
+func (p *Provider) GetVolumeGroup(path string) (volumeGroup VolumeGroup, err error) {
+	if path == "" {
+		return volumeGroup, fmt.Errorf("VolumeGroup path is empty")
+	}
+
+	uri := p.RestClient.BuildURI("storage/volumeGroups", map[string]string{
+		"path":   path,
+		"fields": nsVolumeGroupFields,
+	})
+
+	response := nefStorageVolumeGroupsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return volumeGroup, err
+	}
+
+	if len(response.Data) == 0 {
+		return volumeGroup, &NefError{Code: "ENOENT", Err: fmt.Errorf("VolumeGroup '%s' not found", path)}
+	}
+
+	return response.Data[0], nil
+}
+
+// GetVolumeGroups returns all NexentaStor volumeGroups by parent filesystem
+func (p *Provider) GetVolumeGroups(parent string) ([]VolumeGroup, error) {
+	volumeGroups := []VolumeGroup{}
+
+	offset := 0
+	lastResultCount := nsFilesystemListLimit
+	for lastResultCount >= nsFilesystemListLimit-1 {
+		volumeGroupsSlice, err := p.GetVolumeGroupsSlice(parent, nsFilesystemListLimit-1, offset)
+		if err != nil {
+			return nil, err
+		}
+		volumeGroups = append(volumeGroups, volumeGroupsSlice...)
+		lastResultCount = len(volumeGroupsSlice)
+		offset += lastResultCount
+	}
+
+	return volumeGroups, nil
+}
+
+// GetVolumeGroupsSlice returns a slice of volumeGroups by parent filesystem with specified limit and offset
+// offset - the first record number of collection, that would be included in result
+func (p *Provider) GetVolumeGroupsSlice(parent string, limit, offset int) ([]VolumeGroup, error) {
+	if limit <= 0 || limit >= nsFilesystemListLimit {
+		return nil, fmt.Errorf(
+			"GetVolumeGroupsSlice(): parameter 'limit' must be greater that 0 and less than %d, got: %d",
+			nsFilesystemListLimit,
+			limit,
+		)
+	} else if offset < 0 {
+		return nil, fmt.Errorf(
+			"GetVolumeGroupsSlice(): parameter 'offset' must be greater or equal to 0, got: %d",
+			offset,
+		)
+	}
+
+	uri := p.RestClient.BuildURI("storage/volumeGroups", map[string]string{
+		"parent": parent,
+		"limit":  fmt.Sprint(limit),
+		"offset": fmt.Sprint(offset),
+		"fields": nsVolumeGroupFields,
+	})
+
+	response := nefStorageVolumeGroupsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeGroups := []VolumeGroup{}
+	for _, volumeGroup := range response.Data {
+		if volumeGroup.Path != parent { // exclude parent from the list
+			volumeGroups = append(volumeGroups, volumeGroup)
+		}
+	}
+
+	return volumeGroups, nil
+}
+
+// CreateVolumeGroupParams - params to create a volumeGroup
+// Properties are inherited by volumes created in the volumeGroup, not set ones are not sent
+type CreateVolumeGroupParams struct {
+	// volumeGroup path w/o leading slash
+	Path string `json:"path"`
+	// block size in bytes of volumes created in the volumeGroup
+	VolumeBlockSize int64 `json:"volumeBlockSize,omitempty"`
+	// compression algorithm: "off", "on", "lz4", "gzip", etc.
+	CompressionMode string `json:"compressionMode,omitempty"`
+}
+
+// CreateVolumeGroup creates volumeGroup by path, returns created volumeGroup
+func (p *Provider) CreateVolumeGroup(params CreateVolumeGroupParams) (VolumeGroup, error) {
+	if params.Path == "" {
+		return VolumeGroup{}, fmt.Errorf("Parameter 'CreateVolumeGroupParams.Path' is required")
+	}
+
+	err := p.sendRequest(http.MethodPost, "storage/volumeGroups", params)
+	if err != nil {
+		return VolumeGroup{}, err
+	}
+
+	return p.GetVolumeGroup(params.Path)
+}
+
+// UpdateVolumeGroupParams - params to update volumeGroup, not set properties are not sent
+type UpdateVolumeGroupParams struct {
+	// block size in bytes of volumes created in the volumeGroup
+	VolumeBlockSize int64 `json:"volumeBlockSize,omitempty"`
+	// compression algorithm: "off", "on", "lz4", "gzip", etc.
+	CompressionMode string `json:"compressionMode,omitempty"`
+}
+
+// UpdateVolumeGroup updates volumeGroup by path
+func (p *Provider) UpdateVolumeGroup(path string, params UpdateVolumeGroupParams) error {
+	if path == "" {
+		return fmt.Errorf("Parameter 'path' is required")
+	}
+
+	uri := fmt.Sprintf("storage/volumeGroups/%s", url.PathEscape(path))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
+
+// DestroyVolumeGroupParams - volumeGroup deletion parameters
+type DestroyVolumeGroupParams struct {
+	// If set to `true`, then all volumes of the volumeGroup are destroyed first,
+	// otherwise volumeGroup deletion fails if it has volumes.
+	Recursive bool
+
+	// If set to `true`, then tries to destroy snapshots as well,
+	// see DestroyFilesystemParams.DestroySnapshots for details.
+	DestroySnapshots bool
+
+	// If set to `true`, then the most recent clone of each destroyed volume snapshots is promoted,
+	// see DestroyFilesystemParams.PromoteMostRecentCloneIfExists for details.
+	PromoteMostRecentCloneIfExists bool
+}
+
+// DestroyVolumeGroup destroys volumeGroup on NS, may destroy its volumes and snapshots (see DestroyVolumeGroupParams)
+// Path format: 'pool/volumeGroup'
+func (p *Provider) DestroyVolumeGroup(path string, params DestroyVolumeGroupParams) error {
+	if path == "" {
+		return fmt.Errorf("VolumeGroup path is required")
+	}
+
+	if params.Recursive {
+		volumes, err := p.GetVolumes(path)
+		if err != nil {
+			return fmt.Errorf("Failed to get volumes of volumeGroup '%s': %s", path, err)
+		}
+		for _, volume := range volumes {
+			_, err := p.DestroyVolume(volume.Path, DestroyVolumeParams{
+				DestroySnapshots:               params.DestroySnapshots,
+				PromoteMostRecentCloneIfExists: params.PromoteMostRecentCloneIfExists,
+			})
+			if err != nil && !IsNotExistNefError(err) {
+				return err
+			}
+		}
+	}
+
+	return p.destroyVolumeGroup(path, params.DestroySnapshots)
+}
+
+func (p *Provider) destroyVolumeGroup(path string, destroySnapshots bool) error {
+	uri := p.RestClient.BuildURI(
+		fmt.Sprintf("storage/volumeGroups/%s", url.PathEscape(path)),
+		map[string]string{
+			"snapshots": strconv.FormatBool(destroySnapshots),
+		},
+	)
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// CreateVolumeParams - params to create a volume
+// Optional parameters are not sent if not set, so NS defaults or inherited values are used
+type CreateVolumeParams struct {
+	// volume path w/o leading slash
+	Path         string `json:"path"`
+	VolumeSize   int64  `json:"volumeSize"`
+	SparseVolume bool   `json:"sparseVolume"`
+	// volume block size in bytes, cannot be changed after creation
+	VolumeBlockSize int64 `json:"volumeBlockSize,omitempty"`
+	// compression algorithm: "off", "on", "lz4", "gzip", etc.
+	CompressionMode string `json:"compressionMode,omitempty"`
+	// deduplication mode: "off", "on", "verify", etc.
+	DedupMode string `json:"dedupMode,omitempty"`
+	// synchronous write behaviour: "standard", "always" or "disabled"
+	SyncMode string `json:"syncMode,omitempty"`
+	// disables writeback cache of the volume if set to `true`
+	WritebackCacheDisabled *bool `json:"writebackCacheDisabled,omitempty"`
+	// volume referenced reservation size in bytes, NS reserves the whole volume size if not set,
+	// must be 0 or not set for sparse volumes
+	ReferencedReservationSize *int64 `json:"referencedReservationSize,omitempty"`
+}
+
+// CreateVolume creates volume by path and size, returns created volume
+func (p *Provider) CreateVolume(params CreateVolumeParams) (Volume, error) {
+	if params.Path == "" {
+		return Volume{}, fmt.Errorf(
+			"Parameters 'Volume.Path' is required, received %+v", params)
+	} else if params.SparseVolume && params.ReferencedReservationSize != nil && *params.ReferencedReservationSize != 0 {
+		return Volume{}, &NefError{
+			Code: "EBADARG",
+			Err: fmt.Errorf(
+				"Parameter 'CreateVolumeParams.ReferencedReservationSize' must be 0 for sparse volume '%s', got: %d",
+				params.Path,
+				*params.ReferencedReservationSize,
+			),
+		}
+	}
+
+	err := p.sendRequest(http.MethodPost, "storage/volumes", params)
+	if err != nil {
+		return Volume{}, err
+	}
+
+	return p.GetVolume(params.Path)
 }
 
 // UpdateVolumeParams - params to update volume
+// Optional parameters are not sent if not set, volume block size cannot be updated
 type UpdateVolumeParams struct {
-    // volume referenced quota size in bytes
-    VolumeSize int64 `json:"volumeSize,omitempty"`
+	// volume referenced quota size in bytes
+	VolumeSize int64 `json:"volumeSize,omitempty"`
+	// compression algorithm: "off", "on", "lz4", "gzip", etc.
+	CompressionMode string `json:"compressionMode,omitempty"`
+	// deduplication mode: "off", "on", "verify", etc.
+	DedupMode string `json:"dedupMode,omitempty"`
+	// synchronous write behaviour: "standard", "always" or "disabled"
+	SyncMode string `json:"syncMode,omitempty"`
+	// disables writeback cache of the volume if set to `true`
+	WritebackCacheDisabled *bool `json:"writebackCacheDisabled,omitempty"`
+	// volume referenced reservation size in bytes,
+	// set it to the volume size to make volume thick or to 0 to make it sparse
+	ReferencedReservationSize *int64 `json:"referencedReservationSize,omitempty"`
 }
 
 // UpdateVolume updates volume by path
 func (p *Provider) UpdateVolume(path string, params UpdateVolumeParams) error {
-    if path == "" {
-        return fmt.Errorf("Parameter 'path' is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Parameter 'path' is required")
+	}
+
+	uri := fmt.Sprintf("storage/volumes/%s", url.PathEscape(path))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
 
-    uri :=  fmt.Sprintf("storage/volumes/%s", url.PathEscape(path))
-    return p.sendRequest(http.MethodPut, uri, params)
+// ResizeVolumeParams - params to resize volume
+type ResizeVolumeParams struct {
+	// new volume size in bytes, gets rounded up to the volume block size
+	VolumeSize int64
+	// if set to `true`, volume can be shrunk, which may corrupt data written by the client filesystem
+	AllowShrink bool
+	// maximum ratio of all volume sizes in the pool to the pool capacity, checked for sparse volumes only,
+	// e.g. 1.5 allows to provision 150% of the pool capacity; 0 (default) turns the check off,
+	// so sparse volume is grown regardless of the pool capacity
+	MaxOvercommitRatio float64
+}
+
+// ResizeVolumeResult - volume size before and after ResizeVolume() call
+type ResizeVolumeResult struct {
+	OldSize int64
+	NewSize int64
+}
+
+// ResizeVolume safely changes volume size: the size gets aligned to the volume block size,
+// shrinking is refused unless explicitly allowed, free pool space is checked for thick volumes
+// and overcommit ratio is checked for sparse ones if ResizeVolumeParams.MaxOvercommitRatio is set
+func (p *Provider) ResizeVolume(path string, params ResizeVolumeParams) (ResizeVolumeResult, error) {
+	l := p.Log.WithField("func", "ResizeVolume()")
+
+	result := ResizeVolumeResult{}
+
+	if path == "" {
+		return result, fmt.Errorf("Parameter 'path' is required")
+	} else if params.VolumeSize <= 0 {
+		return result, fmt.Errorf("Parameter 'ResizeVolumeParams.VolumeSize' must be greater than 0, got: %d", params.VolumeSize)
+	}
+
+	volume, err := p.GetVolume(path)
+	if err != nil {
+		return result, err
+	}
+
+	result, updateParams, err := p.getResizeVolumeParams(volume, params)
+	if err != nil || result.NewSize == result.OldSize {
+		return result, err
+	}
+
+	l.Debugf("resize volume '%s' from %d to %d bytes", path, result.OldSize, result.NewSize)
+	err = p.UpdateVolume(path, updateParams)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// getResizeVolumeParams checks if the volume can be resized and returns update params to resize it,
+// update params are empty if the size is not changed
+func (p *Provider) getResizeVolumeParams(volume Volume, params ResizeVolumeParams) (
+	ResizeVolumeResult,
+	UpdateVolumeParams,
+	error,
+) {
+	result := ResizeVolumeResult{
+		OldSize: volume.VolumeSize,
+		NewSize: alignSize(params.VolumeSize, volume.VolumeBlockSize),
+	}
+
+	if result.NewSize == result.OldSize {
+		return result, UpdateVolumeParams{}, nil
+	} else if result.NewSize < result.OldSize && !params.AllowShrink {
+		return result, UpdateVolumeParams{}, fmt.Errorf(
+			"Volume '%s' cannot be shrunk from %d to %d bytes, use 'AllowShrink' parameter to force it",
+			volume.Path,
+			result.OldSize,
+			result.NewSize,
+		)
+	}
+
+	pool := strings.SplitN(volume.Path, "/", 2)[0]
+	if result.NewSize > result.OldSize {
+		var err error
+		if volume.IsSparse() {
+			if params.MaxOvercommitRatio > 0 {
+				err = p.checkPoolOvercommit(pool, result.NewSize-result.OldSize, params.MaxOvercommitRatio)
+			}
+		} else {
+			err = p.checkPoolAvailableCapacity(pool, result.NewSize-result.OldSize)
+		}
+		if err != nil {
+			return result, UpdateVolumeParams{}, fmt.Errorf(
+				"Cannot resize volume '%s' to %d bytes: %s",
+				volume.Path,
+				result.NewSize,
+				err,
+			)
+		}
+	}
+
+	updateParams := UpdateVolumeParams{VolumeSize: result.NewSize}
+	if !volume.IsSparse() {
+		updateParams.ReferencedReservationSize = &result.NewSize
+	}
+
+	return result, updateParams, nil
+}
+
+// alignSize rounds size up to the multiple of block size
+func alignSize(size, blockSize int64) int64 {
+	if blockSize <= 0 || size%blockSize == 0 {
+		return size
+	}
+	return (size/blockSize + 1) * blockSize
+}
+
+// checkPoolAvailableCapacity returns an error if pool has less than requested bytes available
+func (p *Provider) checkPoolAvailableCapacity(pool string, size int64) error {
+	available, err := p.GetFilesystemAvailableCapacity(pool)
+	if err != nil {
+		return fmt.Errorf("Failed to get pool '%s' available capacity: %s", pool, err)
+	} else if available < size {
+		return fmt.Errorf("Pool '%s' has %d bytes available, but %d bytes required", pool, available, size)
+	}
+	return nil
+}
+
+// checkPoolOvercommit returns an error if sizes of all volumes in the pool with added size
+// exceed pool capacity multiplied by the max overcommit ratio, maxRatio must be greater than 0
+func (p *Provider) checkPoolOvercommit(pool string, size int64, maxRatio float64) error {
+	poolFilesystem, err := p.GetFilesystem(pool)
+	if err != nil {
+		return fmt.Errorf("Failed to get pool '%s' capacity: %s", pool, err)
+	}
+	capacity := poolFilesystem.GetReferencedQuotaSize()
+
+	provisioned, err := p.getProvisionedVolumeSize(pool)
+	if err != nil {
+		return fmt.Errorf("Failed to get pool '%s' provisioned size: %s", pool, err)
+	}
+	provisioned += size
+
+	if float64(provisioned) > float64(capacity)*maxRatio {
+		return fmt.Errorf(
+			"Pool '%s' provisioned size %d exceeds %.2f overcommit ratio of its capacity %d",
+			pool,
+			provisioned,
+			maxRatio,
+			capacity,
+		)
+	}
+	return nil
+}
+
+// getProvisionedVolumeSize returns total size of all volumes under the filesystem,
+// including volumeGroups of nested filesystems
+func (p *Provider) getProvisionedVolumeSize(parent string) (int64, error) {
+	var provisioned int64
+
+	volumeGroups, err := p.GetVolumeGroups(parent)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get volumeGroups of '%s': %s", parent, err)
+	}
+	for _, volumeGroup := range volumeGroups {
+		volumes, err := p.GetVolumes(volumeGroup.Path)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get volumes of '%s': %s", volumeGroup.Path, err)
+		}
+		for _, volume := range volumes {
+			provisioned += volume.VolumeSize
+		}
+	}
+
+	filesystems, err := p.GetFilesystems(parent)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get child filesystems of '%s': %s", parent, err)
+	}
+	for _, filesystem := range filesystems {
+		size, err := p.getProvisionedVolumeSize(filesystem.Path)
+		if err != nil {
+			return 0, err
+		}
+		provisioned += size
+	}
+
+	return provisioned, nil
 }
 
 type GetLunMappingsParams struct {
-    TargetGroup string  `json:"targetGroup,omitempty"`
-    Volume      string  `json:"volume,omitempty"`
-    HostGroup   string  `json:"hostGroup,omitempty"`
+	TargetGroup string `json:"targetGroup,omitempty"`
+	Volume      string `json:"volume,omitempty"`
+	HostGroup   string `json:"hostGroup,omitempty"`
 }
 
 // GetLunMappings returns NexentaStor lunmappings for given parameters
 func (p *Provider) GetLunMappings(params GetLunMappingsParams) (lunMappings []LunMapping, err error) {
-    reqParams := map[string]string{
-        "fields": "id,volume,targetGroup,hostGroup,lun",
-    }
-    if params.TargetGroup != "" {
-        reqParams["targetGroup"] = params.TargetGroup
-    }
-    if params.Volume != "" {
-        reqParams["volume"] = params.Volume
-    }
-    if params.HostGroup != "" {
-        reqParams["hostGroup"] = params.HostGroup
-    }
-    uri := p.RestClient.BuildURI("san/lunMappings", reqParams)
-    response := nefLunMappingsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return lunMappings, err
-    }
-
-    return response.Data, nil
+	reqParams := map[string]string{
+		"fields": "id,volume,targetGroup,hostGroup,lun",
+	}
+	if params.TargetGroup != "" {
+		reqParams["targetGroup"] = params.TargetGroup
+	}
+	if params.Volume != "" {
+		reqParams["volume"] = params.Volume
+	}
+	if params.HostGroup != "" {
+		reqParams["hostGroup"] = params.HostGroup
+	}
+	uri := p.RestClient.BuildURI("san/lunMappings", reqParams)
+	response := nefLunMappingsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return lunMappings, err
+	}
+
+	return response.Data, nil
 }
 
 // GetAllLunMappings returns all NexentaStor lunMappings
@@ -965,69 +1624,207 @@ func (p *Provider) GetLunMappingsSlice(limit, offset int) ([]LunMapping, error)
 
 // GetLunMapping returns NexentaStor lunmapping for a volume
 func (p *Provider) GetLunMapping(path string) (lunMapping LunMapping, err error) {
-    if path == "" {
-            return lunMapping, fmt.Errorf("Volume path is empty")
-    }
-    uri := p.RestClient.BuildURI("san/lunMappings", map[string]string{
-        "volume": path,
-        "fields": "id,volume,targetGroup,hostGroup,lun",
-    })
-    response := nefLunMappingsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
-    if err != nil {
-        return lunMapping, err
-    }
-   if len(response.Data) == 0 {
-        return lunMapping, &NefError{Code: "ENOENT", Err: fmt.Errorf("lunMapping '%s' not found", path)}
-    }
-
-    return response.Data[0], nil
+	if path == "" {
+		return lunMapping, fmt.Errorf("Volume path is empty")
+	}
+	uri := p.RestClient.BuildURI("san/lunMappings", map[string]string{
+		"volume": path,
+		"fields": "id,volume,targetGroup,hostGroup,lun",
+	})
+	response := nefLunMappingsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return lunMapping, err
+	}
+	if len(response.Data) == 0 {
+		return lunMapping, &NefError{Code: "ENOENT", Err: fmt.Errorf("lunMapping '%s' not found", path)}
+	}
+
+	return response.Data[0], nil
 }
 
 // CreateRemoteInitiatorParams - params to create credentials for remote initiator
 type CreateRemoteInitiatorParams struct {
-    Name       string   `json:"name"`
-    ChapUser   string   `json:"chapUser"`
-    ChapSecret string   `json:"chapSecret"`
+	Name     string `json:"name"`
+	ChapUser string `json:"chapUser"`
+	// initiator CHAP secret, must be 12-16 characters long
+	ChapSecret string `json:"chapSecret"`
 }
 
 // CreateRemoteInitiator - create new remote initiator in NexentaStor
 func (p *Provider) CreateRemoteInitiator(params CreateRemoteInitiatorParams) error {
-    if params.Name == "" || params.ChapSecret == "" {
-        return fmt.Errorf(
-            "Parameters 'Name' and 'ChapSecret' are required, received: %+v", params)
-    }
-    err := p.sendRequest(http.MethodPost, "v1.2.6/san/iscsi/remoteInitiators", params)
-    if err != nil {
-        return err
-    }
-    return nil
+	if params.Name == "" || params.ChapSecret == "" {
+		return fmt.Errorf(
+			"Parameters 'Name' and 'ChapSecret' are required, received name: '%s'", params.Name)
+	}
+	if err := validateChapSecret("CreateRemoteInitiatorParams.ChapSecret", params.ChapSecret); err != nil {
+		return err
+	}
+	err := p.sendRequest(http.MethodPost, "san/iscsi/remoteInitiators", params)
+	if err != nil {
+		return wrapChapSecretError(err, "CreateRemoteInitiatorParams.ChapSecret", params.Name)
+	}
+	return nil
 }
 
 // UpdateRemoteInitiatorParams - params to update credentials for remote initiator
 type UpdateRemoteInitiatorParams struct {
-    ChapUser   string   `json:"chapUser"`
-    ChapSecret string   `json:"chapSecret"`
+	ChapUser string `json:"chapUser"`
+	// initiator CHAP secret, must be 12-16 characters long
+	ChapSecret string `json:"chapSecret"`
 }
 
 // UpdateRemoteInitiator updates remote initiator for given name
 func (p *Provider) UpdateRemoteInitiator(name string, params UpdateRemoteInitiatorParams) error {
-    if name == "" {
-        return fmt.Errorf("Parameter 'name' is required, received: %+v", name)
-    }
+	if name == "" {
+		return fmt.Errorf("Parameter 'name' is required, received: %+v", name)
+	}
+	if params.ChapSecret != "" {
+		if err := validateChapSecret("UpdateRemoteInitiatorParams.ChapSecret", params.ChapSecret); err != nil {
+			return err
+		}
+	}
 
-    uri :=  fmt.Sprintf("v1.2.6/san/iscsi/remoteInitiators/%s", url.PathEscape(name))
-    return p.sendRequest(http.MethodPut, uri, params)
+	uri := fmt.Sprintf("san/iscsi/remoteInitiators/%s", url.PathEscape(name))
+	err := p.sendRequest(http.MethodPut, uri, params)
+	if err != nil && params.ChapSecret != "" {
+		return wrapChapSecretError(err, "UpdateRemoteInitiatorParams.ChapSecret", name)
+	}
+	return err
 }
 
 // GetRemoteInitiator - returns remote initiator object for given name
 func (p *Provider) GetRemoteInitiator(name string) (remoteInitiator RemoteInitiator, err error) {
-    if name == "" {
-        return remoteInitiator, fmt.Errorf("Remote Initiator name is empty")
-    }
-    uri := p.RestClient.BuildURI(fmt.Sprintf("v1.2.6/san/iscsi/remoteInitiators/%s", url.PathEscape(name)), map[string]string{})
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &remoteInitiator)
-    return remoteInitiator, err
+	if name == "" {
+		return remoteInitiator, fmt.Errorf("Remote Initiator name is empty")
+	}
+	uri := p.RestClient.BuildURI(fmt.Sprintf("san/iscsi/remoteInitiators/%s", url.PathEscape(name)), map[string]string{})
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &remoteInitiator)
+	return remoteInitiator, err
+}
+
+// ListRemoteInitiators - returns all remote initiators configured on NexentaStor
+func (p *Provider) ListRemoteInitiators() ([]RemoteInitiator, error) {
+	uri := p.RestClient.BuildURI("san/iscsi/remoteInitiators", map[string]string{
+		"fields": "name,chapUser,chapSecretSet",
+	})
+
+	response := nefRemoteInitiatorsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// DeleteRemoteInitiator - deletes remote initiator CHAP credentials by initiator name
+func (p *Provider) DeleteRemoteInitiator(name string) error {
+	if name == "" {
+		return fmt.Errorf("Remote Initiator name is empty")
+	}
+
+	uri := fmt.Sprintf("san/iscsi/remoteInitiators/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// SetMutualChapParams - params to configure bidirectional CHAP between initiator and target
+type SetMutualChapParams struct {
+	// initiator name (IQN)
+	Initiator string
+	// CHAP credentials the initiator uses to authenticate itself to the target
+	InitiatorChapUser   string
+	InitiatorChapSecret string
+
+	// iSCSI target name (IQN)
+	Target string
+	// CHAP credentials the target uses to authenticate itself to the initiator,
+	// secret must differ from the initiator one
+	TargetChapUser   string
+	TargetChapSecret string
+}
+
+// SetMutualChap configures bidirectional CHAP: creates or updates remote initiator credentials,
+// sets target CHAP credentials and enables CHAP authentication on the target
+func (p *Provider) SetMutualChap(params SetMutualChapParams) error {
+	if params.Initiator == "" || params.Target == "" {
+		return fmt.Errorf(
+			"Parameters 'Initiator' and 'Target' are required, received: '%s', '%s'",
+			params.Initiator,
+			params.Target,
+		)
+	}
+	if err := validateChapSecret("SetMutualChapParams.InitiatorChapSecret", params.InitiatorChapSecret); err != nil {
+		return err
+	}
+	if err := validateChapSecret("SetMutualChapParams.TargetChapSecret", params.TargetChapSecret); err != nil {
+		return err
+	}
+	if params.InitiatorChapSecret == params.TargetChapSecret {
+		return &NefError{
+			Code: "EBADARG",
+			Err: fmt.Errorf(
+				"Parameters 'SetMutualChapParams.InitiatorChapSecret' and 'SetMutualChapParams.TargetChapSecret' " +
+					"must be different for mutual CHAP",
+			),
+		}
+	}
+
+	err := p.CreateRemoteInitiator(CreateRemoteInitiatorParams{
+		Name:       params.Initiator,
+		ChapUser:   params.InitiatorChapUser,
+		ChapSecret: params.InitiatorChapSecret,
+	})
+	if IsAlreadyExistNefError(err) {
+		err = p.UpdateRemoteInitiator(params.Initiator, UpdateRemoteInitiatorParams{
+			ChapUser:   params.InitiatorChapUser,
+			ChapSecret: params.InitiatorChapSecret,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	err = p.UpdateISCSITarget(params.Target, UpdateISCSITargetParams{
+		Authentication: "chap",
+		ChapUser:       params.TargetChapUser,
+		ChapSecret:     params.TargetChapSecret,
+	})
+	if err != nil {
+		return wrapChapSecretError(err, "SetMutualChapParams.TargetChapSecret", params.Target)
+	}
+
+	return nil
+}
+
+// validateChapSecret checks CHAP secret length in characters required by NexentaStor
+func validateChapSecret(field, secret string) error {
+	length := utf8.RuneCountInString(secret)
+	if length < minChapSecretLength || length > maxChapSecretLength {
+		return &NefError{
+			Code: "EBADARG",
+			Err: fmt.Errorf(
+				"Parameter '%s' must be %d-%d characters long, got %d",
+				field,
+				minChapSecretLength,
+				maxChapSecretLength,
+				length,
+			),
+		}
+	}
+	return nil
+}
+
+// wrapChapSecretError adds CHAP secret field name to EBADARG error returned by NexentaStor
+// if the error refers to the secret, other errors are returned as is
+func wrapChapSecretError(err error, field, name string) error {
+	if !IsBadArgNefError(err) || !strings.Contains(strings.ToLower(err.Error()), "secret") {
+		return err
+	}
+	return &NefError{
+		Code: "EBADARG",
+		Err:  fmt.Errorf("NexentaStor rejected '%s' for '%s': %s", field, name, err),
+	}
 }
 
 func (p *Provider) GetISCSITargets(name string) ([]ISCSITarget, error) {
@@ -1052,294 +1849,772 @@ func (p *Provider) GetISCSITargets(name string) ([]ISCSITarget, error) {
 }
 
 func (p *Provider) GetISCSITarget(name string) (target ISCSITarget, err error) {
-    if name == "" {
-        return target, fmt.Errorf("iSCSI target name is empty")
-    }
+	if name == "" {
+		return target, fmt.Errorf("iSCSI target name is empty")
+	}
 
-    uri := p.RestClient.BuildURI("san/iscsi/targets", map[string]string{
-        "name": name,
-        "fields": "name,state,authentication,alias,chapSecretSet,chapUser,portals",
-    })
-    response := nefTargetsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	uri := p.RestClient.BuildURI("san/iscsi/targets", map[string]string{
+		"name":   name,
+		"fields": "name,state,authentication,alias,chapSecretSet,chapUser,portals",
+	})
+	response := nefTargetsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+
+	if err != nil {
+		return target, err
+	}
 
-    if err != nil {
-        return target, err
-    }
+	if len(response.Data) == 0 {
+		return target, &NefError{Code: "ENOENT", Err: fmt.Errorf("iSCSI target '%s' not found", name)}
+	}
+
+	return response.Data[0], nil
+
+}
 
-    if len(response.Data) == 0 {
-        return target, &NefError{Code: "ENOENT", Err: fmt.Errorf("iSCSI target '%s' not found", name)}
-    }
+// GetISCSISessions returns active iSCSI sessions on NexentaStor
+func (p *Provider) GetISCSISessions() ([]ISCSISession, error) {
+	uri := p.RestClient.BuildURI("san/iscsi/sessions", map[string]string{
+		"fields": "initiator,target",
+	})
 
-    return response.Data[0], nil
+	response := nefISCSISessionsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
 
+	return response.Data, nil
 }
 
 // CreateISCSITargetParams - params to create new iSCSI target
 type CreateISCSITargetParams struct {
-    Name       string   `json:"name"`
-    Portals    []Portal `json:"portals"`
+	Name    string   `json:"name"`
+	Portals []Portal `json:"portals"`
+	Alias   string   `json:"alias,omitempty"`
+	// authentication method: "none" or "chap"
+	Authentication string `json:"authentication,omitempty"`
+	// target CHAP credentials, used by initiators for mutual (bidirectional) CHAP
+	ChapUser   string `json:"chapUser,omitempty"`
+	ChapSecret string `json:"chapSecret,omitempty"`
+
+	// If set to `true` and the target already exists, it gets updated to match the params,
+	// otherwise existing target with different portals causes EEXIST error
+	Ensure bool `json:"-"`
+}
+
+// CreateISCSITarget - create new iSCSI target on NexentaStor, returns created target
+// Existing target with the same portals is not treated as an error (see CreateISCSITargetParams.Ensure)
+func (p *Provider) CreateISCSITarget(params CreateISCSITargetParams) (ISCSITarget, error) {
+	err := p.createISCSITarget(params)
+	if err != nil {
+		return ISCSITarget{}, err
+	}
+
+	return p.GetISCSITarget(params.Name)
 }
 
-// CreateISCSITarget - create new iSCSI target on NexentaStor
-func (p *Provider) CreateISCSITarget (params CreateISCSITargetParams) error {
-    if params.Name == "" {
-        return fmt.Errorf("Parameters 'Name' and 'Portal' are required, received: %+v", params)
-    }
-    err := p.sendRequest(http.MethodPost, "san/iscsi/targets", params)
-    if !IsAlreadyExistNefError(err) {
-        return err
-    }
-    return nil
+func (p *Provider) createISCSITarget(params CreateISCSITargetParams) error {
+	if params.Name == "" {
+		return fmt.Errorf("Parameters 'Name' and 'Portal' are required, received: %+v", params)
+	}
+	if params.ChapSecret != "" {
+		if err := validateChapSecret("CreateISCSITargetParams.ChapSecret", params.ChapSecret); err != nil {
+			return err
+		}
+	}
+	err := p.sendRequest(http.MethodPost, "san/iscsi/targets", params)
+	if !IsAlreadyExistNefError(err) {
+		return err
+	}
+
+	target, getErr := p.GetISCSITarget(params.Name)
+	if getErr != nil {
+		return fmt.Errorf("iSCSI target '%s' already exists, but cannot be read: %s", params.Name, getErr)
+	}
+
+	updateParams := UpdateISCSITargetParams{}
+	if !portalsEqual(target.Portals, params.Portals) {
+		updateParams.Portals = params.Portals
+	}
+	if params.Alias != "" && params.Alias != target.Alias {
+		updateParams.Alias = params.Alias
+	}
+	if params.Authentication != "" && params.Authentication != target.Authentication {
+		updateParams.Authentication = params.Authentication
+	}
+	if params.ChapUser != "" && params.ChapUser != target.ChapUser {
+		updateParams.ChapUser = params.ChapUser
+	}
+
+	if !params.Ensure {
+		if updateParams.Portals != nil {
+			return &NefError{
+				Code: "EEXIST",
+				Err: fmt.Errorf(
+					"iSCSI target '%s' already exists with different portals: %+v, expected: %+v",
+					params.Name,
+					target.Portals,
+					params.Portals,
+				),
+			}
+		}
+		return nil
+	}
+
+	// secret cannot be read back, so it is always sent if specified
+	updateParams.ChapSecret = params.ChapSecret
+	if updateParams.Portals == nil && updateParams.Alias == "" && updateParams.Authentication == "" &&
+		updateParams.ChapUser == "" && updateParams.ChapSecret == "" {
+		return nil
+	}
+
+	return p.UpdateISCSITarget(params.Name, updateParams)
+}
+
+// portalsEqual returns true if both lists have the same portals regardless of their order
+func portalsEqual(a, b []Portal) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(portal Portal) Portal {
+		if portal.Port == 0 {
+			portal.Port = defaultISCSIPort
+		}
+		return portal
+	}
+	counts := map[Portal]int{}
+	for _, portal := range a {
+		counts[normalize(portal)]++
+	}
+	for _, portal := range b {
+		portal = normalize(portal)
+		if counts[portal] == 0 {
+			return false
+		}
+		counts[portal]--
+	}
+	return true
 }
 
-// UpdateISCSITargetParams - params to update existing iSCSI target
+// UpdateISCSITargetParams - params to update existing iSCSI target, not set params are not sent
 type UpdateISCSITargetParams struct {
-    Authentication         string   `json:"authentication"`
+	// authentication method: "none" or "chap"
+	Authentication string   `json:"authentication,omitempty"`
+	Portals        []Portal `json:"portals,omitempty"`
+	Alias          string   `json:"alias,omitempty"`
+	// target CHAP credentials, used by initiators for mutual (bidirectional) CHAP
+	ChapUser   string `json:"chapUser,omitempty"`
+	ChapSecret string `json:"chapSecret,omitempty"`
 }
 
 // UpdateISCSITarget - update existing iSCSI target
 func (p *Provider) UpdateISCSITarget(name string, params UpdateISCSITargetParams) (err error) {
-    if name == "" {
-        return fmt.Errorf("iSCSI target name must not be empty.")
-    }
+	if name == "" {
+		return fmt.Errorf("iSCSI target name must not be empty.")
+	}
 
-    uri :=  fmt.Sprintf("san/iscsi/targets/%s", url.PathEscape(name))
-    return p.sendRequest(http.MethodPut, uri, params)
+	if params.ChapSecret != "" {
+		if err := validateChapSecret("UpdateISCSITargetParams.ChapSecret", params.ChapSecret); err != nil {
+			return err
+		}
+	}
+
+	uri := fmt.Sprintf("san/iscsi/targets/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
+
+// DeleteISCSITarget - delete iSCSI target by name
+func (p *Provider) DeleteISCSITarget(name string) error {
+	if name == "" {
+		return fmt.Errorf("iSCSI target name must not be empty.")
+	}
+
+	uri := fmt.Sprintf("san/iscsi/targets/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // GetTargetGroups - returns the list of targetGroups on NexentaStor
-func (p* Provider) GetTargetGroups() ([]TargetGroup, error) {
-    response := nefTargetGroupsResponse{}
-    err := p.sendRequestWithStruct(http.MethodGet, "san/targetgroups", nil, &response)
-    if err != nil {
-        return nil, err
-    }
+func (p *Provider) GetTargetGroups() ([]TargetGroup, error) {
+	response := nefTargetGroupsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, "san/targetgroups", nil, &response)
+	if err != nil {
+		return nil, err
+	}
 
-    return response.Data, nil
+	return response.Data, nil
 }
 
 // GetTargetGroup returns TargetGroup by its name
 func (p *Provider) GetTargetGroup(name string) (targetGroup TargetGroup, err error) {
-    if name == "" {
-        return targetGroup, fmt.Errorf("targetGroup name is empty")
-    }
+	if name == "" {
+		return targetGroup, fmt.Errorf("targetGroup name is empty")
+	}
 
-    uri := p.RestClient.BuildURI(fmt.Sprintf("san/targetgroups/%s", url.PathEscape(name)), map[string]string{
-        "fields": "name,members",
-    })
+	uri := p.RestClient.BuildURI(fmt.Sprintf("san/targetgroups/%s", url.PathEscape(name)), map[string]string{
+		"fields": "name,members",
+	})
 
-    err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &targetGroup)
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &targetGroup)
 
-    return targetGroup, err
+	return targetGroup, err
 }
 
 // CreateTargetGroupParams - params to create target group
 type CreateTargetGroupParams struct {
-    Name       string    `json:"name"`
-    Members    []string  `json:"members"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
 }
 
 // UpdateTargetGroupParams - params to update existing target group
 type UpdateTargetGroupParams struct {
-    Members     []string    `json:"members"`
+	Members []string `json:"members"`
 }
 
-// CreateUpdateTargetGroup - create new target group on NexentaStor
+// CreateUpdateTargetGroup - create new target group on NexentaStor or replace members of existing one,
+// use AddTargetGroupMembers() to add members to a group which may be updated by other clients
 func (p *Provider) CreateUpdateTargetGroup(params CreateTargetGroupParams) error {
-    if params.Name == "" || len(params.Members) == 0 {
-        return fmt.Errorf(
-            "Parameters 'Name' and 'Members' are required, received: %+v", params)
-    }
-    err := p.sendRequest(http.MethodPost, "san/targetgroups", params)
-    if err != nil {
-        if !IsAlreadyExistNefError(err) {
-            return err
-        } else {
-            uri :=  fmt.Sprintf("san/targetgroups/%s", url.PathEscape(params.Name))
-            err = p.sendRequest(http.MethodPut, uri, UpdateTargetGroupParams{
-                Members: params.Members,
-            })
-            if err != nil {
-                return err
-            }
-        }
-    }
-    return nil
+	if params.Name == "" || len(params.Members) == 0 {
+		return fmt.Errorf(
+			"Parameters 'Name' and 'Members' are required, received: %+v", params)
+	}
+	err := p.sendRequest(http.MethodPost, "san/targetgroups", params)
+	if err != nil {
+		if !IsAlreadyExistNefError(err) {
+			return err
+		} else {
+			err = p.UpdateTargetGroup(params.Name, UpdateTargetGroupParams{
+				Members: params.Members,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateTargetGroup replaces target group members
+func (p *Provider) UpdateTargetGroup(name string, params UpdateTargetGroupParams) error {
+	if name == "" {
+		return fmt.Errorf("Parameter 'name' is required to update targetGroup")
+	}
+
+	uri := fmt.Sprintf("san/targetgroups/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
+
+// AddTargetGroupMembers adds targets to the target group keeping its current members,
+// target group gets created if it doesn't exist
+func (p *Provider) AddTargetGroupMembers(name string, members []string) error {
+	return p.updateTargetGroupMembers(name, members, nil)
+}
+
+// RemoveTargetGroupMembers removes targets from the target group keeping its other members
+func (p *Provider) RemoveTargetGroupMembers(name string, members []string) error {
+	return p.updateTargetGroupMembers(name, nil, members)
+}
+
+func (p *Provider) updateTargetGroupMembers(name string, add, remove []string) error {
+	if name == "" {
+		return fmt.Errorf("TargetGroup name is required")
+	}
+
+	return p.updateGroupMembers(groupMembersOperations{
+		kind:   "targetGroup",
+		name:   name,
+		add:    add,
+		remove: remove,
+		get: func() ([]string, error) {
+			targetGroup, err := p.GetTargetGroup(name)
+			return targetGroup.Members, err
+		},
+		create: func(members []string) error {
+			err := p.sendRequest(http.MethodPost, "san/targetgroups", CreateTargetGroupParams{
+				Name:    name,
+				Members: members,
+			})
+			if !IsAlreadyExistNefError(err) {
+				return err
+			}
+			return nil
+		},
+		update: func(members []string) error {
+			return p.UpdateTargetGroup(name, UpdateTargetGroupParams{Members: members})
+		},
+	})
+}
+
+// DeleteTargetGroup deletes target group, fails with EBUSY code if there are LUN mappings using the group
+func (p *Provider) DeleteTargetGroup(name string) error {
+	if name == "" {
+		return fmt.Errorf("Parameter 'name' is required to delete targetGroup")
+	}
+
+	lunMappings, err := p.GetLunMappings(GetLunMappingsParams{TargetGroup: name})
+	if err != nil {
+		return fmt.Errorf("Failed to get LUN mappings of targetGroup '%s': %s", name, err)
+	} else if len(lunMappings) > 0 {
+		volumes := []string{}
+		for _, lunMapping := range lunMappings {
+			volumes = append(volumes, lunMapping.Volume)
+		}
+		return &NefError{
+			Code: "EBUSY",
+			Err: fmt.Errorf(
+				"TargetGroup '%s' is used by %d LUN mapping(s) of volumes: %v",
+				name,
+				len(lunMappings),
+				volumes,
+			),
+		}
+	}
+
+	uri := fmt.Sprintf("san/targetgroups/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // CreateLunMappingParams - params to create new lun
 type CreateLunMappingParams struct {
-    HostGroup   string `json:"hostGroup"`
-    Volume      string `json:"volume"`
-    TargetGroup string `json:"targetGroup"`
-}
+	HostGroup   string `json:"hostGroup"`
+	Volume      string `json:"volume"`
+	TargetGroup string `json:"targetGroup"`
+	// optional LUN number, NS picks the first free one if not set
+	Lun *int `json:"lun,omitempty"`
+}
+
+// CreateLunMapping - creates lun for given volume, returns created or already existing LUN mapping
+// Existing mapping with a LUN number different from requested one causes EEXIST error
+func (p *Provider) CreateLunMapping(params CreateLunMappingParams) (LunMapping, error) {
+	if params.HostGroup == "" || params.Volume == "" || params.TargetGroup == "" {
+		return LunMapping{}, fmt.Errorf(
+			"Parameters 'HostGroup', 'Target' and 'TargetGroup' are required, received: %+v", params)
+	}
+	createErr := p.sendRequest(http.MethodPost, "san/lunMappings", params)
+	if createErr != nil && !IsAlreadyExistNefError(createErr) {
+		return LunMapping{}, createErr
+	}
+	alreadyExists := createErr != nil
+
+	lunMappings, err := p.GetLunMappings(GetLunMappingsParams{
+		Volume:      params.Volume,
+		TargetGroup: params.TargetGroup,
+		HostGroup:   params.HostGroup,
+	})
+	if err != nil {
+		return LunMapping{}, err
+	} else if len(lunMappings) == 0 {
+		if alreadyExists {
+			// EEXIST is caused by other object, e.g. the requested LUN is used by other volume
+			return LunMapping{}, createErr
+		}
+		return LunMapping{}, &NefError{
+			Code: "ENOENT",
+			Err:  fmt.Errorf("LUN mapping of volume '%s' not found after creation", params.Volume),
+		}
+	}
+
+	lunMapping := lunMappings[0]
+	if alreadyExists && params.Lun != nil && *params.Lun != lunMapping.Lun {
+		return lunMapping, &NefError{
+			Code: "EEXIST",
+			Err: fmt.Errorf(
+				"LUN mapping '%s' of volume '%s' already exists with LUN %d, requested LUN %d",
+				lunMapping.Id,
+				params.Volume,
+				lunMapping.Lun,
+				*params.Lun,
+			),
+		}
+	}
 
-// CreateLunMapping - creates lun for given volume
-func (p *Provider) CreateLunMapping(params CreateLunMappingParams) error {
-    if params.HostGroup == "" || params.Volume == "" || params.TargetGroup == "" {
-        return fmt.Errorf(
-            "Parameters 'HostGroup', 'Target' and 'TargetGroup' are required, received: %+v", params)
-    }
-    err := p.sendRequest(http.MethodPost, "san/lunMappings", params)
-    if !IsAlreadyExistNefError(err) {
-        return err
-    }
-    return nil
+	return lunMapping, nil
 }
 
+// DestroyVolumeParams - volume deletion parameters
 type DestroyVolumeParams struct {
-    DestroySnapshots bool
-    PromoteMostRecentCloneIfExists bool
+	// If set to `true`, then tries to destroy volume's snapshots as well,
+	// see DestroyFilesystemParams.DestroySnapshots for details.
+	DestroySnapshots bool
+
+	// If set to `true`, then the most recent snapshot clone will be promoted,
+	// see DestroyFilesystemParams.PromoteMostRecentCloneIfExists for details.
+	PromoteMostRecentCloneIfExists bool
+
+	// If set to `true`, then all LUN mappings of the volume are removed before the volume deletion
+	DestroyLunMappings bool
+
+	// If set to `true`, then the logical unit of the volume is removed before the volume deletion
+	DestroyLogicalUnit bool
+
+	// If set to `true`, then LUN mappings and logical unit are removed even if the volume
+	// is accessed by iSCSI initiators at the moment, otherwise deletion fails with EBUSY code
+	Force bool
+}
+
+// DestroyVolumeResult - SAN objects removed by DestroyVolume(),
+// objects which had already been deleted by someone else are not listed
+type DestroyVolumeResult struct {
+	RemovedLunMappings  []LunMapping
+	RemovedLogicalUnits []LogicalUnit
 }
 
 func (p *Provider) DestroyLunMapping(id string) error {
-    if id == "" {
-        return fmt.Errorf("LunMapping id is required")
-    }
-
-    uri := fmt.Sprintf("san/lunMappings/%s", id)
-
-    return p.sendRequest(http.MethodDelete, uri, nil)
-}
-
-func (p *Provider) DestroyVolume(path string, params DestroyVolumeParams) error {
-    err := p.destroyVolume(path, params.DestroySnapshots)
-    if err == nil {
-        return nil
-    } else if !params.PromoteMostRecentCloneIfExists || !IsAlreadyExistNefError(err) {
-        return err
-    }
-
-    // If here then volume deletion request has failed because
-    // the volume has dependent clones (EEXIST error code), trying
-    // to promote the most recent clone to make the volume independent:
-
-    maxAttemptCount := 3
-    var mostRecentError error
-
-    for i := 0; i < maxAttemptCount; i++ {
-        mostRecentError = nil
-
-        snapshots, err := p.GetSnapshots(path, true)
-        if err != nil {
-            mostRecentError = fmt.Errorf("failed to get snapshot list: %s", err)
-            break
-        }
-
-        var maxCreationTxg int
-        var mostRecentClone string
-        for _, s := range snapshots {
-            // to get "clones" and "creationTxg" fields that are not presented in the list response
-            snapshot, err := p.GetSnapshot(s.Path)
-            if err != nil {
-                mostRecentError = fmt.Errorf("failed to get '%s' snapshost's info: %s", s.Path, err)
-                break
-            }
-            creationTxg, err := strconv.Atoi(snapshot.CreationTxg)
-            if err != nil {
-                mostRecentError = fmt.Errorf(
-                    "snapshot '%s': failed to convert 'creationTxg' value '%s' to integer: %s",
-                    s.Path,
-                    snapshot.CreationTxg,
-                    err,
-                )
-                break
-            } else if len(snapshot.Clones) > 0 && creationTxg > maxCreationTxg {
-                mostRecentClone = snapshot.Clones[0]
-                maxCreationTxg = creationTxg
-            }
-        }
-        if mostRecentError != nil {
-            // Failed to determine the most recent clone.
-            // Give another chance (or exit if max attempt count exceeded) if any error happened
-            // while getting each snaphost's information. For example, the snapshot got deleted
-            // right after snapshot list request, but before requesting its information.
-            continue
-        }
-
-        if mostRecentClone != "" {
-            err := p.PromoteVolume(mostRecentClone)
-            if err != nil {
-                mostRecentError = fmt.Errorf("failed to promote clone '%s': %s", mostRecentClone, err)
-                continue
-            }
-        }
-
-        mostRecentError = p.destroyVolume(path, params.DestroySnapshots)
-        if mostRecentError == nil {
-            return nil
-        } else if !IsAlreadyExistNefError(mostRecentError) { // if EEXIST code - volume still has dependent clones
-            break
-        }
-    }
-
-    // if not a NefError, wrap it into an explanation
-    if !IsNefError(mostRecentError) {
-        return fmt.Errorf("Failed to delete volume '%s': %s", path, mostRecentError)
-    }
-
-    return mostRecentError
+	if id == "" {
+		return fmt.Errorf("LunMapping id is required")
+	}
+
+	uri := fmt.Sprintf("san/lunMappings/%s", id)
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// DestroyVolume destroys volume on NS, may remove its LUN mappings and logical unit,
+// destroy snapshots and promote clones (see DestroyVolumeParams)
+// Path format: 'pool/volumeGroup/volume'
+func (p *Provider) DestroyVolume(path string, params DestroyVolumeParams) (DestroyVolumeResult, error) {
+	result := DestroyVolumeResult{}
+
+	if params.DestroyLunMappings || params.DestroyLogicalUnit {
+		err := p.removeVolumeSanExposure(path, params, &result)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, p.destroyVolumeWithClones(path, params)
+}
+
+// removeVolumeSanExposure removes LUN mappings and logical unit of the volume,
+// refuses to do it if there are active iSCSI sessions to the volume targets, unless forced
+func (p *Provider) removeVolumeSanExposure(path string, params DestroyVolumeParams, result *DestroyVolumeResult) error {
+	l := p.Log.WithField("func", "removeVolumeSanExposure()")
+
+	if path == "" {
+		return fmt.Errorf("Volume path is required")
+	}
+
+	lunMappings, err := p.GetLunMappings(GetLunMappingsParams{Volume: path})
+	if err != nil {
+		return fmt.Errorf("Failed to get LUN mappings of volume '%s': %s", path, err)
+	}
+
+	if len(lunMappings) > 0 && !params.Force {
+		sessions, err := p.getVolumeISCSISessions(lunMappings)
+		if err != nil {
+			return fmt.Errorf("Failed to check iSCSI sessions of volume '%s': %s", path, err)
+		} else if len(sessions) > 0 {
+			initiators := []string{}
+			for _, session := range sessions {
+				initiators = append(initiators, session.Initiator)
+			}
+			return &NefError{
+				Code: "EBUSY",
+				Err: fmt.Errorf(
+					"Volume '%s' has %d active iSCSI session(s) from %v, use 'Force' parameter to destroy it anyway",
+					path,
+					len(sessions),
+					initiators,
+				),
+			}
+		}
+	}
+
+	if params.DestroyLunMappings {
+		for _, lunMapping := range lunMappings {
+			l.Debugf("destroy LUN mapping '%s' of volume '%s'", lunMapping.Id, path)
+			err := p.DestroyLunMapping(lunMapping.Id)
+			if IsNotExistNefError(err) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("Failed to destroy LUN mapping '%s' of volume '%s': %s", lunMapping.Id, path, err)
+			}
+			result.RemovedLunMappings = append(result.RemovedLunMappings, lunMapping)
+		}
+	}
+
+	if params.DestroyLogicalUnit {
+		logicalUnit, err := p.GetLogicalUnitByVolume(path)
+		if err != nil && !IsNotExistNefError(err) {
+			return fmt.Errorf("Failed to get logical unit of volume '%s': %s", path, err)
+		} else if err == nil {
+			l.Debugf("destroy logical unit '%s' of volume '%s'", logicalUnit.Guid, path)
+			err := p.DeleteLogicalUnit(logicalUnit.Guid)
+			if err != nil && !IsNotExistNefError(err) {
+				return fmt.Errorf("Failed to destroy logical unit '%s' of volume '%s': %s", logicalUnit.Guid, path, err)
+			} else if err == nil {
+				result.RemovedLogicalUnits = append(result.RemovedLogicalUnits, logicalUnit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getVolumeISCSISessions returns active iSCSI sessions which have access to given LUN mappings
+func (p *Provider) getVolumeISCSISessions(lunMappings []LunMapping) ([]ISCSISession, error) {
+	sessions, err := p.GetISCSISessions()
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+
+	hostGroups, err := p.GetHostGroups()
+	if err != nil {
+		return nil, err
+	}
+	hostGroupMembers := map[string][]string{}
+	for _, hostGroup := range hostGroups {
+		hostGroupMembers[hostGroup.Name] = hostGroup.Members
+	}
+
+	targetGroupMembers := map[string][]string{}
+	for _, lunMapping := range lunMappings {
+		if _, ok := targetGroupMembers[lunMapping.TargetGroup]; ok || lunMapping.TargetGroup == "All" {
+			continue
+		}
+		targetGroup, err := p.GetTargetGroup(lunMapping.TargetGroup)
+		if err != nil {
+			return nil, err
+		}
+		targetGroupMembers[lunMapping.TargetGroup] = targetGroup.Members
+	}
+
+	volumeSessions := []ISCSISession{}
+	for _, session := range sessions {
+		for _, lunMapping := range lunMappings {
+			targetMatch := lunMapping.TargetGroup == "All" ||
+				stringArrayContains(targetGroupMembers[lunMapping.TargetGroup], session.Target)
+			initiatorMatch := lunMapping.HostGroup == "All" ||
+				stringArrayContains(hostGroupMembers[lunMapping.HostGroup], session.Initiator)
+			if targetMatch && initiatorMatch {
+				volumeSessions = append(volumeSessions, session)
+				break
+			}
+		}
+	}
+
+	return volumeSessions, nil
+}
+
+func stringArrayContains(array []string, value string) bool {
+	for _, v := range array {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) destroyVolumeWithClones(path string, params DestroyVolumeParams) error {
+	err := p.destroyVolume(path, params.DestroySnapshots)
+	if err == nil {
+		return nil
+	} else if !params.PromoteMostRecentCloneIfExists || !IsAlreadyExistNefError(err) {
+		return err
+	}
+
+	// If here then volume deletion request has failed because
+	// the volume has dependent clones (EEXIST error code), trying
+	// to promote the most recent clone to make the volume independent:
+
+	maxAttemptCount := 3
+	var mostRecentError error
+
+	for i := 0; i < maxAttemptCount; i++ {
+		mostRecentError = nil
+
+		snapshots, err := p.GetSnapshots(path, true)
+		if err != nil {
+			mostRecentError = fmt.Errorf("failed to get snapshot list: %s", err)
+			break
+		}
+
+		var maxCreationTxg int
+		var mostRecentClone string
+		for _, s := range snapshots {
+			// to get "clones" and "creationTxg" fields that are not presented in the list response
+			snapshot, err := p.GetSnapshot(s.Path)
+			if err != nil {
+				mostRecentError = fmt.Errorf("failed to get '%s' snapshost's info: %s", s.Path, err)
+				break
+			}
+			creationTxg, err := strconv.Atoi(snapshot.CreationTxg)
+			if err != nil {
+				mostRecentError = fmt.Errorf(
+					"snapshot '%s': failed to convert 'creationTxg' value '%s' to integer: %s",
+					s.Path,
+					snapshot.CreationTxg,
+					err,
+				)
+				break
+			} else if len(snapshot.Clones) > 0 && creationTxg > maxCreationTxg {
+				mostRecentClone = snapshot.Clones[0]
+				maxCreationTxg = creationTxg
+			}
+		}
+		if mostRecentError != nil {
+			// Failed to determine the most recent clone.
+			// Give another chance (or exit if max attempt count exceeded) if any error happened
+			// while getting each snaphost's information. For example, the snapshot got deleted
+			// right after snapshot list request, but before requesting its information.
+			continue
+		}
+
+		if mostRecentClone != "" {
+			err := p.PromoteVolume(mostRecentClone)
+			if err != nil {
+				mostRecentError = fmt.Errorf("failed to promote clone '%s': %s", mostRecentClone, err)
+				continue
+			}
+		}
+
+		mostRecentError = p.destroyVolume(path, params.DestroySnapshots)
+		if mostRecentError == nil {
+			return nil
+		} else if !IsAlreadyExistNefError(mostRecentError) { // if EEXIST code - volume still has dependent clones
+			break
+		}
+	}
+
+	// if not a NefError, wrap it into an explanation
+	if !IsNefError(mostRecentError) {
+		return fmt.Errorf("Failed to delete volume '%s': %s", path, mostRecentError)
+	}
+
+	return mostRecentError
 }
 
 func (p *Provider) destroyVolume(path string, destroySnapshots bool) error {
-    if path == "" {
-        return fmt.Errorf("Filesystem path is required")
-    }
+	if path == "" {
+		return fmt.Errorf("Filesystem path is required")
+	}
 
-    uri := p.RestClient.BuildURI(
-        fmt.Sprintf("storage/volumes/%s", url.PathEscape(path)),
-        map[string]string{
-            "snapshots": strconv.FormatBool(destroySnapshots),
-        },
-    )
+	uri := p.RestClient.BuildURI(
+		fmt.Sprintf("storage/volumes/%s", url.PathEscape(path)),
+		map[string]string{
+			"snapshots": strconv.FormatBool(destroySnapshots),
+		},
+	)
 
-    return p.sendRequest(http.MethodDelete, uri, nil)
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
 // CreateHostGroupParams - params to create a hostGroup
 type CreateHostGroupParams struct {
-    // list of IQNs for the hostGroup
-    Members []string `json:"members"`
-    // a unique name for the hostGroup
-    Name string `json:"name"`
+	// list of IQNs for the hostGroup
+	Members []string `json:"members"`
+	// a unique name for the hostGroup
+	Name string `json:"name"`
 }
 
-func (p *Provider) CreateHostGroup(params CreateHostGroupParams) error {
-    if params.Name == "" || len(params.Members) == 0 {
-        return fmt.Errorf("HostGroup name and members cannot be empty, got %+v", params)
-    }
+// CreateHostGroup creates hostGroup, existing hostGroup with the same name is not treated as an error
+// Returns created or existing hostGroup
+func (p *Provider) CreateHostGroup(params CreateHostGroupParams) (HostGroup, error) {
+	err := p.createHostGroup(params)
+	if err != nil {
+		return HostGroup{}, err
+	}
 
-    err := p.sendRequest(http.MethodPost, "san/hostgroups", params)
-    if !IsAlreadyExistNefError(err) {
-        return err
-    }
-    return nil
+	return p.GetHostGroup(params.Name)
 }
 
-func (p *Provider) GetHostGroups() (hostGroups []nefHostGroup, err error) {
-    response := nefHostGroupsResponse{}
-    err = p.sendRequestWithStruct(http.MethodGet, "san/hostgroups", nil, &response)
-    if err != nil {
-        return hostGroups, err
-    }
+func (p *Provider) createHostGroup(params CreateHostGroupParams) error {
+	if params.Name == "" || len(params.Members) == 0 {
+		return fmt.Errorf("HostGroup name and members cannot be empty, got %+v", params)
+	}
+
+	err := p.sendRequest(http.MethodPost, "san/hostgroups", params)
+	if !IsAlreadyExistNefError(err) {
+		return err
+	}
+	return nil
+}
+
+// GetHostGroups returns all hostGroups on NexentaStor
+func (p *Provider) GetHostGroups() (hostGroups []HostGroup, err error) {
+	response := nefHostGroupsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, "san/hostgroups", nil, &response)
+	if err != nil {
+		return hostGroups, err
+	}
+
+	return response.Data, nil
+}
+
+// GetHostGroup returns hostGroup by its name
+func (p *Provider) GetHostGroup(name string) (hostGroup HostGroup, err error) {
+	if name == "" {
+		return hostGroup, fmt.Errorf("hostGroup name is empty")
+	}
+
+	uri := p.RestClient.BuildURI(fmt.Sprintf("san/hostgroups/%s", url.PathEscape(name)), map[string]string{
+		"fields": "name,members",
+	})
+
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &hostGroup)
 
-    return response.Data, nil
+	return hostGroup, err
 }
 
 // UpdateHostGroupParams - params to update a hostGroup
 type UpdateHostGroupParams struct {
-    // list of IQNs for the hostGroup
-    Members []string `json:"members"`
+	// list of IQNs for the hostGroup
+	Members []string `json:"members"`
+}
+
+// UpdateHostGroup replaces hostGroup members, use AddHostGroupMembers() and RemoveHostGroupMembers()
+// to change members list safely when the hostGroup may be updated by other clients
+func (p *Provider) UpdateHostGroup(name string, params UpdateHostGroupParams) error {
+	if name == "" {
+		return fmt.Errorf("Parameter 'name' is required to update hostGroup")
+	}
+
+	uri := fmt.Sprintf("san/hostgroups/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
+
+// DeleteHostGroup deletes hostGroup by its name
+func (p *Provider) DeleteHostGroup(name string) error {
+	if name == "" {
+		return fmt.Errorf("Parameter 'name' is required to delete hostGroup")
+	}
+
+	uri := fmt.Sprintf("san/hostgroups/%s", url.PathEscape(name))
+	return p.sendRequest(http.MethodDelete, uri, nil)
 }
 
-func (p *Provider) UpdateHostGroup(path string, params UpdateHostGroupParams) error {
-    if path == "" {
-        return fmt.Errorf("Parameter 'path' is required to update hostGroup")
-    }
+// AddHostGroupMembers adds initiators to the hostGroup keeping its current members,
+// hostGroup gets created if it doesn't exist
+func (p *Provider) AddHostGroupMembers(name string, members []string) error {
+	return p.updateHostGroupMembers(name, members, nil)
+}
 
-    uri :=  fmt.Sprintf("storage/hostgroups/%s", url.PathEscape(path))
-    return p.sendRequest(http.MethodPut, uri, params)
+// RemoveHostGroupMembers removes initiators from the hostGroup keeping its other members
+func (p *Provider) RemoveHostGroupMembers(name string, members []string) error {
+	return p.updateHostGroupMembers(name, nil, members)
+}
+
+func (p *Provider) updateHostGroupMembers(name string, add, remove []string) error {
+	if name == "" {
+		return fmt.Errorf("HostGroup name is required")
+	}
+
+	return p.updateGroupMembers(groupMembersOperations{
+		kind:   "hostGroup",
+		name:   name,
+		add:    add,
+		remove: remove,
+		get: func() ([]string, error) {
+			hostGroup, err := p.GetHostGroup(name)
+			return hostGroup.Members, err
+		},
+		create: func(members []string) error {
+			return p.createHostGroup(CreateHostGroupParams{Name: name, Members: members})
+		},
+		update: func(members []string) error {
+			return p.UpdateHostGroup(name, UpdateHostGroupParams{Members: members})
+		},
+	})
 }
 
 // GetLogicalUnitsSlice returns a slice of logicalUnits with specified limit and offset
@@ -1398,6 +2673,108 @@ func (p *Provider) GetLogicalUnits() ([]LogicalUnit, error) {
 	return LogicalUnits, nil
 }
 
+// GetLogicalUnit returns NexentaStor logicalUnit by its GUID
+func (p *Provider) GetLogicalUnit(guid string) (logicalUnit LogicalUnit, err error) {
+	if guid == "" {
+		return logicalUnit, fmt.Errorf("LogicalUnit guid is empty")
+	}
+
+	uri := p.RestClient.BuildURI("san/logicalUnits", map[string]string{
+		"guid": guid,
+	})
+
+	response := nefSanLogicalUnitsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return logicalUnit, err
+	}
+
+	if len(response.Data) == 0 {
+		return logicalUnit, &NefError{Code: "ENOENT", Err: fmt.Errorf("LogicalUnit '%s' not found", guid)}
+	}
+
+	return response.Data[0], nil
+}
+
+// GetLogicalUnitByVolume returns NexentaStor logicalUnit created for the volume
+func (p *Provider) GetLogicalUnitByVolume(volume string) (logicalUnit LogicalUnit, err error) {
+	if volume == "" {
+		return logicalUnit, fmt.Errorf("Volume path is empty")
+	}
+
+	uri := p.RestClient.BuildURI("san/logicalUnits", map[string]string{
+		"volume": volume,
+	})
+
+	response := nefSanLogicalUnitsResponse{}
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return logicalUnit, err
+	}
+
+	if len(response.Data) == 0 {
+		return logicalUnit, &NefError{Code: "ENOENT", Err: fmt.Errorf("LogicalUnit of volume '%s' not found", volume)}
+	}
+
+	return response.Data[0], nil
+}
+
+// CreateLogicalUnitParams - params to create logicalUnit for a volume, not set optional params are not sent
+type CreateLogicalUnitParams struct {
+	// volume path w/o leading slash
+	Volume string `json:"volume"`
+	Alias  string `json:"alias,omitempty"`
+	// logical block size in bytes reported to initiators
+	BlockSize              int   `json:"blockSize,omitempty"`
+	WriteProtect           *bool `json:"writeProtect,omitempty"`
+	WritebackCacheDisabled *bool `json:"writebackCacheDisabled,omitempty"`
+}
+
+// CreateLogicalUnit creates logicalUnit for a volume, returns created logicalUnit
+func (p *Provider) CreateLogicalUnit(params CreateLogicalUnitParams) (LogicalUnit, error) {
+	if params.Volume == "" {
+		return LogicalUnit{}, fmt.Errorf("Parameter 'CreateLogicalUnitParams.Volume' is required")
+	}
+
+	err := p.sendRequest(http.MethodPost, "san/logicalUnits", params)
+	if err != nil {
+		return LogicalUnit{}, err
+	}
+
+	return p.GetLogicalUnitByVolume(params.Volume)
+}
+
+// UpdateLogicalUnitParams - params to update logicalUnit, not set params are not sent
+type UpdateLogicalUnitParams struct {
+	Alias string `json:"alias,omitempty"`
+	// logical block size in bytes reported to initiators
+	BlockSize int `json:"blockSize,omitempty"`
+	// set to `true` to reject writes from initiators (e.g. for forensic holds)
+	WriteProtect *bool `json:"writeProtect,omitempty"`
+	// set to `true` to disable writeback cache (e.g. for databases)
+	WritebackCacheDisabled *bool `json:"writebackCacheDisabled,omitempty"`
+}
+
+// UpdateLogicalUnit updates logicalUnit by its GUID
+func (p *Provider) UpdateLogicalUnit(guid string, params UpdateLogicalUnitParams) error {
+	if guid == "" {
+		return fmt.Errorf("Parameter 'guid' is required")
+	}
+
+	uri := fmt.Sprintf("san/logicalUnits/%s", url.PathEscape(guid))
+	return p.sendRequest(http.MethodPut, uri, params)
+}
+
+// DeleteLogicalUnit deletes logicalUnit by its GUID, the volume itself is not deleted
+func (p *Provider) DeleteLogicalUnit(guid string) error {
+	if guid == "" {
+		return fmt.Errorf("Parameter 'guid' is required")
+	}
+
+	uri := fmt.Sprintf("san/logicalUnits/%s", url.PathEscape(guid))
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
 func (p *Provider) RebootNode() error {
 	uri := p.RestClient.BuildURI("node/reboot", map[string]string{})
 	return p.sendRequest(http.MethodPost, uri, nil)
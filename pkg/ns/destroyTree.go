@@ -0,0 +1,453 @@
+package ns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DestroyTreeActionType - type of a single step of DestroyTree() plan
+type DestroyTreeActionType string
+
+const (
+	// DestroyTreeActionUnshareNfs - delete NFS share of a filesystem
+	DestroyTreeActionUnshareNfs DestroyTreeActionType = "unshareNfs"
+
+	// DestroyTreeActionUnshareSmb - delete SMB share of a filesystem
+	DestroyTreeActionUnshareSmb DestroyTreeActionType = "unshareSmb"
+
+	// DestroyTreeActionDestroyLunMapping - delete LUN mapping of a volume
+	DestroyTreeActionDestroyLunMapping DestroyTreeActionType = "destroyLunMapping"
+
+	// DestroyTreeActionDestroySnapshot - delete snapshot which has no clones outside of the tree
+	DestroyTreeActionDestroySnapshot DestroyTreeActionType = "destroySnapshot"
+
+	// DestroyTreeActionDestroyVolume - destroy volume
+	DestroyTreeActionDestroyVolume DestroyTreeActionType = "destroyVolume"
+
+	// DestroyTreeActionDestroyVolumeGroup - destroy volumeGroup
+	DestroyTreeActionDestroyVolumeGroup DestroyTreeActionType = "destroyVolumeGroup"
+
+	// DestroyTreeActionDestroyFilesystem - destroy filesystem
+	DestroyTreeActionDestroyFilesystem DestroyTreeActionType = "destroyFilesystem"
+)
+
+// DestroyTreeAction - single step of DestroyTree() plan
+type DestroyTreeAction struct {
+	Type DestroyTreeActionType `json:"type"`
+
+	// Path of the object the action is applied to (share filesystem, snapshot, dataset)
+	Path string `json:"path"`
+
+	// ID of the LUN mapping for DestroyTreeActionDestroyLunMapping action
+	ID string `json:"id,omitempty"`
+
+	// dataset the action belongs to, used to skip dependent actions on failures
+	dataset string
+	// datasets which must be destroyed before this action is executed
+	dependsOn []string
+}
+
+func (a DestroyTreeAction) String() string {
+	switch a.Type {
+	case DestroyTreeActionUnshareNfs:
+		return fmt.Sprintf("delete NFS share of filesystem '%s'", a.Path)
+	case DestroyTreeActionUnshareSmb:
+		return fmt.Sprintf("delete SMB share of filesystem '%s'", a.Path)
+	case DestroyTreeActionDestroyLunMapping:
+		return fmt.Sprintf("delete LUN mapping '%s' of volume '%s'", a.ID, a.Path)
+	case DestroyTreeActionDestroySnapshot:
+		return fmt.Sprintf("destroy snapshot '%s'", a.Path)
+	case DestroyTreeActionDestroyVolume:
+		return fmt.Sprintf("destroy volume '%s'", a.Path)
+	case DestroyTreeActionDestroyVolumeGroup:
+		return fmt.Sprintf("destroy volumeGroup '%s'", a.Path)
+	case DestroyTreeActionDestroyFilesystem:
+		return fmt.Sprintf("destroy filesystem '%s'", a.Path)
+	}
+	return fmt.Sprintf("%s '%s'", a.Type, a.Path)
+}
+
+// DestroyTreeActionError - failed DestroyTree() plan step
+type DestroyTreeActionError struct {
+	Action DestroyTreeAction
+	Err    error
+}
+
+func (e DestroyTreeActionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Action, e.Err)
+}
+
+// DestroyTreeParams - params to destroy a tree of datasets
+type DestroyTreeParams struct {
+	// If set to `true`, only the plan is built and returned, nothing gets changed on NS
+	DryRun bool
+
+	// If set to `true`, snapshots that have clones outside of the destroyed tree
+	// will be taken over by the most recent clone instead of failing the deletion,
+	// see DestroyFilesystemParams.PromoteMostRecentCloneIfExists for details.
+	PromoteMostRecentCloneIfExists bool
+}
+
+// DestroyTreeResult - DestroyTree() plan and its execution summary
+type DestroyTreeResult struct {
+	// all planned actions in execution order
+	Plan []DestroyTreeAction
+
+	// executed actions
+	Done []DestroyTreeAction
+
+	// actions which were not executed because actions they depend on had failed
+	Skipped []DestroyTreeAction
+
+	// failed actions
+	Failed []DestroyTreeActionError
+}
+
+// Summary returns human readable plan execution summary
+func (r DestroyTreeResult) Summary() string {
+	var sb strings.Builder
+	fmt.Fprintf(
+		&sb,
+		"planned: %d, done: %d, failed: %d, skipped: %d",
+		len(r.Plan),
+		len(r.Done),
+		len(r.Failed),
+		len(r.Skipped),
+	)
+	for _, e := range r.Failed {
+		fmt.Fprintf(&sb, "\n  failed: %s", e)
+	}
+	for _, a := range r.Skipped {
+		fmt.Fprintf(&sb, "\n  skipped: %s", a)
+	}
+	return sb.String()
+}
+
+// DestroyTree destroys a filesystem, a volumeGroup or a volume with all its descendants.
+// All NFS/SMB shares, LUN mappings, snapshots and child datasets are destroyed
+// in dependency order. Execution doesn't stop on a failed action, actions depending
+// on the failed one are skipped instead, see DestroyTreeResult for the summary.
+// Path format: 'pool/dataset/filesystem'
+func (p *Provider) DestroyTree(path string, params DestroyTreeParams) (DestroyTreeResult, error) {
+	l := p.Log.WithField("func", "DestroyTree()")
+
+	result := DestroyTreeResult{}
+
+	plan, err := p.planDestroyTree(path)
+	if err != nil {
+		return result, fmt.Errorf("Failed to build destroy plan for '%s': %s", path, err)
+	}
+	result.Plan = plan
+
+	if params.DryRun {
+		for _, action := range plan {
+			l.Infof("[dry-run] %s", action)
+		}
+		return result, nil
+	}
+
+	// datasets with failed or skipped actions, their ancestors cannot be destroyed
+	failedDatasets := map[string]bool{}
+
+	for _, action := range plan {
+		blockedBy := ""
+		if isDestroyTreeDatasetAction(action.Type) && failedDatasets[action.dataset] {
+			blockedBy = action.dataset
+		}
+		for _, dependency := range action.dependsOn {
+			if failedDatasets[dependency] {
+				blockedBy = dependency
+				break
+			}
+		}
+		if blockedBy != "" {
+			l.Debugf("skip: %s, '%s' has not been destroyed", action, blockedBy)
+			result.Skipped = append(result.Skipped, action)
+			failedDatasets[action.dataset] = true
+			continue
+		}
+
+		l.Debugf("%s", action)
+		if err := p.executeDestroyTreeAction(action, params); err != nil {
+			l.Debugf("failed: %s: %s", action, err)
+			result.Failed = append(result.Failed, DestroyTreeActionError{Action: action, Err: err})
+			failedDatasets[action.dataset] = true
+			continue
+		}
+		result.Done = append(result.Done, action)
+	}
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("Failed to destroy '%s': %s", path, result.Summary())
+	}
+
+	return result, nil
+}
+
+func isDestroyTreeDatasetAction(actionType DestroyTreeActionType) bool {
+	return actionType == DestroyTreeActionDestroyFilesystem ||
+		actionType == DestroyTreeActionDestroyVolumeGroup ||
+		actionType == DestroyTreeActionDestroyVolume
+}
+
+func (p *Provider) executeDestroyTreeAction(action DestroyTreeAction, params DestroyTreeParams) error {
+	switch action.Type {
+	case DestroyTreeActionUnshareNfs:
+		return p.DeleteNfsShare(action.Path)
+	case DestroyTreeActionUnshareSmb:
+		return p.DeleteSmbShare(action.Path)
+	case DestroyTreeActionDestroyLunMapping:
+		return p.DestroyLunMapping(action.ID)
+	case DestroyTreeActionDestroySnapshot:
+		return p.DestroySnapshot(action.Path)
+	case DestroyTreeActionDestroyVolume:
+		_, err := p.DestroyVolume(action.Path, DestroyVolumeParams{
+			DestroySnapshots:               true,
+			PromoteMostRecentCloneIfExists: params.PromoteMostRecentCloneIfExists,
+		})
+		return err
+	case DestroyTreeActionDestroyVolumeGroup:
+		return p.destroyVolumeGroup(action.Path, true)
+	case DestroyTreeActionDestroyFilesystem:
+		return p.DestroyFilesystem(action.Path, DestroyFilesystemParams{
+			DestroySnapshots:               true,
+			PromoteMostRecentCloneIfExists: params.PromoteMostRecentCloneIfExists,
+		})
+	}
+	return fmt.Errorf("Unknown action type '%s'", action.Type)
+}
+
+// destroyTreeNode - dataset found while walking the tree
+type destroyTreeNode struct {
+	path       string
+	actionType DestroyTreeActionType
+	prepare    []DestroyTreeAction
+	snapshots  []Snapshot
+	children   []string
+}
+
+// planDestroyTree walks the tree and returns the list of actions in execution order:
+// all shares and LUN mappings first, then snapshots and datasets, so that children
+// and in-tree clones are always destroyed before the datasets they depend on.
+func (p *Provider) planDestroyTree(path string) ([]DestroyTreeAction, error) {
+	if path == "" {
+		return nil, fmt.Errorf("Path is required")
+	}
+
+	nodes := map[string]*destroyTreeNode{}
+
+	var root destroyTreeNode
+	if filesystem, err := p.GetFilesystem(path); err == nil {
+		root = p.newDestroyTreeFilesystemNode(filesystem)
+	} else if !IsNotExistNefError(err) {
+		return nil, err
+	} else if _, err := p.GetVolumeGroup(path); err == nil {
+		root = destroyTreeNode{path: path, actionType: DestroyTreeActionDestroyVolumeGroup}
+	} else if !IsNotExistNefError(err) {
+		return nil, err
+	} else if volume, err := p.GetVolume(path); err == nil {
+		root = destroyTreeNode{path: volume.Path, actionType: DestroyTreeActionDestroyVolume}
+	} else {
+		return nil, err
+	}
+
+	queue := []*destroyTreeNode{&root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		nodes[node.path] = node
+
+		children, err := p.getDestroyTreeChildren(node)
+		if err != nil {
+			return nil, err
+		}
+		for i := range children {
+			node.children = append(node.children, children[i].path)
+			queue = append(queue, &children[i])
+		}
+
+		if node.actionType == DestroyTreeActionDestroyVolume {
+			lunMappings, err := p.GetLunMappings(GetLunMappingsParams{Volume: node.path})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get LUN mappings of '%s': %s", node.path, err)
+			}
+			for _, lunMapping := range lunMappings {
+				node.prepare = append(node.prepare, DestroyTreeAction{
+					Type: DestroyTreeActionDestroyLunMapping,
+					Path: node.path,
+					ID:   lunMapping.Id,
+				})
+			}
+		}
+
+		snapshots, err := p.GetSnapshots(node.path, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get snapshot list of '%s': %s", node.path, err)
+		}
+		for _, s := range snapshots {
+			// to get "clones" field that is not presented in the list response
+			snapshot, err := p.GetSnapshot(s.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get '%s' snapshot's info: %s", s.Path, err)
+			}
+			node.snapshots = append(node.snapshots, snapshot)
+		}
+	}
+
+	plan := []DestroyTreeAction{}
+
+	paths := make([]string, 0, len(nodes))
+	for path := range nodes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, action := range nodes[path].prepare {
+			action.dataset = path
+			plan = append(plan, action)
+		}
+	}
+
+	// dataset -> datasets that must be destroyed first (children and in-tree clones)
+	dependencies := map[string][]string{}
+	for _, path := range paths {
+		node := nodes[path]
+		dependencies[path] = append(dependencies[path], node.children...)
+		for _, snapshot := range node.snapshots {
+			for _, clone := range snapshot.Clones {
+				if _, ok := nodes[clone]; ok {
+					dependencies[path] = append(dependencies[path], clone)
+				}
+			}
+		}
+	}
+
+	for _, path := range sortDestroyTreeDatasets(paths, dependencies) {
+		node := nodes[path]
+		for _, snapshot := range node.snapshots {
+			inTreeClones := []string{}
+			hasExternalClones := false
+			for _, clone := range snapshot.Clones {
+				if _, ok := nodes[clone]; ok {
+					inTreeClones = append(inTreeClones, clone)
+				} else {
+					hasExternalClones = true
+				}
+			}
+			// snapshots with external clones are left to the dataset destroy call,
+			// so the most recent clone can be promoted to take them over
+			if hasExternalClones {
+				continue
+			}
+			plan = append(plan, DestroyTreeAction{
+				Type:      DestroyTreeActionDestroySnapshot,
+				Path:      snapshot.Path,
+				dataset:   path,
+				dependsOn: inTreeClones,
+			})
+		}
+		plan = append(plan, DestroyTreeAction{
+			Type:      node.actionType,
+			Path:      path,
+			dataset:   path,
+			dependsOn: dependencies[path],
+		})
+	}
+
+	return plan, nil
+}
+
+func (p *Provider) newDestroyTreeFilesystemNode(filesystem Filesystem) destroyTreeNode {
+	node := destroyTreeNode{path: filesystem.Path, actionType: DestroyTreeActionDestroyFilesystem}
+	if filesystem.SharedOverNfs {
+		node.prepare = append(node.prepare, DestroyTreeAction{
+			Type: DestroyTreeActionUnshareNfs,
+			Path: filesystem.Path,
+		})
+	}
+	if filesystem.SharedOverSmb {
+		node.prepare = append(node.prepare, DestroyTreeAction{
+			Type: DestroyTreeActionUnshareSmb,
+			Path: filesystem.Path,
+		})
+	}
+	return node
+}
+
+func (p *Provider) getDestroyTreeChildren(node *destroyTreeNode) ([]destroyTreeNode, error) {
+	children := []destroyTreeNode{}
+
+	switch node.actionType {
+	case DestroyTreeActionDestroyFilesystem:
+		filesystems, err := p.GetFilesystems(node.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child filesystems of '%s': %s", node.path, err)
+		}
+		for _, filesystem := range filesystems {
+			children = append(children, p.newDestroyTreeFilesystemNode(filesystem))
+		}
+
+		volumeGroups, err := p.GetVolumeGroups(node.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child volumeGroups of '%s': %s", node.path, err)
+		}
+		for _, volumeGroup := range volumeGroups {
+			children = append(children, destroyTreeNode{
+				path:       volumeGroup.Path,
+				actionType: DestroyTreeActionDestroyVolumeGroup,
+			})
+		}
+	case DestroyTreeActionDestroyVolumeGroup:
+		volumes, err := p.GetVolumes(node.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get volumes of '%s': %s", node.path, err)
+		}
+		for _, volume := range volumes {
+			children = append(children, destroyTreeNode{
+				path:       volume.Path,
+				actionType: DestroyTreeActionDestroyVolume,
+			})
+		}
+	}
+
+	return children, nil
+}
+
+// sortDestroyTreeDatasets returns datasets ordered so that every dataset goes after its dependencies,
+// deeper datasets go first when there is no dependency between them
+func sortDestroyTreeDatasets(paths []string, dependencies map[string][]string) []string {
+	remaining := append([]string{}, paths...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return strings.Count(remaining[i], "/") > strings.Count(remaining[j], "/")
+	})
+
+	sorted := []string{}
+	done := map[string]bool{}
+	for len(remaining) > 0 {
+		next := []string{}
+		for _, path := range remaining {
+			ready := true
+			for _, dependency := range dependencies[path] {
+				if !done[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, path)
+				done[path] = true
+			} else {
+				next = append(next, path)
+			}
+		}
+		if len(next) == len(remaining) {
+			// dependency loop, should never happen, keep the depth order for the rest
+			return append(sorted, next...)
+		}
+		remaining = next
+	}
+
+	return sorted
+}
@@ -0,0 +1,286 @@
+package ns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DiskUsage - what the disk is used for
+type DiskUsage string
+
+const (
+	// DiskUsageUnused - disk doesn't belong to any pool
+	DiskUsageUnused DiskUsage = "unused"
+	// DiskUsageVdev - disk is a part of pool data vdev
+	DiskUsageVdev DiskUsage = "vdev"
+	// DiskUsageLog - disk is a part of pool log vdev
+	DiskUsageLog DiskUsage = "log"
+	// DiskUsageCache - disk is a pool cache device
+	DiskUsageCache DiskUsage = "cache"
+	// DiskUsageSpare - disk is a pool hot spare
+	DiskUsageSpare DiskUsage = "spare"
+)
+
+// DiskSmartHealthOK - SMART health of a disk without predicted failures
+const DiskSmartHealthOK = "OK"
+
+// failedVdevStates - vdev states of disks that need replacement
+var failedVdevStates = map[string]bool{
+	"FAULTED": true,
+	"UNAVAIL": true,
+	"REMOVED": true,
+}
+
+// Disk - NexentaStor disk with its physical location and usage
+type Disk struct {
+	Name      string `json:"logicalDevice"`
+	Serial    string `json:"serial"`
+	Model     string `json:"model"`
+	Vendor    string `json:"vendor"`
+	Size      int64  `json:"size"`
+	Enclosure string `json:"enclosure"`
+	// slot number in the enclosure
+	Slot        int    `json:"slot"`
+	SmartHealth string `json:"smartHealth"`
+	LocateLED   bool   `json:"locateLed"`
+
+	// usage is resolved from pool topologies
+	Usage DiskUsage `json:"usage"`
+	// pool name, empty if disk is unused
+	Pool string `json:"pool"`
+	// vdev state of the disk in the pool (e.g. "ONLINE", "FAULTED")
+	State string `json:"state"`
+}
+
+// Location returns human readable physical location of the disk
+func (disk Disk) Location() string {
+	if disk.Enclosure == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("enclosure %s slot %d", disk.Enclosure, disk.Slot)
+}
+
+// IsFailed returns true if disk has failed in the pool or SMART predicts its failure
+func (disk Disk) IsFailed() bool {
+	return failedVdevStates[disk.State] || (disk.SmartHealth != "" && disk.SmartHealth != DiskSmartHealthOK)
+}
+
+// Enclosure - NexentaStor disk enclosure (JBOD or server chassis)
+type Enclosure struct {
+	Id     string `json:"id"`
+	Vendor string `json:"vendor"`
+	Model  string `json:"model"`
+	Serial string `json:"serial"`
+	Slots  int    `json:"slots"`
+}
+
+type nefInventoryDisksResponse struct {
+	Data []Disk `json:"data"`
+}
+
+type nefInventoryEnclosuresResponse struct {
+	Data []Enclosure `json:"data"`
+}
+
+type nefInventoryDisksUpdateRequest struct {
+	LocateLED bool `json:"locateLed"`
+}
+
+type nefStoragePoolsSparesRequest struct {
+	Devices []string `json:"devices"`
+}
+
+// diskPoolUsage - disk usage in a pool
+type diskPoolUsage struct {
+	pool  string
+	usage DiskUsage
+	state string
+}
+
+// getDiskPoolUsages returns pool usages of all disks by disk name
+func getDiskPoolUsages(pools []Pool) map[string]diskPoolUsage {
+	usages := map[string]diskPoolUsage{}
+
+	var walk func(pool string, usage DiskUsage, vdevs []Vdev)
+	walk = func(pool string, usage DiskUsage, vdevs []Vdev) {
+		for _, vdev := range vdevs {
+			if len(vdev.Children) == 0 {
+				usages[vdev.Name] = diskPoolUsage{pool, usage, vdev.State}
+			} else {
+				walk(pool, usage, vdev.Children)
+			}
+		}
+	}
+
+	for _, pool := range pools {
+		walk(pool.Name, DiskUsageVdev, pool.Topology.Data)
+		walk(pool.Name, DiskUsageVdev, pool.Topology.Special)
+		walk(pool.Name, DiskUsageLog, pool.Topology.Log)
+		walk(pool.Name, DiskUsageCache, pool.Topology.Cache)
+		walk(pool.Name, DiskUsageSpare, pool.Topology.Spare)
+	}
+
+	return usages
+}
+
+// GetDisks returns all disks with their location, SMART health, locate LED state and pool usage
+func (p *Provider) GetDisks() ([]Disk, error) {
+	uri := p.RestClient.BuildURI("inventory/disks", map[string]string{
+		"fields": "logicalDevice,serial,model,vendor,size,enclosure,slot,smartHealth,locateLed",
+	})
+
+	response := nefInventoryDisksResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := p.GetPools()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get pools to resolve disk usage: %s", err)
+	}
+	usages := getDiskPoolUsages(pools)
+
+	disks := []Disk{}
+	for _, disk := range response.Data {
+		disk.Usage = DiskUsageUnused
+		disk.Pool = ""
+		disk.State = ""
+		if usage, ok := usages[disk.Name]; ok {
+			disk.Usage = usage.usage
+			disk.Pool = usage.pool
+			disk.State = usage.state
+		}
+		disks = append(disks, disk)
+	}
+
+	return disks, nil
+}
+
+// GetDisk returns disk by its name (e.g. "c1t1d0") or serial number
+func (p *Provider) GetDisk(name string) (Disk, error) {
+	if name == "" {
+		return Disk{}, fmt.Errorf("Disk name is empty")
+	}
+
+	disks, err := p.GetDisks()
+	if err != nil {
+		return Disk{}, err
+	}
+
+	for _, disk := range disks {
+		if disk.Name == name || strings.EqualFold(disk.Serial, name) {
+			return disk, nil
+		}
+	}
+
+	return Disk{}, &NefError{
+		Err:  fmt.Errorf("Disk '%s' not found", name),
+		Code: "ENOENT",
+	}
+}
+
+// GetFailedDisks returns disks failed in pools or with failure predicted by SMART
+func (p *Provider) GetFailedDisks() ([]Disk, error) {
+	disks, err := p.GetDisks()
+	if err != nil {
+		return nil, err
+	}
+
+	failed := []Disk{}
+	for _, disk := range disks {
+		if disk.IsFailed() {
+			failed = append(failed, disk)
+		}
+	}
+
+	return failed, nil
+}
+
+// GetEnclosures returns disk enclosures
+func (p *Provider) GetEnclosures() ([]Enclosure, error) {
+	uri := p.RestClient.BuildURI("inventory/enclosures", map[string]string{
+		"fields": "id,vendor,model,serial,slots",
+	})
+
+	response := nefInventoryEnclosuresResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// SetDiskLocateLED turns disk locate LED on or off to find the disk in the enclosure
+func (p *Provider) SetDiskLocateLED(name string, on bool) error {
+	if name == "" {
+		return fmt.Errorf("Disk name is required")
+	}
+
+	uri := fmt.Sprintf("inventory/disks/%s", url.PathEscape(name))
+
+	return p.sendRequest(http.MethodPut, uri, nefInventoryDisksUpdateRequest{LocateLED: on})
+}
+
+// AddPoolSpares assigns disks as hot spares of the pool, disks that are already pool spares are skipped
+func (p *Provider) AddPoolSpares(pool string, disks []string) error {
+	if pool == "" || len(disks) == 0 {
+		return fmt.Errorf("Pool name and disks cannot be empty, got pool '%s' and disks %v", pool, disks)
+	}
+
+	spares, err := p.getPoolSpares(pool)
+	if err != nil {
+		return err
+	}
+
+	devices := []string{}
+	for _, disk := range disks {
+		if !spares[disk] {
+			devices = append(devices, disk)
+		}
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/spares", url.PathEscape(pool))
+
+	return p.sendRequest(http.MethodPost, uri, nefStoragePoolsSparesRequest{Devices: devices})
+}
+
+// RemovePoolSpare removes hot spare disk from the pool, not a spare disk is not treated as an error
+func (p *Provider) RemovePoolSpare(pool, disk string) error {
+	if pool == "" || disk == "" {
+		return fmt.Errorf("Pool name and disk are required, got pool '%s' and disk '%s'", pool, disk)
+	}
+
+	spares, err := p.getPoolSpares(pool)
+	if err != nil {
+		return err
+	} else if !spares[disk] {
+		return nil
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/spares/%s", url.PathEscape(pool), url.PathEscape(disk))
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+func (p *Provider) getPoolSpares(pool string) (map[string]bool, error) {
+	poolDetails, err := p.GetPool(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	spares := map[string]bool{}
+	for disk, usage := range getDiskPoolUsages([]Pool{poolDetails}) {
+		if usage.usage == DiskUsageSpare {
+			spares[disk] = true
+		}
+	}
+
+	return spares, nil
+}
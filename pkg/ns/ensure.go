@@ -0,0 +1,430 @@
+package ns
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EnsureAction - action taken by Ensure* methods to converge actual state to the desired one
+type EnsureAction string
+
+const (
+	// EnsureActionCreated - resource did not exist and was created
+	EnsureActionCreated EnsureAction = "created"
+
+	// EnsureActionUpdated - resource existed, but some of its fields were updated
+	EnsureActionUpdated EnsureAction = "updated"
+
+	// EnsureActionUnchanged - resource already was in the desired state
+	EnsureActionUnchanged EnsureAction = "unchanged"
+)
+
+// EnsureFieldDiff - difference between actual and desired value of a resource field,
+// Field is the NexentaStor field name, Actual is nil for created resources
+type EnsureFieldDiff struct {
+	Field   string
+	Actual  interface{}
+	Desired interface{}
+}
+
+func (diff EnsureFieldDiff) String() string {
+	if diff.Actual == nil {
+		return fmt.Sprintf("%s: %v", diff.Field, diff.Desired)
+	}
+	return fmt.Sprintf("%s: %v -> %v", diff.Field, diff.Actual, diff.Desired)
+}
+
+// EnsureResult - result of Ensure* call, empty if the call fails
+type EnsureResult struct {
+	Action EnsureAction
+	// changed fields, empty if the resource is unchanged
+	Diff []EnsureFieldDiff
+}
+
+func (result EnsureResult) String() string {
+	if len(result.Diff) == 0 {
+		return string(result.Action)
+	}
+	diff := make([]string, 0, len(result.Diff))
+	for _, d := range result.Diff {
+		diff = append(diff, d.String())
+	}
+	return fmt.Sprintf("%s (%s)", result.Action, strings.Join(diff, ", "))
+}
+
+// ensureDiff collects field differences of a resource
+type ensureDiff []EnsureFieldDiff
+
+func (d *ensureDiff) add(field string, actual, desired interface{}) bool {
+	if reflect.DeepEqual(actual, desired) {
+		return false
+	}
+	*d = append(*d, EnsureFieldDiff{Field: field, Actual: actual, Desired: desired})
+	return true
+}
+
+func (d ensureDiff) result() EnsureResult {
+	if len(d) == 0 {
+		return EnsureResult{Action: EnsureActionUnchanged}
+	}
+	return EnsureResult{Action: EnsureActionUpdated, Diff: d}
+}
+
+// CreatedDiff converts diff against the zero value of a resource to the diff of created resource
+func CreatedDiff(diff []EnsureFieldDiff) []EnsureFieldDiff {
+	created := make([]EnsureFieldDiff, 0, len(diff))
+	for _, d := range diff {
+		created = append(created, EnsureFieldDiff{Field: d.Field, Desired: d.Desired})
+	}
+	return created
+}
+
+// EnsureFilesystemParams - desired filesystem state, nil fields are not compared
+type EnsureFilesystemParams struct {
+	// filesystem path w/o leading slash
+	Path string
+	// filesystem referenced quota size in bytes, 0 removes the quota
+	ReferencedQuotaSize *int64
+}
+
+// DiffFilesystem returns differences between actual filesystem state and the desired one
+func DiffFilesystem(filesystem Filesystem, params EnsureFilesystemParams) []EnsureFieldDiff {
+	diff := ensureDiff{}
+	if params.ReferencedQuotaSize != nil {
+		diff.add("referencedQuotaSize", filesystem.ReferencedQuotaSize, *params.ReferencedQuotaSize)
+	}
+	return diff
+}
+
+// EnsureFilesystem creates filesystem or updates the existing one to match desired state
+func (p *Provider) EnsureFilesystem(params EnsureFilesystemParams) (Filesystem, EnsureResult, error) {
+	if params.Path == "" {
+		return Filesystem{}, EnsureResult{}, fmt.Errorf("Parameter 'EnsureFilesystemParams.Path' is required")
+	}
+
+	filesystem, err := p.GetFilesystem(params.Path)
+	if IsNotExistNefError(err) {
+		createParams := CreateFilesystemParams{Path: params.Path}
+		if params.ReferencedQuotaSize != nil {
+			createParams.ReferencedQuotaSize = *params.ReferencedQuotaSize
+		}
+		filesystem, err = p.CreateFilesystem(createParams)
+		if err == nil {
+			return filesystem, EnsureResult{
+				Action: EnsureActionCreated,
+				Diff:   CreatedDiff(DiffFilesystem(Filesystem{}, params)),
+			}, nil
+		} else if !IsAlreadyExistNefError(err) {
+			return filesystem, EnsureResult{}, err
+		}
+		filesystem, err = p.GetFilesystem(params.Path)
+	}
+	if err != nil {
+		return filesystem, EnsureResult{}, err
+	}
+
+	diff := ensureDiff(DiffFilesystem(filesystem, params))
+	if len(diff) == 0 {
+		return filesystem, diff.result(), nil
+	}
+
+	err = p.UpdateFilesystem(params.Path, UpdateFilesystemParams{ReferencedQuotaSize: params.ReferencedQuotaSize})
+	if err != nil {
+		return filesystem, EnsureResult{}, err
+	}
+
+	filesystem, err = p.GetFilesystem(params.Path)
+	return filesystem, diff.result(), err
+}
+
+// EnsureVolumeParams - desired volume state, empty and nil fields are not compared
+type EnsureVolumeParams struct {
+	// volume path w/o leading slash
+	Path string
+	// volume size in bytes, gets rounded up to the volume block size, volumes are never shrunk
+	VolumeSize int64
+	// sparse volume flag, used on creation only, use ReferencedReservationSize to change it later
+	SparseVolume bool
+	// volume block size in bytes, existing volume with different block size causes an error
+	VolumeBlockSize int64
+	// compression algorithm: "off", "on", "lz4", "gzip", etc.
+	CompressionMode string
+	// deduplication mode: "off", "on", "verify", etc.
+	DedupMode string
+	// synchronous write behaviour: "standard", "always" or "disabled"
+	SyncMode string
+	// disables writeback cache of the volume if set to `true`
+	WritebackCacheDisabled *bool
+	// volume referenced reservation size in bytes
+	ReferencedReservationSize *int64
+}
+
+// DiffVolume returns differences between actual volume state and the desired one,
+// desired volume size is rounded up to the volume block size
+func DiffVolume(volume Volume, params EnsureVolumeParams) []EnsureFieldDiff {
+	diff := ensureDiff{}
+	if params.VolumeSize != 0 {
+		size := params.VolumeSize
+		if volume.VolumeBlockSize != 0 {
+			size = alignSize(size, volume.VolumeBlockSize)
+		}
+		diff.add("volumeSize", volume.VolumeSize, size)
+	}
+	if params.VolumeBlockSize != 0 {
+		diff.add("volumeBlockSize", volume.VolumeBlockSize, params.VolumeBlockSize)
+	}
+	if params.CompressionMode != "" {
+		diff.add("compressionMode", volume.CompressionMode, params.CompressionMode)
+	}
+	if params.DedupMode != "" {
+		diff.add("dedupMode", volume.DedupMode, params.DedupMode)
+	}
+	if params.SyncMode != "" {
+		diff.add("syncMode", volume.SyncMode, params.SyncMode)
+	}
+	if params.WritebackCacheDisabled != nil {
+		diff.add("writebackCacheDisabled", volume.WritebackCacheDisabled, *params.WritebackCacheDisabled)
+	}
+	if params.ReferencedReservationSize != nil {
+		diff.add("referencedReservationSize", volume.ReferencedReservationSize, *params.ReferencedReservationSize)
+	}
+	return diff
+}
+
+// EnsureVolume creates volume or updates the existing one to match desired state,
+// volume size change is checked the same way as by ResizeVolume(), so shrinking is refused
+func (p *Provider) EnsureVolume(params EnsureVolumeParams) (Volume, EnsureResult, error) {
+	if params.Path == "" {
+		return Volume{}, EnsureResult{}, fmt.Errorf("Parameter 'EnsureVolumeParams.Path' is required")
+	}
+
+	volume, err := p.GetVolume(params.Path)
+	if IsNotExistNefError(err) {
+		if params.VolumeSize == 0 {
+			return volume, EnsureResult{}, &NefError{
+				Code: "EBADARG",
+				Err:  fmt.Errorf("Parameter 'EnsureVolumeParams.VolumeSize' is required to create volume '%s'", params.Path),
+			}
+		}
+		volume, err = p.CreateVolume(CreateVolumeParams{
+			Path:                      params.Path,
+			VolumeSize:                params.VolumeSize,
+			SparseVolume:              params.SparseVolume,
+			VolumeBlockSize:           params.VolumeBlockSize,
+			CompressionMode:           params.CompressionMode,
+			DedupMode:                 params.DedupMode,
+			SyncMode:                  params.SyncMode,
+			WritebackCacheDisabled:    params.WritebackCacheDisabled,
+			ReferencedReservationSize: params.ReferencedReservationSize,
+		})
+		if err == nil {
+			return volume, EnsureResult{
+				Action: EnsureActionCreated,
+				Diff:   CreatedDiff(DiffVolume(Volume{}, params)),
+			}, nil
+		} else if !IsAlreadyExistNefError(err) {
+			return volume, EnsureResult{}, err
+		}
+		volume, err = p.GetVolume(params.Path)
+	}
+	if err != nil {
+		return volume, EnsureResult{}, err
+	}
+
+	diff := ensureDiff(DiffVolume(volume, params))
+	update := UpdateVolumeParams{}
+	for _, d := range diff {
+		switch d.Field {
+		case "volumeBlockSize":
+			return volume, EnsureResult{}, &NefError{
+				Code: "EBADARG",
+				Err: fmt.Errorf(
+					"Volume '%s' block size is %d, it cannot be changed to %d",
+					params.Path, volume.VolumeBlockSize, params.VolumeBlockSize,
+				),
+			}
+		case "compressionMode":
+			update.CompressionMode = params.CompressionMode
+		case "dedupMode":
+			update.DedupMode = params.DedupMode
+		case "syncMode":
+			update.SyncMode = params.SyncMode
+		case "writebackCacheDisabled":
+			update.WritebackCacheDisabled = params.WritebackCacheDisabled
+		case "referencedReservationSize":
+			update.ReferencedReservationSize = params.ReferencedReservationSize
+		}
+	}
+
+	for _, d := range diff {
+		if d.Field == "volumeSize" {
+			_, resize, err := p.getResizeVolumeParams(volume, ResizeVolumeParams{VolumeSize: d.Desired.(int64)})
+			if err != nil {
+				return volume, EnsureResult{}, err
+			}
+			update.VolumeSize = resize.VolumeSize
+			if update.ReferencedReservationSize == nil {
+				update.ReferencedReservationSize = resize.ReferencedReservationSize
+			}
+		}
+	}
+
+	// all changes are sent in a single request, so the volume is never left partially updated
+	if update != (UpdateVolumeParams{}) {
+		err = p.UpdateVolume(params.Path, update)
+		if err != nil {
+			return volume, EnsureResult{}, err
+		}
+	}
+	if len(diff) == 0 {
+		return volume, diff.result(), nil
+	}
+
+	volume, err = p.GetVolume(params.Path)
+	return volume, diff.result(), err
+}
+
+// DiffNfsShare returns differences between actual NFS share access lists and the desired ones,
+// default access lists are the same as for CreateNfsShare()
+func DiffNfsShare(share NfsShare, params CreateNfsShareParams) []EnsureFieldDiff {
+	desired := getNfsSecurityContexts(params)[0]
+	diff := []EnsureFieldDiff{}
+	if !nfsRulesEqual(share.ReadWriteList, desired.ReadWriteList) {
+		diff = append(diff, EnsureFieldDiff{
+			Field:   "readWriteList",
+			Actual:  share.ReadWriteList,
+			Desired: desired.ReadWriteList,
+		})
+	}
+	if !nfsRulesEqual(share.ReadOnlyList, desired.ReadOnlyList) {
+		diff = append(diff, EnsureFieldDiff{
+			Field:   "readOnlyList",
+			Actual:  share.ReadOnlyList,
+			Desired: desired.ReadOnlyList,
+		})
+	}
+	return diff
+}
+
+// EnsureNfsShare creates NFS share on the filesystem or updates the existing share access lists,
+// default access lists are the same as for CreateNfsShare()
+func (p *Provider) EnsureNfsShare(params CreateNfsShareParams) (EnsureResult, error) {
+	if params.Filesystem == "" {
+		return EnsureResult{}, fmt.Errorf("CreateNfsShareParams.Filesystem is required")
+	}
+
+	filesystem, err := p.GetFilesystem(params.Filesystem)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	if !filesystem.SharedOverNfs {
+		if _, err := p.CreateNfsShare(params); err != nil {
+			return EnsureResult{}, err
+		}
+		return EnsureResult{
+			Action: EnsureActionCreated,
+			Diff:   CreatedDiff(DiffNfsShare(NfsShare{}, params)),
+		}, nil
+	}
+
+	share, err := p.GetNfsShare(params.Filesystem)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	diff := ensureDiff(DiffNfsShare(share, params))
+	if len(diff) == 0 {
+		return diff.result(), nil
+	}
+
+	err = p.UpdateNfsShare(params)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	return diff.result(), nil
+}
+
+// nfsRulesEqual compares NFS rule lists ignoring the order
+func nfsRulesEqual(a, b []NfsRuleList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[NfsRuleList]int{}
+	for _, rule := range a {
+		count[rule]++
+	}
+	for _, rule := range b {
+		count[rule]--
+		if count[rule] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// EnsureSmbShare creates SMB share on the filesystem or updates the existing share name,
+// existing share name is kept if CreateSmbShareParams.ShareName is empty
+func (p *Provider) EnsureSmbShare(params CreateSmbShareParams) (EnsureResult, error) {
+	if params.Filesystem == "" {
+		return EnsureResult{}, fmt.Errorf("CreateSmbShareParams.Filesystem is required")
+	}
+
+	filesystem, err := p.GetFilesystem(params.Filesystem)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	if !filesystem.SharedOverSmb {
+		if _, err := p.CreateSmbShare(params); err != nil {
+			return EnsureResult{}, err
+		}
+		result := EnsureResult{Action: EnsureActionCreated}
+		if params.ShareName != "" {
+			result.Diff = []EnsureFieldDiff{{Field: "shareName", Desired: params.ShareName}}
+		}
+		return result, nil
+	}
+
+	shareName, err := p.GetSmbShareName(params.Filesystem)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	diff := ensureDiff{}
+	if params.ShareName == "" || !diff.add("shareName", shareName, params.ShareName) {
+		return diff.result(), nil
+	}
+
+	err = p.UpdateSmbShare(params)
+	if err != nil {
+		return EnsureResult{}, err
+	}
+
+	return diff.result(), nil
+}
+
+// EnsureSnapshot creates snapshot if it doesn't exist, snapshots are immutable so they are never updated
+func (p *Provider) EnsureSnapshot(params CreateSnapshotParams) (Snapshot, EnsureResult, error) {
+	if params.Path == "" {
+		return Snapshot{}, EnsureResult{}, fmt.Errorf("Parameter 'CreateSnapshotParams.Path' is required")
+	}
+
+	snapshot, err := p.GetSnapshot(params.Path)
+	if IsNotExistNefError(err) {
+		snapshot, err = p.CreateSnapshot(params)
+		if err == nil {
+			return snapshot, EnsureResult{Action: EnsureActionCreated}, nil
+		} else if !IsAlreadyExistNefError(err) {
+			return snapshot, EnsureResult{}, err
+		}
+		snapshot, err = p.GetSnapshot(params.Path)
+	}
+	if err != nil {
+		return snapshot, EnsureResult{}, err
+	}
+
+	return snapshot, EnsureResult{Action: EnsureActionUnchanged}, nil
+}
@@ -0,0 +1,270 @@
+package ns
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExportVolumeISCSIParams - params to expose a volume over iSCSI
+type ExportVolumeISCSIParams struct {
+	// iSCSI target name (IQN), the target gets created if it doesn't exist
+	Target string
+	// portals for the target creation, existing target with different portals causes an error
+	Portals []Portal
+
+	// targetGroup name, target gets added to it, "tg-<target name>" is used if not set
+	TargetGroup string
+	// hostGroup name, initiators get added to it,
+	// "hg-<initiators hash>" is used if not set, so hosts with the same initiators share the hostGroup
+	HostGroup string
+
+	// optional LUN number, use it to keep LUN IDs stable across hosts
+	Lun *int
+
+	// optional CHAP credentials, configured as remote initiator credentials for each initiator
+	ChapUser   string
+	ChapSecret string
+}
+
+// ExportVolumeISCSIResult - iSCSI exposure of a volume
+type ExportVolumeISCSIResult struct {
+	// iSCSI target name
+	TargetIQN string
+	// iSCSI target portals
+	Portals []Portal
+	// LUN number of the volume
+	Lun int
+	// device WWN, the volume is seen by initiator hosts as /dev/disk/by-id/wwn-0x<DeviceWWN>
+	DeviceWWN string
+
+	LunMapping LunMapping
+}
+
+// ExportVolumeISCSI exposes the volume over iSCSI to specified initiators: creates target, targetGroup,
+// hostGroup, remote initiators and LUN mapping. Existing objects are reused, so the call is idempotent.
+func (p *Provider) ExportVolumeISCSI(
+	volume string,
+	initiatorIQNs []string,
+	params ExportVolumeISCSIParams,
+) (ExportVolumeISCSIResult, error) {
+	l := p.Log.WithField("func", "ExportVolumeISCSI()")
+
+	result := ExportVolumeISCSIResult{}
+
+	if volume == "" {
+		return result, fmt.Errorf("Volume path is required")
+	} else if len(initiatorIQNs) == 0 {
+		return result, fmt.Errorf("At least one initiator IQN is required to export volume '%s'", volume)
+	} else if params.Target == "" {
+		return result, fmt.Errorf("Parameter 'ExportVolumeISCSIParams.Target' is required")
+	}
+
+	if _, err := p.GetVolume(volume); err != nil {
+		return result, err
+	}
+
+	targetGroup := params.TargetGroup
+	if targetGroup == "" {
+		targetGroup = fmt.Sprintf("tg-%s", params.Target)
+	}
+	hostGroup := params.HostGroup
+	if hostGroup == "" {
+		hostGroup = getDefaultHostGroupName(initiatorIQNs)
+	}
+
+	l.Debugf("export volume '%s' over target '%s' to %v", volume, params.Target, initiatorIQNs)
+
+	target, err := p.CreateISCSITarget(CreateISCSITargetParams{
+		Name:    params.Target,
+		Portals: params.Portals,
+	})
+	if err != nil {
+		return result, fmt.Errorf("Failed to create iSCSI target '%s': %s", params.Target, err)
+	}
+	result.TargetIQN = target.Name
+	result.Portals = target.Portals
+
+	err = p.AddTargetGroupMembers(targetGroup, []string{params.Target})
+	if err != nil {
+		return result, fmt.Errorf("Failed to add target '%s' to targetGroup '%s': %s", params.Target, targetGroup, err)
+	}
+
+	err = p.AddHostGroupMembers(hostGroup, initiatorIQNs)
+	if err != nil {
+		return result, fmt.Errorf("Failed to add initiators %v to hostGroup '%s': %s", initiatorIQNs, hostGroup, err)
+	}
+
+	if params.ChapSecret != "" {
+		for _, initiator := range initiatorIQNs {
+			err := p.CreateRemoteInitiator(CreateRemoteInitiatorParams{
+				Name:       initiator,
+				ChapUser:   params.ChapUser,
+				ChapSecret: params.ChapSecret,
+			})
+			if IsAlreadyExistNefError(err) {
+				err = p.UpdateRemoteInitiator(initiator, UpdateRemoteInitiatorParams{
+					ChapUser:   params.ChapUser,
+					ChapSecret: params.ChapSecret,
+				})
+			}
+			if err != nil {
+				return result, fmt.Errorf("Failed to set CHAP credentials of initiator '%s': %s", initiator, err)
+			}
+		}
+	}
+
+	lunMapping, err := p.CreateLunMapping(CreateLunMappingParams{
+		Volume:      volume,
+		TargetGroup: targetGroup,
+		HostGroup:   hostGroup,
+		Lun:         params.Lun,
+	})
+	if err != nil {
+		return result, fmt.Errorf("Failed to create LUN mapping of volume '%s': %s", volume, err)
+	}
+	result.LunMapping = lunMapping
+	result.Lun = lunMapping.Lun
+
+	logicalUnit, err := p.GetLogicalUnitByVolume(volume)
+	if err != nil {
+		return result, fmt.Errorf("Failed to get logical unit of volume '%s': %s", volume, err)
+	}
+	result.DeviceWWN = strings.ToLower(logicalUnit.Guid)
+
+	return result, nil
+}
+
+// getDefaultHostGroupName returns hostGroup name unique for the set of initiators
+func getDefaultHostGroupName(initiators []string) string {
+	sorted := append([]string{}, initiators...)
+	sort.Strings(sorted)
+	return fmt.Sprintf("hg-%x", sha1.Sum([]byte(strings.Join(sorted, ","))))[:11]
+}
+
+// UnexportVolumeISCSIResult - SAN objects removed by UnexportVolumeISCSI()
+type UnexportVolumeISCSIResult struct {
+	RemovedLunMappings  []LunMapping
+	RemovedLogicalUnits []LogicalUnit
+	RemovedHostGroups   []string
+	RemovedTargetGroups []string
+	RemovedTargets      []string
+}
+
+// UnexportVolumeISCSI removes all LUN mappings of the volume, hostGroups, targetGroups and iSCSI targets
+// which are not used by other volumes, then the logical unit of the volume.
+// Remote initiator CHAP credentials are kept as they may be used by other exports.
+func (p *Provider) UnexportVolumeISCSI(volume string) (UnexportVolumeISCSIResult, error) {
+	l := p.Log.WithField("func", "UnexportVolumeISCSI()")
+
+	result := UnexportVolumeISCSIResult{}
+
+	if volume == "" {
+		return result, fmt.Errorf("Volume path is required")
+	}
+
+	lunMappings, err := p.GetLunMappings(GetLunMappingsParams{Volume: volume})
+	if err != nil {
+		return result, fmt.Errorf("Failed to get LUN mappings of volume '%s': %s", volume, err)
+	}
+
+	hostGroups := map[string]bool{}
+	targetGroups := map[string]bool{}
+	for _, lunMapping := range lunMappings {
+		l.Debugf("destroy LUN mapping '%s' of volume '%s'", lunMapping.Id, volume)
+		err := p.DestroyLunMapping(lunMapping.Id)
+		if err != nil && !IsNotExistNefError(err) {
+			return result, fmt.Errorf("Failed to destroy LUN mapping '%s' of volume '%s': %s", lunMapping.Id, volume, err)
+		}
+		result.RemovedLunMappings = append(result.RemovedLunMappings, lunMapping)
+		if lunMapping.HostGroup != "" && lunMapping.HostGroup != "All" {
+			hostGroups[lunMapping.HostGroup] = true
+		}
+		if lunMapping.TargetGroup != "" && lunMapping.TargetGroup != "All" {
+			targetGroups[lunMapping.TargetGroup] = true
+		}
+	}
+
+	for _, hostGroup := range sortedKeys(hostGroups) {
+		used, err := p.GetLunMappings(GetLunMappingsParams{HostGroup: hostGroup})
+		if err != nil {
+			return result, fmt.Errorf("Failed to get LUN mappings of hostGroup '%s': %s", hostGroup, err)
+		} else if len(used) > 0 {
+			continue
+		}
+		l.Debugf("delete unused hostGroup '%s'", hostGroup)
+		err = p.DeleteHostGroup(hostGroup)
+		if err != nil && !IsNotExistNefError(err) {
+			return result, fmt.Errorf("Failed to delete hostGroup '%s': %s", hostGroup, err)
+		}
+		result.RemovedHostGroups = append(result.RemovedHostGroups, hostGroup)
+	}
+
+	candidateTargets := map[string]bool{}
+	for _, name := range sortedKeys(targetGroups) {
+		targetGroup, err := p.GetTargetGroup(name)
+		if IsNotExistNefError(err) {
+			continue
+		} else if err != nil {
+			return result, fmt.Errorf("Failed to get targetGroup '%s': %s", name, err)
+		}
+		l.Debugf("delete targetGroup '%s' if unused", name)
+		err = p.DeleteTargetGroup(name)
+		if IsBusyNefError(err) || IsNotExistNefError(err) {
+			continue
+		} else if err != nil {
+			return result, fmt.Errorf("Failed to delete targetGroup '%s': %s", name, err)
+		}
+		result.RemovedTargetGroups = append(result.RemovedTargetGroups, name)
+		for _, target := range targetGroup.Members {
+			if !strings.HasPrefix(target, "wwn.") { // FC ports are not iSCSI targets
+				candidateTargets[target] = true
+			}
+		}
+	}
+
+	if len(candidateTargets) > 0 {
+		remainingTargetGroups, err := p.GetTargetGroups()
+		if err != nil {
+			return result, fmt.Errorf("Failed to get targetGroups: %s", err)
+		}
+		for _, targetGroup := range remainingTargetGroups {
+			for _, target := range targetGroup.Members {
+				delete(candidateTargets, target)
+			}
+		}
+		for _, target := range sortedKeys(candidateTargets) {
+			l.Debugf("delete unused iSCSI target '%s'", target)
+			err := p.DeleteISCSITarget(target)
+			if err != nil && !IsNotExistNefError(err) {
+				return result, fmt.Errorf("Failed to delete iSCSI target '%s': %s", target, err)
+			}
+			result.RemovedTargets = append(result.RemovedTargets, target)
+		}
+	}
+
+	// the logical unit goes last: once LUN mappings are removed, the groups they used cannot be found
+	// by a retry, while the logical unit is always found by the volume
+	logicalUnit, err := p.GetLogicalUnitByVolume(volume)
+	if err == nil {
+		err = p.DeleteLogicalUnit(logicalUnit.Guid)
+		if err != nil && !IsNotExistNefError(err) {
+			return result, fmt.Errorf("Failed to delete logical unit '%s' of volume '%s': %s", logicalUnit.Guid, volume, err)
+		}
+		result.RemovedLogicalUnits = append(result.RemovedLogicalUnits, logicalUnit)
+	} else if !IsNotExistNefError(err) {
+		return result, fmt.Errorf("Failed to get logical unit of volume '%s': %s", volume, err)
+	}
+
+	return result, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
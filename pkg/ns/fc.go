@@ -0,0 +1,126 @@
+package ns
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FCTargetPort - NexentaStor Fibre Channel port in target mode
+type FCTargetPort struct {
+	Wwn      string `json:"wwn"`
+	State    string `json:"state"`
+	Speed    string `json:"speed"`
+	PortType string `json:"portType"`
+	Model    string `json:"model"`
+}
+
+type nefSanFCTargetsResponse struct {
+	Data []FCTargetPort `json:"data"`
+}
+
+// FormatWWN converts World Wide Name to "wwn.XXXXXXXXXXXXXXXX" format used for FC hostGroup and targetGroup members
+// Accepts "21:00:00:24:ff:4b:8c:1a", "21000024ff4b8c1a" and "wwn.21000024FF4B8C1A" formats
+func FormatWWN(wwn string) (string, error) {
+	hex := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(wwn)), "wwn.")
+	hex = strings.NewReplacer(":", "", "-", "").Replace(hex)
+
+	if len(hex) != 16 {
+		return "", fmt.Errorf("WWN '%s' must contain 16 hex digits", wwn)
+	}
+	for _, c := range hex {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return "", fmt.Errorf("WWN '%s' contains non-hex character '%c'", wwn, c)
+		}
+	}
+
+	return fmt.Sprintf("wwn.%s", strings.ToUpper(hex)), nil
+}
+
+func formatWWNs(wwns []string) ([]string, error) {
+	members := []string{}
+	for _, wwn := range wwns {
+		member, err := FormatWWN(wwn)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// GetFCTargetPorts returns Fibre Channel ports in target mode with their WWNs and state
+func (p *Provider) GetFCTargetPorts() ([]FCTargetPort, error) {
+	uri := p.RestClient.BuildURI("san/fc/targets", map[string]string{
+		"fields": "wwn,state,speed,portType,model",
+	})
+
+	response := nefSanFCTargetsResponse{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// CreateFCHostGroupParams - params to create a hostGroup of FC initiators
+type CreateFCHostGroupParams struct {
+	// a unique name for the hostGroup
+	Name string
+	// list of initiator WWNs, see FormatWWN() for supported formats
+	Wwns []string
+}
+
+// CreateFCHostGroup creates hostGroup from FC initiator WWNs,
+// existing hostGroup with the same name is not treated as an error
+// Returns created or existing hostGroup
+func (p *Provider) CreateFCHostGroup(params CreateFCHostGroupParams) (HostGroup, error) {
+	if params.Name == "" || len(params.Wwns) == 0 {
+		return HostGroup{}, fmt.Errorf("HostGroup name and WWNs cannot be empty, got %+v", params)
+	}
+
+	members, err := formatWWNs(params.Wwns)
+	if err != nil {
+		return HostGroup{}, err
+	}
+
+	err = p.createHostGroup(CreateHostGroupParams{
+		Name:    params.Name,
+		Members: members,
+	})
+	if err != nil {
+		return HostGroup{}, err
+	}
+
+	return p.GetHostGroup(params.Name)
+}
+
+// CreateFCTargetGroupParams - params to create a targetGroup of FC target ports
+type CreateFCTargetGroupParams struct {
+	// a unique name for the targetGroup
+	Name string
+	// list of FC target port WWNs, see FormatWWN() for supported formats
+	Wwns []string
+}
+
+// CreateFCTargetGroup creates targetGroup from FC target port WWNs or adds the ports to existing one,
+// use the targetGroup in CreateLunMapping() to expose volumes over FC
+// Returns created or updated targetGroup
+func (p *Provider) CreateFCTargetGroup(params CreateFCTargetGroupParams) (TargetGroup, error) {
+	if params.Name == "" || len(params.Wwns) == 0 {
+		return TargetGroup{}, fmt.Errorf("TargetGroup name and WWNs cannot be empty, got %+v", params)
+	}
+
+	members, err := formatWWNs(params.Wwns)
+	if err != nil {
+		return TargetGroup{}, err
+	}
+
+	err = p.AddTargetGroupMembers(params.Name, members)
+	if err != nil {
+		return TargetGroup{}, err
+	}
+
+	return p.GetTargetGroup(params.Name)
+}
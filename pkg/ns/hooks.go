@@ -0,0 +1,92 @@
+package ns
+
+import (
+	"strings"
+	"time"
+
+	"go-nexentastor/pkg/rest"
+)
+
+// Hooks - provider instrumentation, extends REST request hooks with NexentaStor specific events,
+// embed NoopHooks to implement only some of the methods
+type Hooks interface {
+	rest.Hooks
+
+	// ObserveNefError is called for each NEF error response with its code, e.g. "ENOENT"
+	ObserveNefError(method, pathTemplate, code string)
+	// ObserveLogin is called after each login attempt
+	ObserveLogin(err error)
+	// ObserveJobWait is called when provider stops waiting for an async job
+	ObserveJobWait(duration time.Duration, err error)
+}
+
+// NoopHooks - hooks doing nothing
+type NoopHooks struct {
+	rest.NoopHooks
+}
+
+// ObserveNefError does nothing
+func (NoopHooks) ObserveNefError(method, pathTemplate, code string) {}
+
+// ObserveLogin does nothing
+func (NoopHooks) ObserveLogin(err error) {}
+
+// ObserveJobWait does nothing
+func (NoopHooks) ObserveJobWait(duration time.Duration, err error) {}
+
+// nefPathSegments - static segments of NEF API paths, other segments are object IDs
+var nefPathSegments = map[string]bool{}
+
+func init() {
+	for _, segment := range []string{
+		"acl", "analytics", "attach", "auth", "clone", "clusters", "detach", "disks", "enclosures", "export",
+		"fc", "filesystems", "hostgroups", "import", "inventory", "iscsi", "jobStatus", "license", "logicalUnits",
+		"login", "lunMappings", "nas", "nfs", "node", "pools", "promote", "reboot", "remoteInitiators", "replace",
+		"rsf", "san", "scrub", "series", "sessions", "settings", "smb", "snapshots", "spares", "storage",
+		"targetgroups", "targets", "vdevs", "volumeGroups", "volumes",
+	} {
+		nefPathSegments[segment] = true
+	}
+}
+
+// isNefAPIVersion returns true for API version path prefix, e.g. "v1.2.6"
+func isNefAPIVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, c := range segment[1:] {
+		if !(c >= '0' && c <= '9' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// nefPathTemplate removes query params from NEF path and replaces object IDs with "{id}",
+// e.g. "storage/filesystems/pool%2Ffs?fields=path" becomes "storage/filesystems/{id}"
+func nefPathTemplate(path string) string {
+	path = strings.SplitN(path, "?", 2)[0]
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !nefPathSegments[segment] && !(i == 0 && isNefAPIVersion(segment)) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (p *Provider) hooks() Hooks {
+	if p.Hooks == nil {
+		return NoopHooks{}
+	}
+	return p.Hooks
+}
+
+// observeNefError reports NEF error code to hooks
+func (p *Provider) observeNefError(method, path string, err error) {
+	if nefErr, ok := err.(*NefError); ok {
+		p.hooks().ObserveNefError(method, nefPathTemplate(path), nefErr.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package ns
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// count of read-merge-write attempts to update group members
+	groupMembersUpdateAttempts = 5
+
+	// delay between attempts, multiplied by attempt number
+	groupMembersUpdateInterval = 500 * time.Millisecond
+)
+
+// groupMembersOperations - functions to read and write members of a SAN group (hostGroup, targetGroup)
+type groupMembersOperations struct {
+	kind   string
+	name   string
+	add    []string
+	remove []string
+
+	// get returns current group members, or ENOENT error if group doesn't exist
+	get    func() ([]string, error)
+	create func(members []string) error
+	update func(members []string) error
+}
+
+// updateGroupMembers merges current group members with added and removed ones and writes them back.
+// NS has no conditional updates, so the result is read again after the write and the whole cycle
+// is repeated if other client has overwritten the members concurrently.
+func (p *Provider) updateGroupMembers(ops groupMembersOperations) error {
+	l := p.Log.WithField("func", "updateGroupMembers()")
+
+	for i := 0; i < groupMembersUpdateAttempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * groupMembersUpdateInterval)
+		}
+
+		current, err := ops.get()
+		if IsNotExistNefError(err) {
+			if len(ops.add) == 0 {
+				return nil
+			}
+			l.Debugf("create %s '%s' with members: %v", ops.kind, ops.name, ops.add)
+			if err := ops.create(ops.add); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			members := mergeMembers(current, ops.add, ops.remove)
+			if membersEqual(members, current) {
+				return nil
+			}
+			l.Debugf("update %s '%s' members: %v -> %v", ops.kind, ops.name, current, members)
+			if err := ops.update(members); err != nil {
+				return err
+			}
+		}
+
+		// verify that no other client has overwritten the members
+		current, err = ops.get()
+		if err != nil && !IsNotExistNefError(err) {
+			return err
+		} else if err == nil && membersEqual(mergeMembers(current, ops.add, ops.remove), current) {
+			return nil
+		}
+		l.Debugf("%s '%s' members were modified concurrently, retrying", ops.kind, ops.name)
+	}
+
+	return fmt.Errorf(
+		"Failed to update %s '%s' members after %d attempts, members are being modified concurrently",
+		ops.kind,
+		ops.name,
+		groupMembersUpdateAttempts,
+	)
+}
+
+// mergeMembers returns current members without removed ones and with added ones, keeps the order
+func mergeMembers(current, add, remove []string) []string {
+	removed := map[string]bool{}
+	for _, member := range remove {
+		removed[member] = true
+	}
+
+	members := []string{}
+	seen := map[string]bool{}
+	for _, member := range append(append([]string{}, current...), add...) {
+		if !removed[member] && !seen[member] {
+			members = append(members, member)
+			seen[member] = true
+		}
+	}
+
+	return members
+}
+
+// membersEqual returns true if both lists have the same members regardless of their order
+func membersEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]bool{}
+	for _, member := range a {
+		set[member] = true
+	}
+	for _, member := range b {
+		if !set[member] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,401 @@
+package ns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// poolFields - fields requested for pool details
+const poolFields = "poolName,health,status,size,allocated,free,fragmentation,topology,scan"
+
+// Pool - NS pool
+type Pool struct {
+	Name      string `json:"poolName"`
+	Health    string `json:"health"`
+	Status    string `json:"status"`
+	Size      int64  `json:"size"`
+	Allocated int64  `json:"allocated"`
+	Free      int64  `json:"free"`
+	// fragmentation of free space in percents
+	Fragmentation int          `json:"fragmentation"`
+	Topology      PoolTopology `json:"topology"`
+	Scan          PoolScan     `json:"scan"`
+}
+
+// VdevErrors - read, write and checksum error counters of a vdev
+type VdevErrors struct {
+	Read     int64 `json:"readErrors"`
+	Write    int64 `json:"writeErrors"`
+	Checksum int64 `json:"checksumErrors"`
+}
+
+// Total returns sum of all error counters
+func (e VdevErrors) Total() int64 {
+	return e.Read + e.Write + e.Checksum
+}
+
+func (e VdevErrors) add(other VdevErrors) VdevErrors {
+	return VdevErrors{
+		Read:     e.Read + other.Read,
+		Write:    e.Write + other.Write,
+		Checksum: e.Checksum + other.Checksum,
+	}
+}
+
+// Vdev - pool virtual device, leaf vdevs are disks, others group their children
+type Vdev struct {
+	Name  string   `json:"name"`
+	Type  VdevType `json:"type"`
+	State string   `json:"state"`
+	VdevErrors
+	Children []Vdev `json:"devices,omitempty"`
+}
+
+// Errors returns error counters of the vdev summed with all its children
+func (v Vdev) Errors() VdevErrors {
+	errors := v.VdevErrors
+	for _, child := range v.Children {
+		errors = errors.add(child.Errors())
+	}
+	return errors
+}
+
+// PoolTopology - pool vdevs by their role
+type PoolTopology struct {
+	Data    []Vdev `json:"group"`
+	Log     []Vdev `json:"log,omitempty"`
+	Cache   []Vdev `json:"cache,omitempty"`
+	Spare   []Vdev `json:"spare,omitempty"`
+	Special []Vdev `json:"special,omitempty"`
+}
+
+// Vdevs returns all top-level vdevs of the topology
+func (t PoolTopology) Vdevs() []Vdev {
+	vdevs := []Vdev{}
+	for _, list := range [][]Vdev{t.Data, t.Log, t.Cache, t.Spare, t.Special} {
+		vdevs = append(vdevs, list...)
+	}
+	return vdevs
+}
+
+// Errors returns error counters summed over all pool vdevs
+func (pool Pool) Errors() VdevErrors {
+	errors := VdevErrors{}
+	for _, vdev := range pool.Topology.Vdevs() {
+		errors = errors.add(vdev.Errors())
+	}
+	return errors
+}
+
+// PoolScanState - state of pool scrub or resilver
+type PoolScanState string
+
+const (
+	// PoolScanStateNone - pool has never been scanned
+	PoolScanStateNone PoolScanState = "none"
+	// PoolScanStateScanning - scan is in progress
+	PoolScanStateScanning PoolScanState = "scanning"
+	// PoolScanStateFinished - scan is completed
+	PoolScanStateFinished PoolScanState = "finished"
+	// PoolScanStateCanceled - scan was stopped
+	PoolScanStateCanceled PoolScanState = "canceled"
+)
+
+// PoolScan - last pool scrub or resilver status
+type PoolScan struct {
+	// "scrub" or "resilver"
+	Function  string        `json:"function"`
+	State     PoolScanState `json:"state"`
+	StartTime *time.Time    `json:"startTime,omitempty"`
+	EndTime   *time.Time    `json:"endTime,omitempty"`
+	// bytes to examine and examined so far
+	ToExamine int64 `json:"toExamine"`
+	Examined  int64 `json:"examined"`
+	// number of errors found by the scan
+	Errors int64 `json:"errors"`
+}
+
+// IsRunning returns true if scan is in progress
+func (s PoolScan) IsRunning() bool {
+	return s.State == PoolScanStateScanning
+}
+
+// Progress returns scan progress in percents
+func (s PoolScan) Progress() float64 {
+	if s.State == PoolScanStateFinished {
+		return 100
+	} else if s.ToExamine <= 0 {
+		return 0
+	}
+	return float64(s.Examined) * 100 / float64(s.ToExamine)
+}
+
+// VdevType - type of pool vdev
+type VdevType string
+
+const (
+	// VdevTypeDisk - single disk vdev, has no redundancy
+	VdevTypeDisk VdevType = "disk"
+	// VdevTypeMirror - mirror of 2 or more disks
+	VdevTypeMirror VdevType = "mirror"
+	// VdevTypeRaidz1 - single parity RAID-Z of 2 or more disks
+	VdevTypeRaidz1 VdevType = "raidz1"
+	// VdevTypeRaidz2 - double parity RAID-Z of 3 or more disks
+	VdevTypeRaidz2 VdevType = "raidz2"
+	// VdevTypeRaidz3 - triple parity RAID-Z of 4 or more disks
+	VdevTypeRaidz3 VdevType = "raidz3"
+)
+
+var vdevTypeMinDevices = map[VdevType]int{
+	VdevTypeDisk:   1,
+	VdevTypeMirror: 2,
+	VdevTypeRaidz1: 2,
+	VdevTypeRaidz2: 3,
+	VdevTypeRaidz3: 4,
+}
+
+// VdevSpec - vdev to create, devices are disk names (e.g. "c1t1d0")
+type VdevSpec struct {
+	Type    VdevType `json:"type"`
+	Devices []string `json:"devices"`
+}
+
+// PoolLayout - vdev layout of a new pool or vdevs to add to existing one
+type PoolLayout struct {
+	// data vdevs, at least one is required for a new pool
+	Data []VdevSpec `json:"group,omitempty"`
+	// separate intent log vdevs
+	Log []VdevSpec `json:"log,omitempty"`
+	// cache and spare disks
+	Cache []string `json:"cache,omitempty"`
+	Spare []string `json:"spare,omitempty"`
+}
+
+// Validate checks vdev types and device counts, each device may be used only once
+func (layout PoolLayout) Validate() error {
+	if len(layout.Data) == 0 {
+		return fmt.Errorf("Pool layout must contain at least one data vdev")
+	}
+	return layout.validateDevices()
+}
+
+func (layout PoolLayout) isEmpty() bool {
+	return len(layout.Data) == 0 && len(layout.Log) == 0 && len(layout.Cache) == 0 && len(layout.Spare) == 0
+}
+
+func (layout PoolLayout) validateDevices() error {
+	used := map[string]bool{}
+	use := func(device string) error {
+		if device == "" {
+			return fmt.Errorf("Pool layout contains empty device name")
+		} else if used[device] {
+			return fmt.Errorf("Device '%s' is used more than once in pool layout", device)
+		}
+		used[device] = true
+		return nil
+	}
+
+	for _, vdevs := range [][]VdevSpec{layout.Data, layout.Log} {
+		for _, vdev := range vdevs {
+			minDevices, ok := vdevTypeMinDevices[vdev.Type]
+			if !ok {
+				return fmt.Errorf("Unknown vdev type '%s'", vdev.Type)
+			} else if vdev.Type == VdevTypeDisk && len(vdev.Devices) != 1 {
+				return fmt.Errorf("Vdev of type '%s' must contain exactly one device, got %v", vdev.Type, vdev.Devices)
+			} else if len(vdev.Devices) < minDevices {
+				return fmt.Errorf(
+					"Vdev of type '%s' requires at least %d devices, got %v",
+					vdev.Type,
+					minDevices,
+					vdev.Devices,
+				)
+			}
+			for _, device := range vdev.Devices {
+				if err := use(device); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, devices := range [][]string{layout.Cache, layout.Spare} {
+		for _, device := range devices {
+			if err := use(device); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type nefStoragePoolsCreateRequest struct {
+	PoolName string     `json:"poolName"`
+	Topology PoolLayout `json:"topology"`
+	Force    bool       `json:"force,omitempty"`
+}
+
+type nefStoragePoolsImportRequest struct {
+	PoolName    string `json:"poolName"`
+	NewPoolName string `json:"newPoolName,omitempty"`
+	Force       bool   `json:"force,omitempty"`
+}
+
+type nefStoragePoolsExportRequest struct {
+	Force bool `json:"force,omitempty"`
+}
+
+// GetPool returns NexentaStor pool details by its name
+func (p *Provider) GetPool(name string) (pool Pool, err error) {
+	if name == "" {
+		return pool, fmt.Errorf("Pool name is required")
+	}
+
+	uri := p.RestClient.BuildURI(fmt.Sprintf("storage/pools/%s", url.PathEscape(name)), map[string]string{
+		"fields": poolFields,
+	})
+
+	err = p.sendRequestWithStruct(http.MethodGet, uri, nil, &pool)
+	return pool, err
+}
+
+// CreatePoolParams - params to create a pool
+type CreatePoolParams struct {
+	// pool name
+	Name string
+	// vdev layout
+	Layout PoolLayout
+	// use devices even if they contain data of another pool
+	Force bool
+}
+
+// CreatePool creates pool with specified vdev layout and returns it
+func (p *Provider) CreatePool(params CreatePoolParams) (Pool, error) {
+	if params.Name == "" {
+		return Pool{}, fmt.Errorf("Pool name is required")
+	}
+	if err := params.Layout.Validate(); err != nil {
+		return Pool{}, &NefError{Code: "EBADARG", Err: err}
+	}
+
+	err := p.sendRequest(http.MethodPost, "storage/pools", nefStoragePoolsCreateRequest{
+		PoolName: params.Name,
+		Topology: params.Layout,
+		Force:    params.Force,
+	})
+	if err != nil {
+		return Pool{}, err
+	}
+
+	return p.GetPool(params.Name)
+}
+
+// DestroyPool destroys pool and all its data
+func (p *Provider) DestroyPool(name string) error {
+	if name == "" {
+		return fmt.Errorf("Pool name is required")
+	}
+
+	uri := p.RestClient.BuildURI(fmt.Sprintf("storage/pools/%s", url.PathEscape(name)), map[string]string{
+		"destroyData": "true",
+	})
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// ImportPoolParams - params to import a pool
+type ImportPoolParams struct {
+	// name of the pool to import
+	Name string
+	// import pool under a new name
+	NewName string
+	// import pool even if it is in use by another system
+	Force bool
+}
+
+// ImportPool imports pool and returns it
+func (p *Provider) ImportPool(params ImportPoolParams) (Pool, error) {
+	if params.Name == "" {
+		return Pool{}, fmt.Errorf("Pool name is required")
+	}
+
+	err := p.sendRequest(http.MethodPost, "storage/pools/import", nefStoragePoolsImportRequest{
+		PoolName:    params.Name,
+		NewPoolName: params.NewName,
+		Force:       params.Force,
+	})
+	if err != nil {
+		return Pool{}, err
+	}
+
+	name := params.Name
+	if params.NewName != "" {
+		name = params.NewName
+	}
+
+	return p.GetPool(name)
+}
+
+// ExportPoolParams - params to export a pool
+type ExportPoolParams struct {
+	// export pool even if its datasets are in use
+	Force bool
+}
+
+// ExportPool exports pool, so it can be imported on another system
+func (p *Provider) ExportPool(name string, params ExportPoolParams) error {
+	if name == "" {
+		return fmt.Errorf("Pool name is required")
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/export", url.PathEscape(name))
+
+	return p.sendRequest(http.MethodPost, uri, nefStoragePoolsExportRequest{Force: params.Force})
+}
+
+// StartPoolScrub starts pool scrub, already running scrub is not treated as an error
+func (p *Provider) StartPoolScrub(name string) error {
+	if name == "" {
+		return fmt.Errorf("Pool name is required")
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/scrub", url.PathEscape(name))
+
+	err := p.sendRequest(http.MethodPost, uri, nil)
+	if IsBusyNefError(err) {
+		scan, scanErr := p.GetPoolScanStatus(name)
+		if scanErr == nil && scan.Function == "scrub" && scan.IsRunning() {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// StopPoolScrub stops running pool scrub
+func (p *Provider) StopPoolScrub(name string) error {
+	if name == "" {
+		return fmt.Errorf("Pool name is required")
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/scrub", url.PathEscape(name))
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// GetPoolScanStatus returns status of the last pool scan, it's either scrub or resilver (see PoolScan.Function)
+func (p *Provider) GetPoolScanStatus(name string) (PoolScan, error) {
+	if name == "" {
+		return PoolScan{}, fmt.Errorf("Pool name is required")
+	}
+
+	uri := p.RestClient.BuildURI(fmt.Sprintf("storage/pools/%s", url.PathEscape(name)), map[string]string{
+		"fields": "scan",
+	})
+
+	pool := Pool{}
+	err := p.sendRequestWithStruct(http.MethodGet, uri, nil, &pool)
+	return pool.Scan, err
+}
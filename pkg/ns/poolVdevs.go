@@ -0,0 +1,229 @@
+package ns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PoolJob - handle of a pool operation that continues in background, e.g. resilver after disk replacement
+type PoolJob struct {
+	Pool string
+	// NS async job ID, empty if NS has completed the request synchronously
+	JobID string
+	// pool scan status got before the job has been started,
+	// the job is done when a resilver started after this scan is finished
+	LastScan PoolScan
+
+	provider ProviderInterface
+}
+
+// PoolJobStatus - pool job progress
+type PoolJobStatus struct {
+	Done bool
+	// progress in percents
+	Progress float64
+	// resilver status, empty until the resilver is started
+	Scan PoolScan
+}
+
+// NewPoolJob creates handle to monitor pool job started by another process,
+// lastScan is the pool scan status got before the job has been started
+func NewPoolJob(provider ProviderInterface, pool, jobID string, lastScan PoolScan) PoolJob {
+	return PoolJob{Pool: pool, JobID: jobID, LastScan: lastScan, provider: provider}
+}
+
+// Status returns job status, the job is done when NS job is completed
+// and the resilver started after the job start is finished
+func (job PoolJob) Status() (PoolJobStatus, error) {
+	if job.JobID != "" {
+		done, err := job.provider.IsJobDone(job.JobID)
+		if err != nil {
+			return PoolJobStatus{}, err
+		} else if !done {
+			return PoolJobStatus{}, nil
+		}
+	}
+
+	scan, err := job.provider.GetPoolScanStatus(job.Pool)
+	if err != nil {
+		return PoolJobStatus{}, err
+	} else if scan.Function != "resilver" || !job.isNewScan(scan) {
+		// resilver hasn't been started yet, pool may still be scrubbed or show previous resilver
+		return PoolJobStatus{}, nil
+	} else if scan.IsRunning() {
+		return PoolJobStatus{Progress: scan.Progress(), Scan: scan}, nil
+	}
+
+	return PoolJobStatus{Done: true, Progress: 100, Scan: scan}, nil
+}
+
+// isNewScan returns true if the scan has been started after the job start
+func (job PoolJob) isNewScan(scan PoolScan) bool {
+	if scan.StartTime == nil {
+		return false
+	}
+	return job.LastScan.StartTime == nil || scan.StartTime.After(*job.LastScan.StartTime)
+}
+
+// Wait polls job status until the job is done, onProgress is called with each status if set
+func (job PoolJob) Wait(timeout time.Duration, onProgress func(PoolJobStatus)) (PoolJobStatus, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := job.Status()
+		if err != nil {
+			return status, err
+		}
+		if onProgress != nil {
+			onProgress(status)
+		}
+		if status.Done {
+			return status, nil
+		} else if time.Now().After(deadline) {
+			return status, fmt.Errorf(
+				"Pool '%s' job is not done after %s, progress: %.1f%%",
+				job.Pool,
+				timeout,
+				status.Progress,
+			)
+		}
+		time.Sleep(checkJobStatusInterval)
+	}
+}
+
+type nefStoragePoolsVdevsRequest struct {
+	Topology PoolLayout `json:"topology"`
+}
+
+type nefStoragePoolsDeviceRequest struct {
+	Device string `json:"device"`
+}
+
+// findPoolDevice returns role of the device or top-level vdev in pool topology, empty string if not found
+func findPoolDevice(pool Pool, device string) DiskUsage {
+	if usage, ok := getDiskPoolUsages([]Pool{pool})[device]; ok {
+		return usage.usage
+	}
+	for _, vdev := range pool.Topology.Log {
+		if vdev.Name == device {
+			return DiskUsageLog
+		}
+	}
+	for _, vdev := range pool.Topology.Vdevs() {
+		if vdev.Name == device {
+			return DiskUsageVdev
+		}
+	}
+	return ""
+}
+
+// AddPoolVdevs adds data vdevs, log vdevs, cache and spare devices to the pool
+func (p *Provider) AddPoolVdevs(pool string, layout PoolLayout) error {
+	if pool == "" {
+		return fmt.Errorf("Pool name is required")
+	} else if layout.isEmpty() {
+		return &NefError{Code: "EBADARG", Err: fmt.Errorf("No vdevs or devices to add to pool '%s'", pool)}
+	}
+	if err := layout.validateDevices(); err != nil {
+		return &NefError{Code: "EBADARG", Err: err}
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/vdevs", url.PathEscape(pool))
+
+	return p.sendRequest(http.MethodPost, uri, nefStoragePoolsVdevsRequest{Topology: layout})
+}
+
+// RemovePoolDevice removes cache device, log device or log vdev from the pool,
+// device that doesn't belong to the pool is not treated as an error
+func (p *Provider) RemovePoolDevice(pool, device string) error {
+	if pool == "" || device == "" {
+		return fmt.Errorf("Pool name and device are required, got pool '%s' and device '%s'", pool, device)
+	}
+
+	poolDetails, err := p.GetPool(pool)
+	if err != nil {
+		return err
+	}
+
+	switch findPoolDevice(poolDetails, device) {
+	case "":
+		return nil
+	case DiskUsageCache, DiskUsageLog:
+	default:
+		return &NefError{
+			Code: "EBADARG",
+			Err:  fmt.Errorf("Device '%s' of pool '%s' is not a cache or log device", device, pool),
+		}
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/vdevs/%s", url.PathEscape(pool), url.PathEscape(device))
+
+	return p.sendRequest(http.MethodDelete, uri, nil)
+}
+
+// AttachPoolDevice attaches new device to existing one making a mirror or extending it,
+// returned job is done when the new device is resilvered
+func (p *Provider) AttachPoolDevice(pool, device, newDevice string) (PoolJob, error) {
+	if pool == "" || device == "" || newDevice == "" {
+		return PoolJob{}, fmt.Errorf(
+			"Pool name, device and new device are required, got '%s', '%s' and '%s'",
+			pool,
+			device,
+			newDevice,
+		)
+	}
+
+	lastScan, err := p.GetPoolScanStatus(pool)
+	if err != nil {
+		return PoolJob{}, err
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/vdevs/%s/attach", url.PathEscape(pool), url.PathEscape(device))
+
+	jobID, err := p.sendAsyncRequest(http.MethodPost, uri, nefStoragePoolsDeviceRequest{Device: newDevice})
+	if err != nil {
+		return PoolJob{}, err
+	}
+
+	return NewPoolJob(p, pool, jobID, lastScan), nil
+}
+
+// DetachPoolDevice detaches device from a mirror
+func (p *Provider) DetachPoolDevice(pool, device string) error {
+	if pool == "" || device == "" {
+		return fmt.Errorf("Pool name and device are required, got pool '%s' and device '%s'", pool, device)
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/vdevs/%s/detach", url.PathEscape(pool), url.PathEscape(device))
+
+	return p.sendRequest(http.MethodPost, uri, nil)
+}
+
+// ReplacePoolDevice replaces pool device with a new one,
+// returned job is done when the new device is resilvered
+func (p *Provider) ReplacePoolDevice(pool, device, newDevice string) (PoolJob, error) {
+	if pool == "" || device == "" || newDevice == "" {
+		return PoolJob{}, fmt.Errorf(
+			"Pool name, device and new device are required, got '%s', '%s' and '%s'",
+			pool,
+			device,
+			newDevice,
+		)
+	}
+
+	lastScan, err := p.GetPoolScanStatus(pool)
+	if err != nil {
+		return PoolJob{}, err
+	}
+
+	uri := fmt.Sprintf("storage/pools/%s/vdevs/%s/replace", url.PathEscape(pool), url.PathEscape(device))
+
+	jobID, err := p.sendAsyncRequest(http.MethodPost, uri, nefStoragePoolsDeviceRequest{Device: newDevice})
+	if err != nil {
+		return PoolJob{}, err
+	}
+
+	return NewPoolJob(p, pool, jobID, lastScan), nil
+}
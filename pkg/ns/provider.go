@@ -7,9 +7,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
-
-	"github.com/Nexenta/go-nexentastor/pkg/rest"
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/rest"
 )
 
 const (
@@ -27,9 +26,32 @@ type ProviderInterface interface {
 
 	// pools
 	GetPools() ([]Pool, error)
+	GetPool(name string) (Pool, error)
+	CreatePool(params CreatePoolParams) (Pool, error)
+	DestroyPool(name string) error
+	ImportPool(params ImportPoolParams) (Pool, error)
+	ExportPool(name string, params ExportPoolParams) error
+	StartPoolScrub(name string) error
+	StopPoolScrub(name string) error
+	GetPoolScanStatus(name string) (PoolScan, error)
+	AddPoolSpares(pool string, disks []string) error
+	RemovePoolSpare(pool, disk string) error
+	AddPoolVdevs(pool string, layout PoolLayout) error
+	RemovePoolDevice(pool, device string) error
+	AttachPoolDevice(pool, device, newDevice string) (PoolJob, error)
+	DetachPoolDevice(pool, device string) error
+	ReplacePoolDevice(pool, device, newDevice string) (PoolJob, error)
+
+	// disks
+	GetDisks() ([]Disk, error)
+	GetDisk(name string) (Disk, error)
+	GetFailedDisks() ([]Disk, error)
+	GetEnclosures() ([]Enclosure, error)
+	SetDiskLocateLED(name string, on bool) error
 
 	// filesystems
-	CreateFilesystem(params CreateFilesystemParams) error
+	CreateFilesystem(params CreateFilesystemParams) (Filesystem, error)
+	EnsureFilesystem(params EnsureFilesystemParams) (Filesystem, EnsureResult, error)
 	UpdateFilesystem(path string, params UpdateFilesystemParams) error
 	DestroyFilesystem(path string, params DestroyFilesystemParams) error
 	SetFilesystemACL(path string, aclRuleSet ACLRuleSet) error
@@ -40,55 +62,104 @@ type ProviderInterface interface {
 	GetFilesystemsSlice(parent string, limit, offset int) ([]Filesystem, error)
 
 	// filesystems - nfs share
-	CreateNfsShare(params CreateNfsShareParams) error
+	CreateNfsShare(params CreateNfsShareParams) (NfsShare, error)
+	EnsureNfsShare(params CreateNfsShareParams) (EnsureResult, error)
+	GetNfsShare(path string) (NfsShare, error)
+	UpdateNfsShare(params CreateNfsShareParams) error
 	DeleteNfsShare(path string) error
 
 	// filesystems - smb share
-	CreateSmbShare(params CreateSmbShareParams) error
+	CreateSmbShare(params CreateSmbShareParams) (SmbShare, error)
+	EnsureSmbShare(params CreateSmbShareParams) (EnsureResult, error)
+	UpdateSmbShare(params CreateSmbShareParams) error
 	DeleteSmbShare(path string) error
+	GetSmbShare(path string) (SmbShare, error)
 	GetSmbShareName(path string) (string, error)
 
 	// snapshots
-	CreateSnapshot(params CreateSnapshotParams) error
+	CreateSnapshot(params CreateSnapshotParams) (Snapshot, error)
+	EnsureSnapshot(params CreateSnapshotParams) (Snapshot, EnsureResult, error)
 	DestroySnapshot(path string) error
 	GetSnapshot(path string) (Snapshot, error)
 	GetSnapshots(volumePath string, recursive bool) ([]Snapshot, error)
-	CloneSnapshot(path string, params CloneSnapshotParams) error
+	CloneSnapshot(path string, params CloneSnapshotParams) (CloneSnapshotResult, error)
+	CloneVolumeSnapshot(path string, params CloneVolumeSnapshotParams) (CloneSnapshotResult, error)
 	PromoteFilesystem(path string) error
+	DestroyTree(path string, params DestroyTreeParams) (DestroyTreeResult, error)
 
 	// volumes
-	CreateVolume(params CreateVolumeParams) error
+	CreateVolume(params CreateVolumeParams) (Volume, error)
+	EnsureVolume(params EnsureVolumeParams) (Volume, EnsureResult, error)
 	GetVolume(path string) (Volume, error)
 	GetVolumes(parent string) ([]Volume, error)
 	UpdateVolume(path string, params UpdateVolumeParams) error
-	DestroyVolume(path string, params DestroyVolumeParams) error
+	ResizeVolume(path string, params ResizeVolumeParams) (ResizeVolumeResult, error)
+	DestroyVolume(path string, params DestroyVolumeParams) (DestroyVolumeResult, error)
 	GetVolumeGroup(path string) (VolumeGroup, error)
+	GetVolumeGroups(parent string) ([]VolumeGroup, error)
+	GetVolumeGroupsSlice(parent string, limit, offset int) ([]VolumeGroup, error)
+	CreateVolumeGroup(params CreateVolumeGroupParams) (VolumeGroup, error)
+	UpdateVolumeGroup(path string, params UpdateVolumeGroupParams) error
+	DestroyVolumeGroup(path string, params DestroyVolumeGroupParams) error
 	GetVolumesWithStartingToken(parent string, startingToken string, limit int) ([]Volume, string, error)
 	PromoteVolume(path string) error
 
 	// iSCSI
-	CreateLunMapping(params CreateLunMappingParams) error
+	CreateLunMapping(params CreateLunMappingParams) (LunMapping, error)
 	GetLunMapping(path string) (LunMapping, error)
 	GetAllLunMappings() (lunMappings []LunMapping, err error)
 	GetLunMappings(params GetLunMappingsParams) (lunMappings []LunMapping, err error)
 	DestroyLunMapping(id string) error
-	CreateISCSITarget(params CreateISCSITargetParams) error
+	GetISCSISessions() ([]ISCSISession, error)
+	CreateISCSITarget(params CreateISCSITargetParams) (ISCSITarget, error)
 	UpdateISCSITarget(name string, params UpdateISCSITargetParams) error
+	DeleteISCSITarget(name string) error
 	GetISCSITarget(name string) (target ISCSITarget, err error)
 	GetISCSITargets(name string) (target []ISCSITarget, err error)
 	GetTargetGroups() ([]TargetGroup, error)
 	GetTargetGroup(name string) (targetGroup TargetGroup, err error)
 	CreateUpdateTargetGroup(params CreateTargetGroupParams) error
-	CreateHostGroup(params CreateHostGroupParams) error
-	GetHostGroups() ([]nefHostGroup, error)
-	UpdateHostGroup(path string, params UpdateHostGroupParams) error
+	UpdateTargetGroup(name string, params UpdateTargetGroupParams) error
+	AddTargetGroupMembers(name string, members []string) error
+	RemoveTargetGroupMembers(name string, members []string) error
+	DeleteTargetGroup(name string) error
+	CreateHostGroup(params CreateHostGroupParams) (HostGroup, error)
+	GetHostGroups() ([]HostGroup, error)
+	GetHostGroup(name string) (HostGroup, error)
+	UpdateHostGroup(name string, params UpdateHostGroupParams) error
+	DeleteHostGroup(name string) error
+	AddHostGroupMembers(name string, members []string) error
+	RemoveHostGroupMembers(name string, members []string) error
 	GetRemoteInitiator(name string) (remoteInitiator RemoteInitiator, err error)
 	CreateRemoteInitiator(params CreateRemoteInitiatorParams) error
 	UpdateRemoteInitiator(name string, params UpdateRemoteInitiatorParams) error
+	ListRemoteInitiators() ([]RemoteInitiator, error)
+	DeleteRemoteInitiator(name string) error
+	SetMutualChap(params SetMutualChapParams) error
+
+	ExportVolumeISCSI(volume string, initiatorIQNs []string, params ExportVolumeISCSIParams) (
+		ExportVolumeISCSIResult,
+		error,
+	)
+	UnexportVolumeISCSI(volume string) (UnexportVolumeISCSIResult, error)
+
+	// Fibre Channel
+	GetFCTargetPorts() ([]FCTargetPort, error)
+	CreateFCHostGroup(params CreateFCHostGroupParams) (HostGroup, error)
+	CreateFCTargetGroup(params CreateFCTargetGroupParams) (TargetGroup, error)
 
 	// logicalUnits
 	GetLogicalUnits() (logicalUnits []LogicalUnit, err error)
 	GetLogicalUnitsSlice(limit, offset int) ([]LogicalUnit, error)
+	GetLogicalUnit(guid string) (LogicalUnit, error)
+	GetLogicalUnitByVolume(volume string) (LogicalUnit, error)
+	CreateLogicalUnit(params CreateLogicalUnitParams) (LogicalUnit, error)
+	UpdateLogicalUnit(guid string, params UpdateLogicalUnitParams) error
+	DeleteLogicalUnit(guid string) error
+
+	// analytics
+	GetAnalytics(query AnalyticsQuery) (AnalyticsSeries, error)
+	GetDatasetsAnalytics(parent string, query AnalyticsQuery) ([]AnalyticsSeries, error)
 
 	// node
 	RebootNode() error
@@ -100,7 +171,9 @@ type Provider struct {
 	Username   string
 	Password   string
 	RestClient rest.ClientInterface
-	Log        *logrus.Entry
+	Log        logger.Logger
+	// Hooks to collect metrics and traces, optional
+	Hooks Hooks
 }
 
 func (p *Provider) String() string {
@@ -182,9 +255,32 @@ func (p *Provider) sendRequest(method, path string, data interface{}) error {
 func (p *Provider) doAuthRequest(method, path string, data interface{}) ([]byte, error) {
 	l := p.Log.WithField("func", "doAuthRequest()")
 
+	jobID, bodyBytes, err := p.startAuthRequest(method, path, data)
+	if err == nil && jobID != "" {
+		// this is an async job
+		err = p.waitForAsyncJob(jobID)
+		if err != nil {
+			l.Debugf("waitForAsyncJob() error: %s", err)
+		}
+	}
+
+	return bodyBytes, err
+}
+
+// sendAsyncRequest sends request without waiting for async job completion,
+// returns job ID or empty string if the request has been completed synchronously
+func (p *Provider) sendAsyncRequest(method, path string, data interface{}) (string, error) {
+	jobID, _, err := p.startAuthRequest(method, path, data)
+	return jobID, err
+}
+
+// startAuthRequest sends request and logs in again if needed, returns async job ID if NS has started a job
+func (p *Provider) startAuthRequest(method, path string, data interface{}) (string, []byte, error) {
+	l := p.Log.WithField("func", "startAuthRequest()")
+
 	statusCode, bodyBytes, err := p.RestClient.Send(method, path, data)
 	if err != nil {
-		return bodyBytes, err
+		return "", bodyBytes, err
 	}
 
 	nefError := p.parseNefError(bodyBytes, "checking login status")
@@ -196,31 +292,26 @@ func (p *Provider) doAuthRequest(method, path string, data interface{}) ([]byte,
 
 		err = p.LogIn()
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
 		// send original request again
 		statusCode, bodyBytes, err = p.RestClient.Send(method, path, data)
 		if err != nil {
-			return bodyBytes, err
+			return "", bodyBytes, err
 		}
 	}
 
 	if statusCode == http.StatusAccepted {
-		// this is an async job
-		var href string
-		href, err = p.parseAsyncJobHref(bodyBytes)
+		href, err := p.parseAsyncJobHref(bodyBytes)
 		if err != nil {
-			return bodyBytes, err
-		}
-
-		err = p.waitForAsyncJob(strings.TrimPrefix(href, "/jobStatus/"))
-		if err != nil {
-			l.Debugf("waitForAsyncJob() error: %s", err)
+			return "", bodyBytes, err
 		}
+		return strings.TrimPrefix(href, "/jobStatus/"), bodyBytes, nil
 	} else if statusCode >= 300 {
 		nefError := p.parseNefError(bodyBytes, "request error")
 		if nefError != nil {
+			p.observeNefError(method, path, nefError)
 			err = nefError
 		} else {
 			err = fmt.Errorf(
@@ -231,7 +322,7 @@ func (p *Provider) doAuthRequest(method, path string, data interface{}) ([]byte,
 		}
 	}
 
-	return bodyBytes, err
+	return "", bodyBytes, err
 }
 
 func (p *Provider) parseAsyncJobHref(bodyBytes []byte) (string, error) {
@@ -256,6 +347,9 @@ func (p *Provider) waitForAsyncJob(jobID string) (err error) {
 	timer := time.NewTimer(0)
 	timeout := time.After(checkJobStatusTimeout)
 	startTime := time.Now()
+	defer func() {
+		p.hooks().ObserveJobWait(time.Since(startTime), err)
+	}()
 
 	for {
 		select {
@@ -284,15 +378,19 @@ type ProviderArgs struct {
 	Address  string
 	Username string
 	Password string
-	Log      *logrus.Entry
+	// logger, messages are discarded if not set
+	Log logger.Logger
 
 	// InsecureSkipVerify controls whether a client verifies the server's certificate chain and host name.
 	InsecureSkipVerify bool
+
+	// Hooks to collect request metrics and traces, optional
+	Hooks Hooks
 }
 
 // NewProvider creates NexentaStor provider instance
 func NewProvider(args ProviderArgs) (ProviderInterface, error) {
-	l := args.Log.WithFields(logrus.Fields{
+	l := logger.OrNoop(args.Log).WithFields(logger.Fields{
 		"cmp": "NSProvider",
 		"ns":  args.Address,
 	})
@@ -305,6 +403,8 @@ func NewProvider(args ProviderArgs) (ProviderInterface, error) {
 		Address:            args.Address,
 		Log:                l,
 		InsecureSkipVerify: args.InsecureSkipVerify,
+		Hooks:              args.Hooks,
+		PathTemplate:       nefPathTemplate,
 	})
 
 	l.Debugf("created for '%s'", args.Address)
@@ -314,5 +414,6 @@ func NewProvider(args ProviderArgs) (ProviderInterface, error) {
 		Password:   args.Password,
 		RestClient: restClient,
 		Log:        l,
+		Hooks:      args.Hooks,
 	}, nil
 }
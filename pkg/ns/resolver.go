@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/sirupsen/logrus"
+	"go-nexentastor/pkg/logger"
 )
 
 // Resolver - NexentaStor cluster API provider
 type Resolver struct {
 	Nodes []ProviderInterface
-	Log   *logrus.Entry
+	Log   logger.Logger
 }
 
 // Resolve returns one NS from the list of NSs by provided pool/dataset/fs path
@@ -125,15 +125,19 @@ type ResolverArgs struct {
 	Address  string
 	Username string
 	Password string
-	Log      *logrus.Entry
+	// logger, messages are discarded if not set
+	Log logger.Logger
 
 	// InsecureSkipVerify controls whether a client verifies the server's certificate chain and host name.
 	InsecureSkipVerify bool
+
+	// Hooks to collect request metrics and traces of all nodes, optional
+	Hooks Hooks
 }
 
 // NewResolver creates NexentaStor resolver instance based on configuration
 func NewResolver(args ResolverArgs) (*Resolver, error) {
-	l := args.Log.WithFields(logrus.Fields{
+	l := logger.OrNoop(args.Log).WithFields(logger.Fields{
 		"cmp": "NSResolver",
 		"ns":  args.Address,
 	})
@@ -151,6 +155,7 @@ func NewResolver(args ResolverArgs) (*Resolver, error) {
 			Password:           args.Password,
 			Log:                l,
 			InsecureSkipVerify: args.InsecureSkipVerify,
+			Hooks:              args.Hooks,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("Cannot create provider for %s NexentaStor: %s", address, err)
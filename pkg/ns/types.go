@@ -16,6 +16,17 @@ const (
 	ACLReadWrite
 )
 
+// DatasetType - type of NexentaStor dataset
+type DatasetType string
+
+const (
+	// DatasetTypeFilesystem - filesystem dataset
+	DatasetTypeFilesystem DatasetType = "filesystem"
+
+	// DatasetTypeVolume - volume (zvol) dataset
+	DatasetTypeVolume DatasetType = "volume"
+)
+
 // License - NexentaStor license
 type License struct {
 	Valid   bool   `json:"valid"`
@@ -30,6 +41,8 @@ type Filesystem struct {
 	SharedOverSmb  bool   `json:"sharedOverSmb"`
 	BytesAvailable int64  `json:"bytesAvailable"`
 	BytesUsed      int64  `json:"bytesUsed"`
+	// referenced quota size in bytes, 0 if not set
+	ReferencedQuotaSize int64 `json:"referencedQuotaSize"`
 }
 
 // Service response - NexentaStor /rsf/clusters
@@ -56,44 +69,71 @@ type Status struct {
 
 // Volume - NexentaStor volume
 type Volume struct {
-	Path 		string `json:"path"`
+	Path           string `json:"path"`
 	BytesAvailable int64  `json:"bytesAvailable"`
 	BytesUsed      int64  `json:"bytesUsed"`
 	VolumeSize     int64  `json:"volumeSize"`
+
+	VolumeBlockSize           int64     `json:"volumeBlockSize"`
+	CompressionMode           string    `json:"compressionMode"`
+	DedupMode                 string    `json:"dedupMode"`
+	SyncMode                  string    `json:"syncMode"`
+	WritebackCacheDisabled    bool      `json:"writebackCacheDisabled"`
+	ReferencedReservationSize int64     `json:"referencedReservationSize"`
+	CreationTime              time.Time `json:"creationTime"`
+	// snapshot the volume was cloned from, empty if the volume is not a clone
+	Origin string `json:"origin"`
+}
+
+func (volume *Volume) String() string {
+	return volume.Path
+}
+
+// IsSparse - returns true if volume has no reserved space (thin provisioned)
+func (volume *Volume) IsSparse() bool {
+	return volume.ReferencedReservationSize == 0
 }
 
 // VolumeGroup - NexentaStor volumeGroup
 type VolumeGroup struct {
-    Path           string `json:"path"`
-    BytesAvailable int64  `json:"bytesAvailable"`
-    BytesUsed      int64  `json:"bytesUsed"`
+	Path            string `json:"path"`
+	BytesAvailable  int64  `json:"bytesAvailable"`
+	BytesUsed       int64  `json:"bytesUsed"`
+	VolumeBlockSize int64  `json:"volumeBlockSize"`
+	CompressionMode string `json:"compressionMode"`
 }
 
 // LunMapping - NexentaStor lunmapping
 type LunMapping struct {
-	Id			string `json:"id"`
+	Id          string `json:"id"`
 	Volume      string `json:"volume"`
 	TargetGroup string `json:"targetGroup"`
 	HostGroup   string `json:"hostGroup"`
-	Lun 		int    `json:"lun"`
+	Lun         int    `json:"lun"`
 }
 
 // RemoteInitiator - NexentaStor remote initiator for CHAP access
 type RemoteInitiator struct {
-	Name             string `json:"name"`
-	ChapUser   		 string `json:"chapUser"`
-	ChapSecretSet    bool   `json:"chapSecretSet"`
+	Name          string `json:"name"`
+	ChapUser      string `json:"chapUser"`
+	ChapSecretSet bool   `json:"chapSecretSet"`
 }
 
 // ISCSITarget - NexentaStor iSCSI target
 type ISCSITarget struct {
-	Name 				string
-	State 				string
-	Authentication 		string
-	Alias 				string
-	ChapSecretSet 		bool
-	ChapUser 			string
-	Portals     		[]Portal
+	Name           string   `json:"name"`
+	State          string   `json:"state"`
+	Authentication string   `json:"authentication"`
+	Alias          string   `json:"alias"`
+	ChapSecretSet  bool     `json:"chapSecretSet"`
+	ChapUser       string   `json:"chapUser"`
+	Portals        []Portal `json:"portals"`
+}
+
+// ISCSISession - NexentaStor active iSCSI session
+type ISCSISession struct {
+	Initiator string `json:"initiator"`
+	Target    string `json:"target"`
 }
 
 // LogicalUnit - NexentaStor logicalUnit
@@ -142,21 +182,35 @@ func (snapshot *Snapshot) String() string {
 	return snapshot.Path
 }
 
+// CloneSnapshotResult - dataset created from a snapshot
+type CloneSnapshotResult struct {
+	Path string      `json:"path"`
+	Type DatasetType `json:"type"`
+
+	// created filesystem, set if Type is DatasetTypeFilesystem
+	Filesystem *Filesystem `json:"filesystem,omitempty"`
+
+	// created volume, set if Type is DatasetTypeVolume
+	Volume *Volume `json:"volume,omitempty"`
+}
+
 // RSFCluster - RSF cluster with a name
 type RSFCluster struct {
-	Name string `json:"clusterName"`
+	Name     string    `json:"clusterName"`
 	Services []Service `json:"services"`
 	Health   Health    `json:"health"`
 }
 
-// Pool - NS pool
-type Pool struct {
-	Name string `json:"poolName"`
+// HostGroup - NexentaStor SAN hostGroup (group of initiators)
+type HostGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
 }
 
+// TargetGroup - NexentaStor SAN targetGroup
 type TargetGroup struct {
-	Name string 		`json:"name"`
-	Members []string 	`json:"members"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
 }
 
 // NEF request/response types
@@ -179,7 +233,7 @@ type nefStorageFilesystemsResponse struct {
 }
 
 type nefStorageVolumesResponse struct {
-    Data []Volume `json:"data"`
+	Data []Volume `json:"data"`
 }
 
 type nefSanLogicalUnitsResponse struct {
@@ -187,11 +241,11 @@ type nefSanLogicalUnitsResponse struct {
 }
 
 type nefStorageVolumeGroupsResponse struct {
-    Data []VolumeGroup `json:"data"`
+	Data []VolumeGroup `json:"data"`
 }
 
 type nefLunMappingsResponse struct {
-	Data[]LunMapping `json:"data"`
+	Data []LunMapping `json:"data"`
 }
 
 type nefStorageSnapshotsResponse struct {
@@ -204,20 +258,46 @@ type nefNasNfsRequest struct {
 	SecurityContexts []nefNasNfsRequestSecurityContext `json:"securityContexts"`
 }
 type nefNasNfsRequestSecurityContext struct {
-	SecurityModes []string 							`json:"securityModes"`
-	ReadWriteList []NfsRuleList						`json:"readWriteList"`
-	ReadOnlyList  []NfsRuleList						`json:"readOnlyList"`
+	SecurityModes []string      `json:"securityModes"`
+	ReadWriteList []NfsRuleList `json:"readWriteList"`
+	ReadOnlyList  []NfsRuleList `json:"readOnlyList"`
 }
 
 type NfsRuleList struct {
-	Etype 	string 		`json:"etype"`
-	Entity 	string 		`json:"entity"`
-	Mask	int 		`json:"mask"`
+	Etype  string `json:"etype"`
+	Entity string `json:"entity"`
+	Mask   int    `json:"mask"`
 }
 
 type Portal struct {
 	Address string `json:"address"`
-	Port 	int    `json:"port"`
+	Port    int    `json:"port"`
+}
+
+type nefNasNfsResponse struct {
+	Filesystem       string                            `json:"filesystem"`
+	SecurityContexts []nefNasNfsRequestSecurityContext `json:"securityContexts"`
+}
+
+// NfsShare - NexentaStor NFS share access lists
+type NfsShare struct {
+	Filesystem    string        `json:"filesystem"`
+	ReadWriteList []NfsRuleList `json:"readWriteList"`
+	ReadOnlyList  []NfsRuleList `json:"readOnlyList"`
+}
+
+// SmbShare - NexentaStor SMB share
+type SmbShare struct {
+	Filesystem string `json:"filesystem"`
+	ShareName  string `json:"shareName"`
+}
+
+type nefNasNfsUpdateRequest struct {
+	SecurityContexts []nefNasNfsRequestSecurityContext `json:"securityContexts"`
+}
+
+type nefNasSmbUpdateRequest struct {
+	ShareName string `json:"shareName"`
 }
 
 type nefNasSmbResponse struct {
@@ -243,19 +323,22 @@ type nefJobStatusResponseLink struct {
 	Href string `json:"href"`
 }
 
-type nefHostGroup struct {
-	Members 	[]string  `json:"members"`
-	Name 		string 	  `json:"name"`
-}
-
 type nefHostGroupsResponse struct {
-	Data 	[]nefHostGroup  `json:"data"`
+	Data []HostGroup `json:"data"`
 }
 
 type nefTargetGroupsResponse struct {
-	Data 	[]TargetGroup  `json:"data"`
+	Data []TargetGroup `json:"data"`
 }
 
 type nefTargetsResponse struct {
-	Data 	[]ISCSITarget  `json:"data"`
+	Data []ISCSITarget `json:"data"`
+}
+
+type nefISCSISessionsResponse struct {
+	Data []ISCSISession `json:"data"`
+}
+
+type nefRemoteInitiatorsResponse struct {
+	Data []RemoteInitiator `json:"data"`
 }
@@ -12,7 +12,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"go-nexentastor/pkg/logger"
 )
 
 const requestTimeout = 30 * time.Second
@@ -22,7 +22,10 @@ type Client struct {
 	address    string
 	authToken  string
 	httpClient *http.Client
-	log        *logrus.Entry
+	log        logger.Logger
+
+	hooks        Hooks
+	pathTemplate func(path string) string
 
 	mux       sync.Mutex
 	requestID int64
@@ -59,16 +62,42 @@ func (c *Client) BuildURI(uri string, params map[string]string) string {
 func (c *Client) Send(method, path string, data interface{}) (int, []byte, error) {
 	c.mux.Lock()
 	c.requestID++
-	l := c.log.WithFields(logrus.Fields{
+	info := RequestInfo{
+		ID:     c.requestID,
+		Method: method,
+		Path:   path,
+	}
+	c.mux.Unlock()
+
+	l := c.log.WithFields(logger.Fields{
 		"func":  "Send()",
 		"req":   fmt.Sprintf("%s %s", method, path),
-		"reqID": c.requestID,
+		"reqID": info.ID,
 	})
-	c.mux.Unlock()
 
+	hooks := c.hooks
+	if hooks == nil {
+		hooks = NoopHooks{}
+	}
+	if c.pathTemplate != nil {
+		info.PathTemplate = c.pathTemplate(path)
+	} else {
+		info.PathTemplate = DefaultPathTemplate(path)
+	}
+
+	span := hooks.StartSpan(info)
+	startTime := time.Now()
+	statusCode, bodyBytes, err := c.send(l, method, path, data)
+	hooks.ObserveRequest(info, statusCode, time.Since(startTime), err)
+	span.End(statusCode, err)
+
+	return statusCode, bodyBytes, err
+}
+
+func (c *Client) send(l logger.Logger, method, path string, data interface{}) (int, []byte, error) {
 	uri := fmt.Sprintf("%s/%s", c.address, path)
 
-	l.Debug("send request")
+	l.Debugf("send request")
 	// send request data as json
 	var jsonDataReader io.Reader
 	if data != nil {
@@ -123,15 +152,21 @@ func (c *Client) SetAuthToken(token string) {
 // ClientArgs - params to create Client instance
 type ClientArgs struct {
 	Address string
-	Log     *logrus.Entry
+	// logger, messages are discarded if not set
+	Log logger.Logger
 
 	// InsecureSkipVerify controls whether a client verifies the server's certificate chain and host name.
 	InsecureSkipVerify bool
+
+	// Hooks to collect request metrics and traces, optional
+	Hooks Hooks
+	// PathTemplate converts request path to a low cardinality template for hooks, DefaultPathTemplate if not set
+	PathTemplate func(path string) string
 }
 
 // NewClient creates new REST client
 func NewClient(args ClientArgs) ClientInterface {
-	l := args.Log.WithField("cmp", "RestClient")
+	l := logger.OrNoop(args.Log).WithField("cmp", "RestClient")
 
 	tr := &http.Transport{
 		IdleConnTimeout: 60 * time.Second,
@@ -147,9 +182,11 @@ func NewClient(args ClientArgs) ClientInterface {
 
 	l.Debugf("created for '%s'", args.Address)
 	return &Client{
-		address:    args.Address,
-		httpClient: httpClient,
-		log:        l,
-		requestID:  0,
+		address:      args.Address,
+		httpClient:   httpClient,
+		log:          l,
+		hooks:        args.Hooks,
+		pathTemplate: args.PathTemplate,
+		requestID:    0,
 	}
 }
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RequestInfo - request description passed to hooks
+type RequestInfo struct {
+	// sequence number of the request in the client
+	ID     int64
+	Method string
+	// request path with query params
+	Path string
+	// path without query params and with IDs replaced by "{id}", e.g. "storage/filesystems/{id}",
+	// use it as a metric label or span name to keep cardinality low
+	PathTemplate string
+}
+
+// Span - tracing span of a single request
+type Span interface {
+	// End is called when response is received or request has failed, statusCode is 0 if there is no response
+	End(statusCode int, err error)
+}
+
+// Hooks - request instrumentation, methods may be called concurrently.
+// StartSpan/Span.End map to OpenTelemetry tracer.Start()/span.End(),
+// embed NoopHooks to implement only some of the methods.
+type Hooks interface {
+	// StartSpan is called before request is sent
+	StartSpan(info RequestInfo) Span
+	// ObserveRequest is called after each request with its duration, statusCode is 0 if there is no response
+	ObserveRequest(info RequestInfo, statusCode int, duration time.Duration, err error)
+}
+
+// NoopHooks - hooks doing nothing
+type NoopHooks struct{}
+
+type noopSpan struct{}
+
+func (noopSpan) End(statusCode int, err error) {}
+
+// StartSpan returns span doing nothing
+func (NoopHooks) StartSpan(info RequestInfo) Span {
+	return noopSpan{}
+}
+
+// ObserveRequest does nothing
+func (NoopHooks) ObserveRequest(info RequestInfo, statusCode int, duration time.Duration, err error) {
+}
+
+// DefaultPathTemplate removes query params from the path and replaces segments
+// that are URL-escaped or contain digits with "{id}"
+func DefaultPathTemplate(path string) string {
+	path = strings.SplitN(path, "?", 2)[0]
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped != segment || strings.IndexFunc(segment, unicode.IsDigit) != -1 {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"fmt"
+
+	"go-nexentastor/pkg/ns"
+)
+
+// ChangeError - failed change and its error
+type ChangeError struct {
+	Change Change
+	Err    error
+}
+
+func (e ChangeError) Error() string {
+	return fmt.Sprintf("failed to %s: %s", e.Change, e.Err)
+}
+
+// ApplyParams - apply options
+type ApplyParams struct {
+	// If set to `true`, then the plan is computed, but nothing gets changed
+	DryRun bool
+
+	// See PlanParams.Prune
+	Prune bool
+}
+
+// ApplyResult - result of Apply() call
+type ApplyResult struct {
+	Plan Plan
+	// applied changes
+	Done []Change
+	// changes not applied because of the failure
+	Skipped []Change
+	// failed change, applying stops on the first failure
+	Failed *ChangeError
+}
+
+// Apply converges actual appliance state to the desired one
+func Apply(p ns.ProviderInterface, state State, params ApplyParams) (ApplyResult, error) {
+	plan, err := NewPlan(p, state, PlanParams{Prune: params.Prune})
+	if err != nil {
+		return ApplyResult{}, fmt.Errorf("Failed to plan changes: %s", err)
+	}
+
+	if params.DryRun {
+		return ApplyResult{Plan: plan}, nil
+	}
+
+	return plan.Apply(p)
+}
+
+// Apply applies planned changes in order, changes after the failed one are skipped
+func (plan Plan) Apply(p ns.ProviderInterface) (ApplyResult, error) {
+	result := ApplyResult{Plan: plan}
+
+	for i, change := range plan.Changes {
+		if err := change.apply(p); err != nil {
+			result.Failed = &ChangeError{Change: change, Err: err}
+			result.Skipped = append(result.Skipped, plan.Changes[i+1:]...)
+			return result, result.Failed
+		}
+		result.Done = append(result.Done, change)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,819 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-nexentastor/pkg/ns"
+)
+
+// ChangeAction - type of planned change
+type ChangeAction string
+
+const (
+	// ChangeActionCreate - resource will be created
+	ChangeActionCreate ChangeAction = "create"
+
+	// ChangeActionUpdate - existing resource will be updated
+	ChangeActionUpdate ChangeAction = "update"
+
+	// ChangeActionDelete - existing resource will be deleted, planned in prune mode only
+	ChangeActionDelete ChangeAction = "delete"
+)
+
+// ResourceKind - kind of managed resource
+type ResourceKind string
+
+const (
+	ResourceKindFilesystem  ResourceKind = "filesystem"
+	ResourceKindNfsShare    ResourceKind = "NFS share"
+	ResourceKindSmbShare    ResourceKind = "SMB share"
+	ResourceKindVolume      ResourceKind = "volume"
+	ResourceKindVolumeGroup ResourceKind = "volumeGroup"
+	ResourceKindHostGroup   ResourceKind = "hostGroup"
+	ResourceKindTargetGroup ResourceKind = "targetGroup"
+	ResourceKindLunMapping  ResourceKind = "LUN mapping"
+)
+
+// Change - single planned change of a resource
+type Change struct {
+	Action ChangeAction
+	Kind   ResourceKind
+	// resource path or name, LUN mapping is named as "<volume>:<hostGroup>:<targetGroup>"
+	Name string
+	// changed fields, Actual values are not set for created resources
+	Diff []ns.EnsureFieldDiff
+
+	apply func(p ns.ProviderInterface) error
+}
+
+func (change Change) String() string {
+	s := fmt.Sprintf("%s %s '%s'", change.Action, change.Kind, change.Name)
+	if len(change.Diff) > 0 {
+		diff := make([]string, 0, len(change.Diff))
+		for _, d := range change.Diff {
+			diff = append(diff, d.String())
+		}
+		s = fmt.Sprintf("%s (%s)", s, strings.Join(diff, ", "))
+	}
+	return s
+}
+
+// Plan - list of changes in the order they must be applied
+type Plan struct {
+	Changes []Change
+}
+
+// IsEmpty returns true if actual state matches the desired one
+func (plan Plan) IsEmpty() bool {
+	return len(plan.Changes) == 0
+}
+
+func (plan Plan) String() string {
+	if plan.IsEmpty() {
+		return "no changes"
+	}
+	lines := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanParams - plan computation options
+type PlanParams struct {
+	// If set to `true`, then resources missing in the desired state are removed:
+	// datasets and volumeGroups under State.Roots, shares of listed filesystems, LUN mappings of listed volumes,
+	// members of listed groups and groups left unused after LUN mappings and datasets removal.
+	Prune bool
+}
+
+// phases of the plan, changes are applied phase by phase
+const (
+	phaseFilesystems = iota
+	phaseShares
+	phaseVolumes
+	phaseGroups
+	phaseLunMappings
+	phaseDeleteLunMappings
+	phaseDeleteShares
+	phaseDeleteDatasets
+	phaseDeleteGroups
+	phaseCount
+)
+
+type planner struct {
+	p      ns.ProviderInterface
+	state  State
+	params PlanParams
+	phases [phaseCount][]Change
+
+	// LUN mappings to be deleted by id, including mappings of destroyed volumes
+	deletedLunMappings map[string]ns.LunMapping
+}
+
+func (planner *planner) add(phase int, change Change) {
+	planner.phases[phase] = append(planner.phases[phase], change)
+}
+
+// NewPlan compares desired state with the actual one and returns changes required to converge it
+func NewPlan(p ns.ProviderInterface, state State, params PlanParams) (Plan, error) {
+	if err := state.Validate(); err != nil {
+		return Plan{}, err
+	}
+
+	planner := &planner{
+		p:                  p,
+		state:              state,
+		params:             params,
+		deletedLunMappings: map[string]ns.LunMapping{},
+	}
+
+	steps := []func() error{
+		planner.planFilesystems,
+		planner.planVolumes,
+		planner.planHostGroups,
+		planner.planTargetGroups,
+		planner.planLunMappings,
+	}
+	if params.Prune {
+		steps = append(steps, planner.planPruneDatasets, planner.planPruneGroups)
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return Plan{}, err
+		}
+	}
+
+	plan := Plan{}
+	for _, changes := range planner.phases {
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	return plan, nil
+}
+
+func (planner *planner) planFilesystems() error {
+	filesystems := append([]Filesystem{}, planner.state.Filesystems...)
+	sort.Slice(filesystems, func(i, j int) bool { return filesystems[i].Path < filesystems[j].Path })
+
+	for _, desired := range filesystems {
+		params := ns.EnsureFilesystemParams{Path: desired.Path, ReferencedQuotaSize: desired.ReferencedQuotaSize}
+		ensure := func(p ns.ProviderInterface) error {
+			_, _, err := p.EnsureFilesystem(params)
+			return err
+		}
+
+		actual, err := planner.p.GetFilesystem(desired.Path)
+		exists := err == nil
+		if err != nil && !ns.IsNotExistNefError(err) {
+			return fmt.Errorf("Failed to get filesystem '%s': %s", desired.Path, err)
+		}
+
+		if !exists {
+			planner.add(phaseFilesystems, Change{
+				Action: ChangeActionCreate,
+				Kind:   ResourceKindFilesystem,
+				Name:   desired.Path,
+				Diff:   ns.CreatedDiff(ns.DiffFilesystem(ns.Filesystem{}, params)),
+				apply:  ensure,
+			})
+		} else if diff := ns.DiffFilesystem(actual, params); len(diff) > 0 {
+			planner.add(phaseFilesystems, Change{
+				Action: ChangeActionUpdate,
+				Kind:   ResourceKindFilesystem,
+				Name:   desired.Path,
+				Diff:   diff,
+				apply:  ensure,
+			})
+		}
+
+		if err := planner.planNfsShare(desired, actual, exists); err != nil {
+			return err
+		}
+		if err := planner.planSmbShare(desired, actual, exists); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (planner *planner) planNfsShare(desired Filesystem, actual ns.Filesystem, exists bool) error {
+	path := desired.Path
+
+	if desired.Nfs == nil {
+		if planner.params.Prune && exists && actual.SharedOverNfs {
+			planner.add(phaseDeleteShares, Change{
+				Action: ChangeActionDelete,
+				Kind:   ResourceKindNfsShare,
+				Name:   path,
+				apply:  func(p ns.ProviderInterface) error { return p.DeleteNfsShare(path) },
+			})
+		}
+		return nil
+	}
+
+	params := ns.CreateNfsShareParams{
+		Filesystem:    path,
+		ReadWriteList: desired.Nfs.ReadWriteList,
+		ReadOnlyList:  desired.Nfs.ReadOnlyList,
+	}
+	ensure := func(p ns.ProviderInterface) error {
+		_, err := p.EnsureNfsShare(params)
+		return err
+	}
+
+	if !exists || !actual.SharedOverNfs {
+		planner.add(phaseShares, Change{
+			Action: ChangeActionCreate,
+			Kind:   ResourceKindNfsShare,
+			Name:   path,
+			Diff:   ns.CreatedDiff(ns.DiffNfsShare(ns.NfsShare{}, params)),
+			apply:  ensure,
+		})
+		return nil
+	}
+
+	share, err := planner.p.GetNfsShare(path)
+	if err != nil {
+		return fmt.Errorf("Failed to get NFS share of filesystem '%s': %s", path, err)
+	}
+	if diff := ns.DiffNfsShare(share, params); len(diff) > 0 {
+		planner.add(phaseShares, Change{
+			Action: ChangeActionUpdate,
+			Kind:   ResourceKindNfsShare,
+			Name:   path,
+			Diff:   diff,
+			apply:  ensure,
+		})
+	}
+
+	return nil
+}
+
+func (planner *planner) planSmbShare(desired Filesystem, actual ns.Filesystem, exists bool) error {
+	path := desired.Path
+
+	if desired.Smb == nil {
+		if planner.params.Prune && exists && actual.SharedOverSmb {
+			planner.add(phaseDeleteShares, Change{
+				Action: ChangeActionDelete,
+				Kind:   ResourceKindSmbShare,
+				Name:   path,
+				apply:  func(p ns.ProviderInterface) error { return p.DeleteSmbShare(path) },
+			})
+		}
+		return nil
+	}
+
+	params := ns.CreateSmbShareParams{Filesystem: path, ShareName: desired.Smb.ShareName}
+	ensure := func(p ns.ProviderInterface) error {
+		_, err := p.EnsureSmbShare(params)
+		return err
+	}
+
+	if !exists || !actual.SharedOverSmb {
+		change := Change{Action: ChangeActionCreate, Kind: ResourceKindSmbShare, Name: path, apply: ensure}
+		if params.ShareName != "" {
+			change.Diff = []ns.EnsureFieldDiff{{Field: "shareName", Desired: params.ShareName}}
+		}
+		planner.add(phaseShares, change)
+		return nil
+	}
+
+	if params.ShareName == "" {
+		return nil
+	}
+	shareName, err := planner.p.GetSmbShareName(path)
+	if err != nil {
+		return fmt.Errorf("Failed to get SMB share of filesystem '%s': %s", path, err)
+	}
+	if shareName != params.ShareName {
+		planner.add(phaseShares, Change{
+			Action: ChangeActionUpdate,
+			Kind:   ResourceKindSmbShare,
+			Name:   path,
+			Diff:   []ns.EnsureFieldDiff{{Field: "shareName", Actual: shareName, Desired: params.ShareName}},
+			apply:  ensure,
+		})
+	}
+
+	return nil
+}
+
+func (planner *planner) planVolumes() error {
+	volumes := append([]Volume{}, planner.state.Volumes...)
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Path < volumes[j].Path })
+
+	for _, desired := range volumes {
+		params := desired.ensureParams()
+		ensure := func(p ns.ProviderInterface) error {
+			_, _, err := p.EnsureVolume(params)
+			return err
+		}
+
+		actual, err := planner.p.GetVolume(desired.Path)
+		if ns.IsNotExistNefError(err) {
+			planner.add(phaseVolumes, Change{
+				Action: ChangeActionCreate,
+				Kind:   ResourceKindVolume,
+				Name:   desired.Path,
+				Diff:   ns.CreatedDiff(ns.DiffVolume(ns.Volume{}, params)),
+				apply:  ensure,
+			})
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Failed to get volume '%s': %s", desired.Path, err)
+		}
+
+		diff := ns.DiffVolume(actual, params)
+		for _, d := range diff {
+			if d.Field == "volumeBlockSize" {
+				return fmt.Errorf("Volume '%s' block size cannot be changed: %s", desired.Path, d)
+			} else if d.Field == "volumeSize" && d.Desired.(int64) < actual.VolumeSize {
+				return fmt.Errorf("Volume '%s' cannot be shrunk: %s", desired.Path, d)
+			}
+		}
+		if len(diff) > 0 {
+			planner.add(phaseVolumes, Change{
+				Action: ChangeActionUpdate,
+				Kind:   ResourceKindVolume,
+				Name:   desired.Path,
+				Diff:   diff,
+				apply:  ensure,
+			})
+		}
+	}
+
+	return nil
+}
+
+// groupOperations - hostGroup and targetGroup specific calls
+type groupOperations struct {
+	kind   ResourceKind
+	get    func(name string) ([]string, error)
+	add    func(p ns.ProviderInterface, name string, members []string) error
+	remove func(p ns.ProviderInterface, name string, members []string) error
+}
+
+func (planner *planner) planHostGroups() error {
+	return planner.planGroups(planner.state.HostGroups, groupOperations{
+		kind: ResourceKindHostGroup,
+		get: func(name string) ([]string, error) {
+			hostGroup, err := planner.p.GetHostGroup(name)
+			return hostGroup.Members, err
+		},
+		add: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.AddHostGroupMembers(name, members)
+		},
+		remove: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.RemoveHostGroupMembers(name, members)
+		},
+	})
+}
+
+func (planner *planner) planTargetGroups() error {
+	return planner.planGroups(planner.state.TargetGroups, groupOperations{
+		kind: ResourceKindTargetGroup,
+		get: func(name string) ([]string, error) {
+			targetGroup, err := planner.p.GetTargetGroup(name)
+			return targetGroup.Members, err
+		},
+		add: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.AddTargetGroupMembers(name, members)
+		},
+		remove: func(p ns.ProviderInterface, name string, members []string) error {
+			return p.RemoveTargetGroupMembers(name, members)
+		},
+	})
+}
+
+// planGroups plans group members update, extra members are removed in prune mode only
+func (planner *planner) planGroups(groups []Group, ops groupOperations) error {
+	for _, desired := range groups {
+		name := desired.Name
+		members := sortedUnique(desired.Members)
+
+		actual, err := ops.get(name)
+		if ns.IsNotExistNefError(err) {
+			planner.add(phaseGroups, Change{
+				Action: ChangeActionCreate,
+				Kind:   ops.kind,
+				Name:   name,
+				Diff:   []ns.EnsureFieldDiff{{Field: "members", Desired: members}},
+				apply:  func(p ns.ProviderInterface) error { return ops.add(p, name, members) },
+			})
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Failed to get %s '%s': %s", ops.kind, name, err)
+		}
+
+		actual = sortedUnique(actual)
+		missing := subtract(members, actual)
+		extra := []string{}
+		if planner.params.Prune {
+			extra = subtract(actual, members)
+		}
+		if len(missing) == 0 && len(extra) == 0 {
+			continue
+		}
+
+		planner.add(phaseGroups, Change{
+			Action: ChangeActionUpdate,
+			Kind:   ops.kind,
+			Name:   name,
+			Diff: []ns.EnsureFieldDiff{{
+				Field:   "members",
+				Actual:  actual,
+				Desired: sortedUnique(append(subtract(actual, extra), missing...)),
+			}},
+			apply: func(p ns.ProviderInterface) error {
+				if len(missing) > 0 {
+					if err := ops.add(p, name, missing); err != nil {
+						return err
+					}
+				}
+				if len(extra) > 0 {
+					return ops.remove(p, name, extra)
+				}
+				return nil
+			},
+		})
+	}
+
+	return nil
+}
+
+// planLunMappings plans LUN mappings of volumes listed in the state,
+// mapping with different LUN number is recreated as LUN number cannot be changed
+func (planner *planner) planLunMappings() error {
+	desiredByVolume := map[string][]LunMapping{}
+	for _, lunMapping := range planner.state.LunMappings {
+		desiredByVolume[lunMapping.Volume] = append(desiredByVolume[lunMapping.Volume], lunMapping)
+	}
+	managedVolumes := map[string]bool{}
+	for volume := range desiredByVolume {
+		managedVolumes[volume] = true
+	}
+	if planner.params.Prune {
+		for _, volume := range planner.state.Volumes {
+			managedVolumes[volume.Path] = true
+		}
+	}
+
+	for _, volume := range sortedKeys(managedVolumes) {
+		existing, err := planner.p.GetLunMappings(ns.GetLunMappingsParams{Volume: volume})
+		if err != nil {
+			return fmt.Errorf("Failed to get LUN mappings of volume '%s': %s", volume, err)
+		}
+
+		matched := map[string]bool{}
+		for _, desired := range desiredByVolume[volume] {
+			desired := desired
+			params := ns.CreateLunMappingParams{
+				Volume:      desired.Volume,
+				HostGroup:   desired.HostGroup,
+				TargetGroup: desired.TargetGroup,
+				Lun:         desired.Lun,
+			}
+			create := func(p ns.ProviderInterface) error {
+				_, err := p.CreateLunMapping(params)
+				return err
+			}
+
+			var actual *ns.LunMapping
+			for i, lunMapping := range existing {
+				if lunMapping.HostGroup == desired.HostGroup && lunMapping.TargetGroup == desired.TargetGroup {
+					actual = &existing[i]
+					matched[lunMapping.Id] = true
+					break
+				}
+			}
+
+			if actual == nil {
+				change := Change{Action: ChangeActionCreate, Kind: ResourceKindLunMapping, Name: desired.String(), apply: create}
+				if desired.Lun != nil {
+					change.Diff = []ns.EnsureFieldDiff{{Field: "lun", Desired: *desired.Lun}}
+				}
+				planner.add(phaseLunMappings, change)
+			} else if desired.Lun != nil && *desired.Lun != actual.Lun {
+				id := actual.Id
+				planner.add(phaseLunMappings, Change{
+					Action: ChangeActionUpdate,
+					Kind:   ResourceKindLunMapping,
+					Name:   desired.String(),
+					Diff:   []ns.EnsureFieldDiff{{Field: "lun", Actual: actual.Lun, Desired: *desired.Lun}},
+					apply: func(p ns.ProviderInterface) error {
+						if err := p.DestroyLunMapping(id); err != nil && !ns.IsNotExistNefError(err) {
+							return err
+						}
+						return create(p)
+					},
+				})
+			}
+		}
+
+		if !planner.params.Prune {
+			continue
+		}
+		for _, lunMapping := range existing {
+			if matched[lunMapping.Id] {
+				continue
+			}
+			id := lunMapping.Id
+			planner.deletedLunMappings[id] = lunMapping
+			planner.add(phaseDeleteLunMappings, Change{
+				Action: ChangeActionDelete,
+				Kind:   ResourceKindLunMapping,
+				Name: LunMapping{
+					Volume:      lunMapping.Volume,
+					HostGroup:   lunMapping.HostGroup,
+					TargetGroup: lunMapping.TargetGroup,
+				}.String(),
+				apply: func(p ns.ProviderInterface) error { return p.DestroyLunMapping(id) },
+			})
+		}
+	}
+
+	return nil
+}
+
+// planPruneDatasets plans destruction of filesystems, volumeGroups and volumes under State.Roots
+// missing in the state, ancestors of listed datasets are kept
+func (planner *planner) planPruneDatasets() error {
+	keep := map[string]bool{}
+	volumes := map[string]bool{}
+	for _, filesystem := range planner.state.Filesystems {
+		for _, path := range withAncestors(filesystem.Path) {
+			keep[path] = true
+		}
+	}
+	for _, volume := range planner.state.Volumes {
+		volumes[volume.Path] = true
+		for _, path := range withAncestors(volume.Path) {
+			keep[path] = true
+		}
+	}
+
+	for _, root := range planner.state.Roots {
+		_, err := planner.p.GetFilesystem(root)
+		if ns.IsNotExistNefError(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Failed to get root filesystem '%s': %s", root, err)
+		}
+
+		queue := []string{root}
+		for len(queue) > 0 {
+			parent := queue[0]
+			queue = queue[1:]
+
+			children, err := planner.p.GetFilesystems(parent)
+			if err != nil {
+				return fmt.Errorf("Failed to get children of filesystem '%s': %s", parent, err)
+			}
+			for _, child := range children {
+				if child.Path == parent {
+					continue
+				} else if keep[child.Path] {
+					queue = append(queue, child.Path)
+				} else if err := planner.addDestroyFilesystem(child.Path); err != nil {
+					return err
+				}
+			}
+
+			volumeGroups, err := planner.p.GetVolumeGroups(parent)
+			if err != nil {
+				return fmt.Errorf("Failed to get volumeGroups of filesystem '%s': %s", parent, err)
+			}
+			for _, volumeGroup := range volumeGroups {
+				existing, err := planner.p.GetVolumes(volumeGroup.Path)
+				if err != nil {
+					return fmt.Errorf("Failed to get volumes of volumeGroup '%s': %s", volumeGroup.Path, err)
+				}
+				for _, volume := range existing {
+					if volumes[volume.Path] {
+						continue
+					} else if err := planner.addDestroyVolume(volume.Path); err != nil {
+						return err
+					}
+				}
+				if !keep[volumeGroup.Path] {
+					planner.addDestroyVolumeGroup(volumeGroup.Path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// addDestroyFilesystem plans destruction of the filesystem tree, LUN mappings of its volumes are removed as well
+func (planner *planner) addDestroyFilesystem(path string) error {
+	if err := planner.addTreeLunMappings(path); err != nil {
+		return err
+	}
+	planner.add(phaseDeleteDatasets, Change{
+		Action: ChangeActionDelete,
+		Kind:   ResourceKindFilesystem,
+		Name:   path,
+		apply: func(p ns.ProviderInterface) error {
+			_, err := p.DestroyTree(path, ns.DestroyTreeParams{})
+			return err
+		},
+	})
+	return nil
+}
+
+func (planner *planner) addDestroyVolume(path string) error {
+	if err := planner.addVolumeLunMappings(path); err != nil {
+		return err
+	}
+	planner.add(phaseDeleteDatasets, Change{
+		Action: ChangeActionDelete,
+		Kind:   ResourceKindVolume,
+		Name:   path,
+		apply: func(p ns.ProviderInterface) error {
+			_, err := p.DestroyVolume(path, ns.DestroyVolumeParams{
+				DestroySnapshots:   true,
+				DestroyLunMappings: true,
+				DestroyLogicalUnit: true,
+			})
+			return err
+		},
+	})
+	return nil
+}
+
+// addDestroyVolumeGroup plans deletion of the volumeGroup, its volumes must be planned for destruction first
+func (planner *planner) addDestroyVolumeGroup(path string) {
+	planner.add(phaseDeleteDatasets, Change{
+		Action: ChangeActionDelete,
+		Kind:   ResourceKindVolumeGroup,
+		Name:   path,
+		apply: func(p ns.ProviderInterface) error {
+			return p.DestroyVolumeGroup(path, ns.DestroyVolumeGroupParams{DestroySnapshots: true})
+		},
+	})
+}
+
+// addVolumeLunMappings marks LUN mappings of the destroyed volume as deleted,
+// so groups left unused by them get pruned
+func (planner *planner) addVolumeLunMappings(volume string) error {
+	lunMappings, err := planner.p.GetLunMappings(ns.GetLunMappingsParams{Volume: volume})
+	if err != nil {
+		return fmt.Errorf("Failed to get LUN mappings of volume '%s': %s", volume, err)
+	}
+	for _, lunMapping := range lunMappings {
+		planner.deletedLunMappings[lunMapping.Id] = lunMapping
+	}
+	return nil
+}
+
+// addTreeLunMappings marks LUN mappings of all volumes under the destroyed filesystem as deleted
+func (planner *planner) addTreeLunMappings(path string) error {
+	volumeGroups, err := planner.p.GetVolumeGroups(path)
+	if err != nil {
+		return fmt.Errorf("Failed to get volumeGroups of filesystem '%s': %s", path, err)
+	}
+	for _, volumeGroup := range volumeGroups {
+		volumes, err := planner.p.GetVolumes(volumeGroup.Path)
+		if err != nil {
+			return fmt.Errorf("Failed to get volumes of volumeGroup '%s': %s", volumeGroup.Path, err)
+		}
+		for _, volume := range volumes {
+			if err := planner.addVolumeLunMappings(volume.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	children, err := planner.p.GetFilesystems(path)
+	if err != nil {
+		return fmt.Errorf("Failed to get children of filesystem '%s': %s", path, err)
+	}
+	for _, child := range children {
+		if child.Path == path {
+			continue
+		} else if err := planner.addTreeLunMappings(child.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planPruneGroups plans deletion of groups not listed in the state,
+// which are left without LUN mappings after pruned LUN mappings and datasets removal
+func (planner *planner) planPruneGroups() error {
+	hostGroups := map[string]bool{}
+	targetGroups := map[string]bool{}
+	for _, lunMapping := range planner.deletedLunMappings {
+		hostGroups[lunMapping.HostGroup] = true
+		targetGroups[lunMapping.TargetGroup] = true
+	}
+	for _, group := range planner.state.HostGroups {
+		delete(hostGroups, group.Name)
+	}
+	for _, group := range planner.state.TargetGroups {
+		delete(targetGroups, group.Name)
+	}
+	for _, lunMapping := range planner.state.LunMappings {
+		delete(hostGroups, lunMapping.HostGroup)
+		delete(targetGroups, lunMapping.TargetGroup)
+	}
+
+	for _, name := range sortedKeys(hostGroups) {
+		name := name
+		if name == "" || name == "All" {
+			continue
+		}
+		unused, err := planner.isGroupUnused(ns.GetLunMappingsParams{HostGroup: name})
+		if err != nil {
+			return err
+		} else if unused {
+			planner.add(phaseDeleteGroups, Change{
+				Action: ChangeActionDelete,
+				Kind:   ResourceKindHostGroup,
+				Name:   name,
+				apply:  func(p ns.ProviderInterface) error { return p.DeleteHostGroup(name) },
+			})
+		}
+	}
+	for _, name := range sortedKeys(targetGroups) {
+		name := name
+		if name == "" || name == "All" {
+			continue
+		}
+		unused, err := planner.isGroupUnused(ns.GetLunMappingsParams{TargetGroup: name})
+		if err != nil {
+			return err
+		} else if unused {
+			planner.add(phaseDeleteGroups, Change{
+				Action: ChangeActionDelete,
+				Kind:   ResourceKindTargetGroup,
+				Name:   name,
+				apply:  func(p ns.ProviderInterface) error { return p.DeleteTargetGroup(name) },
+			})
+		}
+	}
+
+	return nil
+}
+
+// isGroupUnused returns true if all LUN mappings of the group are going to be deleted
+func (planner *planner) isGroupUnused(params ns.GetLunMappingsParams) (bool, error) {
+	lunMappings, err := planner.p.GetLunMappings(params)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get LUN mappings %+v: %s", params, err)
+	}
+	for _, lunMapping := range lunMappings {
+		if _, ok := planner.deletedLunMappings[lunMapping.Id]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// withAncestors returns dataset path and paths of all its parents
+func withAncestors(path string) []string {
+	paths := []string{path}
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path, "/") {
+		path = path[:i]
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func sortedUnique(list []string) []string {
+	set := map[string]bool{}
+	for _, item := range list {
+		set[item] = true
+	}
+	return sortedKeys(set)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subtract returns items of a missing in b
+func subtract(a, b []string) []string {
+	set := map[string]bool{}
+	for _, item := range b {
+		set[item] = true
+	}
+	result := []string{}
+	for _, item := range a {
+		if !set[item] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+// Package state loads desired NexentaStor appliance state from YAML or JSON,
+// plans changes against a live provider and applies them in dependency order.
+//
+// Example of state file:
+//
+//	roots:
+//	  - pool/tenant
+//	filesystems:
+//	  - path: pool/tenant
+//	  - path: pool/tenant/data
+//	    referencedQuotaSize: 10737418240
+//	    nfs:
+//	      readWriteList:
+//	        - etype: network
+//	          entity: 10.0.0.0
+//	          mask: 24
+//	    smb:
+//	      shareName: data
+//	volumes:
+//	  - path: pool/tenant/vg/db
+//	    volumeSize: 10737418240
+//	hostGroups:
+//	  - name: db-hosts
+//	    members: [iqn.2005-07.com.example:db1]
+//	targetGroups:
+//	  - name: db-targets
+//	    members: [iqn.2005-07.com.nexenta:01:db]
+//	lunMappings:
+//	  - volume: pool/tenant/vg/db
+//	    hostGroup: db-hosts
+//	    targetGroup: db-targets
+//	    lun: 1
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"go-nexentastor/pkg/ns"
+)
+
+// State - desired NexentaStor appliance state
+type State struct {
+	// datasets owned by the state, prune mode destroys datasets under them which are not in the state
+	Roots        []string     `json:"roots,omitempty" yaml:"roots,omitempty"`
+	Filesystems  []Filesystem `json:"filesystems,omitempty" yaml:"filesystems,omitempty"`
+	Volumes      []Volume     `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	HostGroups   []Group      `json:"hostGroups,omitempty" yaml:"hostGroups,omitempty"`
+	TargetGroups []Group      `json:"targetGroups,omitempty" yaml:"targetGroups,omitempty"`
+	LunMappings  []LunMapping `json:"lunMappings,omitempty" yaml:"lunMappings,omitempty"`
+}
+
+// Filesystem - desired filesystem state, not set properties are not managed
+type Filesystem struct {
+	Path                string    `json:"path" yaml:"path"`
+	ReferencedQuotaSize *int64    `json:"referencedQuotaSize,omitempty" yaml:"referencedQuotaSize,omitempty"`
+	Nfs                 *NfsShare `json:"nfs,omitempty" yaml:"nfs,omitempty"`
+	Smb                 *SmbShare `json:"smb,omitempty" yaml:"smb,omitempty"`
+}
+
+// NfsShare - desired NFS share, default access lists are the same as for ns.CreateNfsShare()
+type NfsShare struct {
+	ReadWriteList []ns.NfsRuleList `json:"readWriteList,omitempty" yaml:"readWriteList,omitempty"`
+	ReadOnlyList  []ns.NfsRuleList `json:"readOnlyList,omitempty" yaml:"readOnlyList,omitempty"`
+}
+
+// SmbShare - desired SMB share, default share name is generated by NexentaStor if not set
+type SmbShare struct {
+	ShareName string `json:"shareName,omitempty" yaml:"shareName,omitempty"`
+}
+
+// Volume - desired volume state, parent volumeGroup must exist
+type Volume struct {
+	Path                      string `json:"path" yaml:"path"`
+	VolumeSize                int64  `json:"volumeSize" yaml:"volumeSize"`
+	SparseVolume              bool   `json:"sparseVolume,omitempty" yaml:"sparseVolume,omitempty"`
+	VolumeBlockSize           int64  `json:"volumeBlockSize,omitempty" yaml:"volumeBlockSize,omitempty"`
+	CompressionMode           string `json:"compressionMode,omitempty" yaml:"compressionMode,omitempty"`
+	DedupMode                 string `json:"dedupMode,omitempty" yaml:"dedupMode,omitempty"`
+	SyncMode                  string `json:"syncMode,omitempty" yaml:"syncMode,omitempty"`
+	WritebackCacheDisabled    *bool  `json:"writebackCacheDisabled,omitempty" yaml:"writebackCacheDisabled,omitempty"`
+	ReferencedReservationSize *int64 `json:"referencedReservationSize,omitempty" yaml:"referencedReservationSize,omitempty"`
+}
+
+func (volume Volume) ensureParams() ns.EnsureVolumeParams {
+	return ns.EnsureVolumeParams{
+		Path:                      volume.Path,
+		VolumeSize:                volume.VolumeSize,
+		SparseVolume:              volume.SparseVolume,
+		VolumeBlockSize:           volume.VolumeBlockSize,
+		CompressionMode:           volume.CompressionMode,
+		DedupMode:                 volume.DedupMode,
+		SyncMode:                  volume.SyncMode,
+		WritebackCacheDisabled:    volume.WritebackCacheDisabled,
+		ReferencedReservationSize: volume.ReferencedReservationSize,
+	}
+}
+
+// Group - desired hostGroup or targetGroup
+type Group struct {
+	Name    string   `json:"name" yaml:"name"`
+	Members []string `json:"members" yaml:"members"`
+}
+
+// LunMapping - desired LUN mapping of a volume, LUN number is assigned by NexentaStor if not set
+type LunMapping struct {
+	Volume      string `json:"volume" yaml:"volume"`
+	HostGroup   string `json:"hostGroup" yaml:"hostGroup"`
+	TargetGroup string `json:"targetGroup" yaml:"targetGroup"`
+	Lun         *int   `json:"lun,omitempty" yaml:"lun,omitempty"`
+}
+
+func (lunMapping LunMapping) String() string {
+	return fmt.Sprintf("%s:%s:%s", lunMapping.Volume, lunMapping.HostGroup, lunMapping.TargetGroup)
+}
+
+// Load reads state from YAML or JSON file, format is detected by ".json" extension
+func Load(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, fmt.Errorf("Cannot read state file '%s': %s", path, err)
+	}
+
+	state, err := Parse(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return state, fmt.Errorf("Cannot load state file '%s': %s", path, err)
+	}
+
+	return state, nil
+}
+
+// Parse parses and validates state from JSON or YAML data
+func Parse(data []byte, isJSON bool) (State, error) {
+	state := State{}
+
+	var err error
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&state)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&state)
+	}
+	if err != nil {
+		return state, err
+	}
+
+	return state, state.Validate()
+}
+
+// Validate checks required fields and duplicates
+func (state State) Validate() error {
+	filesystems := map[string]bool{}
+	for i, filesystem := range state.Filesystems {
+		if filesystem.Path == "" {
+			return fmt.Errorf("filesystems[%d]: path is required", i)
+		} else if filesystems[filesystem.Path] {
+			return fmt.Errorf("filesystems[%d]: duplicate filesystem '%s'", i, filesystem.Path)
+		}
+		filesystems[filesystem.Path] = true
+	}
+
+	volumes := map[string]bool{}
+	for i, volume := range state.Volumes {
+		if volume.Path == "" {
+			return fmt.Errorf("volumes[%d]: path is required", i)
+		} else if volume.VolumeSize <= 0 {
+			return fmt.Errorf("volumes[%d]: volumeSize of '%s' must be positive", i, volume.Path)
+		} else if volumes[volume.Path] || filesystems[volume.Path] {
+			return fmt.Errorf("volumes[%d]: duplicate dataset '%s'", i, volume.Path)
+		}
+		volumes[volume.Path] = true
+	}
+
+	if err := validateGroups("hostGroups", state.HostGroups); err != nil {
+		return err
+	}
+	if err := validateGroups("targetGroups", state.TargetGroups); err != nil {
+		return err
+	}
+
+	lunMappings := map[string]bool{}
+	for i, lunMapping := range state.LunMappings {
+		if lunMapping.Volume == "" || lunMapping.HostGroup == "" || lunMapping.TargetGroup == "" {
+			return fmt.Errorf("lunMappings[%d]: volume, hostGroup and targetGroup are required", i)
+		} else if lunMappings[lunMapping.String()] {
+			return fmt.Errorf("lunMappings[%d]: duplicate LUN mapping of volume '%s'", i, lunMapping.Volume)
+		}
+		lunMappings[lunMapping.String()] = true
+	}
+
+	for i, root := range state.Roots {
+		if root == "" {
+			return fmt.Errorf("roots[%d]: empty path", i)
+		}
+	}
+
+	return nil
+}
+
+func validateGroups(kind string, groups []Group) error {
+	names := map[string]bool{}
+	for i, group := range groups {
+		if group.Name == "" {
+			return fmt.Errorf("%s[%d]: name is required", kind, i)
+		} else if len(group.Members) == 0 {
+			return fmt.Errorf("%s[%d]: members of '%s' cannot be empty", kind, i, group.Name)
+		} else if names[group.Name] {
+			return fmt.Errorf("%s[%d]: duplicate name '%s'", kind, i, group.Name)
+		}
+		names[group.Name] = true
+	}
+	return nil
+}
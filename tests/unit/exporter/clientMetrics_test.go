@@ -0,0 +1,51 @@
+package exporter_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"go-nexentastor/pkg/exporter"
+	"go-nexentastor/pkg/rest"
+)
+
+func TestClientMetrics(t *testing.T) {
+	m := exporter.NewClientMetrics()
+
+	info := rest.RequestInfo{ID: 1, Method: "GET", Path: "storage/pools/p1?fields=name", PathTemplate: "storage/pools/{id}"}
+	m.ObserveRequest(info, 200, 100*time.Millisecond, nil)
+	m.ObserveNefError("GET", "storage/pools/{id}", "ENOENT")
+	m.ObserveNefError("GET", "storage/pools/{id}", "ENOENT")
+	m.ObserveLogin(nil)
+	m.ObserveLogin(fmt.Errorf("Unauthorized"))
+	m.ObserveJobWait(2*time.Second, nil)
+
+	expected := `
+# HELP nexentastor_client_logins_total NexentaStor API login attempts by result
+# TYPE nexentastor_client_logins_total counter
+nexentastor_client_logins_total{result="error"} 1
+nexentastor_client_logins_total{result="success"} 1
+# HELP nexentastor_client_nef_errors_total NexentaStor API error responses by NEF error code
+# TYPE nexentastor_client_nef_errors_total counter
+nexentastor_client_nef_errors_total{code="ENOENT",method="GET",path="storage/pools/{id}"} 2
+`
+	err := testutil.CollectAndCompare(
+		m,
+		strings.NewReader(expected),
+		"nexentastor_client_logins_total",
+		"nexentastor_client_nef_errors_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if count := testutil.CollectAndCount(m, "nexentastor_client_request_duration_seconds"); count != 1 {
+		t.Errorf("expected 1 request duration series, but got %d", count)
+	}
+	if count := testutil.CollectAndCount(m, "nexentastor_client_job_wait_duration_seconds"); count != 1 {
+		t.Errorf("expected 1 job wait duration series, but got %d", count)
+	}
+}
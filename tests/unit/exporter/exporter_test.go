@@ -0,0 +1,255 @@
+package exporter_test
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/exporter"
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/ns"
+)
+
+// fakeProvider - node with static responses, delay is added to GetPools() to simulate slow appliance
+type fakeProvider struct {
+	ns.ProviderInterface
+
+	name        string
+	delay       time.Duration
+	pools       []ns.Pool
+	filesystems map[string][]ns.Filesystem
+	// volumeGroups and volumes by parent path
+	volumeGroups map[string][]ns.VolumeGroup
+	volumes      map[string][]ns.Volume
+	lunMappings  []ns.LunMapping
+	license      ns.License
+	licenseErr   error
+
+	// number of GetPools() calls
+	poolsCalls int32
+}
+
+func (f *fakeProvider) String() string {
+	return f.name
+}
+
+func (f *fakeProvider) GetPools() ([]ns.Pool, error) {
+	atomic.AddInt32(&f.poolsCalls, 1)
+	time.Sleep(f.delay)
+	return f.pools, nil
+}
+
+func (f *fakeProvider) GetFilesystems(parent string) ([]ns.Filesystem, error) {
+	return f.filesystems[parent], nil
+}
+
+func (f *fakeProvider) GetVolumeGroups(parent string) ([]ns.VolumeGroup, error) {
+	return f.volumeGroups[parent], nil
+}
+
+func (f *fakeProvider) GetVolumes(parent string) ([]ns.Volume, error) {
+	return f.volumes[parent], nil
+}
+
+func (f *fakeProvider) GetLicense() (ns.License, error) {
+	return f.license, f.licenseErr
+}
+
+func (f *fakeProvider) GetRSFClusters() ([]ns.RSFCluster, error) {
+	return []ns.RSFCluster{}, nil
+}
+
+func (f *fakeProvider) GetAllLunMappings() ([]ns.LunMapping, error) {
+	return f.lunMappings, nil
+}
+
+func scrape(t *testing.T, e *exporter.Exporter) string {
+	recorder := httptest.NewRecorder()
+	e.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func newLog() logger.Logger {
+	return logger.NewNoopLogger()
+}
+
+func TestExporter(t *testing.T) {
+	node := &fakeProvider{
+		name:  "node1",
+		pools: []ns.Pool{{Name: "pool1", Health: "ONLINE", Size: 1000, Allocated: 400, Free: 600}},
+		filesystems: map[string][]ns.Filesystem{
+			"pool1": {{Path: "pool1/fs1", BytesUsed: 100, BytesAvailable: 500, ReferencedQuotaSize: 1024}},
+		},
+		lunMappings: []ns.LunMapping{
+			{Volume: "pool1/vg/v1", HostGroup: "hg1", TargetGroup: "tg1"},
+			{Volume: "pool1/vg/v2", HostGroup: "hg1", TargetGroup: "tg1"},
+		},
+		license: ns.License{Valid: true, Expires: "2030-01-01"},
+	}
+
+	e, err := exporter.NewExporter(exporter.ExporterArgs{Provider: node, Log: newLog()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := scrape(t, e)
+
+	for _, expected := range []string{
+		`nexentastor_up{node="node1"} 1`,
+		`nexentastor_pool_free_bytes{node="node1",pool="pool1"} 600`,
+		`nexentastor_pool_health{health="ONLINE",node="node1",pool="pool1"} 1`,
+		`nexentastor_filesystem_quota_bytes{node="node1",path="pool1/fs1"} 1024`,
+		`nexentastor_license_valid{node="node1"} 1`,
+		fmt.Sprintf(`nexentastor_license_expiry_timestamp_seconds{node="node1"} %g`, float64(1893456000)),
+		`nexentastor_lun_mappings{host_group="hg1",node="node1",target_group="tg1"} 2`,
+		`nexentastor_collector_success{collector="rsf",node="node1"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain '%s', but got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestExporter_failedCollector(t *testing.T) {
+	node := &fakeProvider{name: "node1", licenseErr: fmt.Errorf("license request failed")}
+
+	e, err := exporter.NewExporter(exporter.ExporterArgs{Provider: node, Log: newLog()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := scrape(t, e)
+
+	for _, expected := range []string{
+		`nexentastor_up{node="node1"} 1`,
+		`nexentastor_collector_success{collector="license",node="node1"} 0`,
+		`nexentastor_collector_success{collector="pools",node="node1"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain '%s', but got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestExporter_timeout(t *testing.T) {
+	slow := &fakeProvider{name: "slow", delay: time.Second}
+	fast := &fakeProvider{name: "fast", pools: []ns.Pool{{Name: "pool1", Size: 1000}}}
+
+	e, err := exporter.NewExporter(exporter.ExporterArgs{
+		Resolver: &ns.Resolver{Nodes: []ns.ProviderInterface{slow, fast}},
+		Timeout:  100 * time.Millisecond,
+		Log:      newLog(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	startTime := time.Now()
+	body := scrape(t, e)
+	if duration := time.Since(startTime); duration > 500*time.Millisecond {
+		t.Errorf("expected scrape to be done within the timeout, but it took %s", duration)
+	}
+
+	for _, expected := range []string{
+		`nexentastor_up{node="slow"} 0`,
+		`nexentastor_up{node="fast"} 1`,
+		`nexentastor_pool_size_bytes{node="fast",pool="pool1"} 1000`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain '%s', but got:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, `collector_success{collector="pools",node="slow"}`) {
+		t.Errorf("expected no collector metrics of the slow node, but got:\n%s", body)
+	}
+}
+
+func TestExporter_slowNodeScrapedOnce(t *testing.T) {
+	slow := &fakeProvider{name: "slow", delay: 300 * time.Millisecond, pools: []ns.Pool{{Name: "pool1", Size: 1000}}}
+
+	e, err := exporter.NewExporter(exporter.ExporterArgs{
+		Provider: slow,
+		Timeout:  200 * time.Millisecond,
+		Log:      newLog(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body := scrape(t, e); !strings.Contains(body, `nexentastor_up{node="slow"} 0`) {
+		t.Fatalf("expected the first scrape to time out, but got:\n%s", body)
+	}
+
+	// the running scrape is reused and finishes within the second scrape timeout
+	body := scrape(t, e)
+	for _, expected := range []string{
+		`nexentastor_up{node="slow"} 1`,
+		`nexentastor_pool_size_bytes{node="slow",pool="pool1"} 1000`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain '%s', but got:\n%s", expected, body)
+		}
+	}
+	if calls := atomic.LoadInt32(&slow.poolsCalls); calls != 1 {
+		t.Errorf("expected a single scrape of the slow node, but got %d GetPools() calls", calls)
+	}
+
+	// next scrape starts a new one once the previous is done
+	scrape(t, e)
+	if calls := atomic.LoadInt32(&slow.poolsCalls); calls != 2 {
+		t.Errorf("expected a new scrape of the node, but got %d GetPools() calls", calls)
+	}
+}
+
+func TestExporter_nestedDatasets(t *testing.T) {
+	node := &fakeProvider{
+		name:  "node1",
+		pools: []ns.Pool{{Name: "pool1"}},
+		filesystems: map[string][]ns.Filesystem{
+			"pool1":     {{Path: "pool1/a", BytesUsed: 1}},
+			"pool1/a":   {{Path: "pool1/a/b", BytesUsed: 2}},
+			"pool1/a/b": {{Path: "pool1/a/b/c", BytesUsed: 3}},
+		},
+		volumeGroups: map[string][]ns.VolumeGroup{
+			"pool1":     {{Path: "pool1/vg"}},
+			"pool1/a/b": {{Path: "pool1/a/b/vg"}},
+		},
+		volumes: map[string][]ns.Volume{
+			"pool1/vg":     {{Path: "pool1/vg/v1", VolumeSize: 10}},
+			"pool1/a/b/vg": {{Path: "pool1/a/b/vg/v2", VolumeSize: 20}},
+		},
+	}
+
+	for _, parents := range [][]string{
+		nil,
+		{"pool1", "pool1/a"},
+		{"pool1/a/b", "pool1/a", "pool1"},
+	} {
+		t.Run(fmt.Sprintf("parents %v", parents), func(t *testing.T) {
+			e, err := exporter.NewExporter(exporter.ExporterArgs{Provider: node, Parents: parents, Log: newLog()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := scrape(t, e)
+
+			for _, expected := range []string{
+				`nexentastor_filesystem_used_bytes{node="node1",path="pool1/a"} 1`,
+				`nexentastor_filesystem_used_bytes{node="node1",path="pool1/a/b"} 2`,
+				`nexentastor_filesystem_used_bytes{node="node1",path="pool1/a/b/c"} 3`,
+				`nexentastor_volume_size_bytes{node="node1",path="pool1/vg/v1"} 10`,
+				`nexentastor_volume_size_bytes{node="node1",path="pool1/a/b/vg/v2"} 20`,
+				`nexentastor_collector_success{collector="datasets",node="node1"} 1`,
+			} {
+				if !strings.Contains(body, expected) {
+					t.Errorf("expected metrics to contain '%s', but got:\n%s", expected, body)
+				}
+			}
+		})
+	}
+}
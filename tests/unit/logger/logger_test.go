@@ -0,0 +1,92 @@
+package logger_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/ns"
+)
+
+func newSlog(buf *bytes.Buffer, level slog.Level) logger.Logger {
+	return logger.NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: level})))
+}
+
+func newLogrus(buf *bytes.Buffer, level logrus.Level) logger.Logger {
+	l := logrus.New()
+	l.SetOutput(buf)
+	l.SetLevel(level)
+	l.SetFormatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+	return logger.NewLogrusLogger(logrus.NewEntry(l))
+}
+
+func TestLogger_fields(t *testing.T) {
+	for name, newLogger := range map[string]func(buf *bytes.Buffer) logger.Logger{
+		"slog":   func(buf *bytes.Buffer) logger.Logger { return newSlog(buf, slog.LevelDebug) },
+		"logrus": func(buf *bytes.Buffer) logger.Logger { return newLogrus(buf, logrus.DebugLevel) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := newLogger(buf).
+				WithFields(logger.Fields{"cmp": "NSProvider", "ns": "https://10.3.199.252:8443"}).
+				WithField("func", "LogIn()")
+			l.Debugf("login token has been updated for '%s'", "admin")
+
+			// slog and logrus quote values differently
+			out := strings.ToLower(strings.ReplaceAll(buf.String(), `"`, ""))
+			for _, expected := range []string{
+				"level=debug",
+				"cmp=nsprovider",
+				"ns=https://10.3.199.252:8443",
+				"func=login()",
+				"login token has been updated for 'admin'",
+			} {
+				if !strings.Contains(out, strings.ToLower(expected)) {
+					t.Errorf("expected log output to contain '%s', but got: %s", expected, out)
+				}
+			}
+		})
+	}
+}
+
+func TestLogger_level(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := newSlog(buf, slog.LevelWarn)
+	l.Debugf("debug")
+	l.Infof("info")
+	l.Warnf("warn")
+	l.Errorf("error")
+
+	out := buf.String()
+	if strings.Contains(out, "msg=debug") || strings.Contains(out, "msg=info") {
+		t.Errorf("expected debug and info messages to be discarded, but got: %s", out)
+	}
+	if !strings.Contains(out, "msg=warn") || !strings.Contains(out, "msg=error") {
+		t.Errorf("expected warn and error messages to be logged, but got: %s", out)
+	}
+}
+
+func TestLogger_nil(t *testing.T) {
+	if _, ok := logger.NewSlogLogger(nil).(logger.NoopLogger); !ok {
+		t.Error("expected NoopLogger for nil slog logger")
+	}
+	if _, ok := logger.NewLogrusLogger(nil).(logger.NoopLogger); !ok {
+		t.Error("expected NoopLogger for nil logrus entry")
+	}
+
+	nsProvider, err := ns.NewProvider(ns.ProviderArgs{Address: "https://10.3.199.252:8443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nsProvider.(*ns.Provider).Log == nil {
+		t.Error("expected provider created without Log to have NoopLogger")
+	}
+
+	if _, err := ns.NewResolver(ns.ResolverArgs{Address: "https://10.3.199.252:8443"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package provider_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_GetAnalytics(t *testing.T) {
+	end := time.Unix(1700000600, 0)
+	start := end.Add(-10 * time.Minute)
+
+	client := &fakeNef{
+		analytics: map[string][]map[string]interface{}{
+			"pool1/fs1": {
+				{"timestamp": 1700000000, "read": 100, "write": 50},
+				{"timestamp": 1700000300, "read": 300, "write": 150},
+			},
+		},
+	}
+	nsp := newFakeProvider(client)
+
+	series, err := nsp.GetAnalytics(ns.AnalyticsQuery{
+		Metric:     ns.AnalyticsMetricIOPS,
+		EntityType: ns.AnalyticsEntityDataset,
+		Entity:     "pool1/fs1",
+		Start:      start,
+		End:        end,
+		Resolution: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := client.requests[len(client.requests)-1]
+	for _, param := range []string{"metric=iops", "entityType=dataset", "from=1700000000", "to=1700000600", "resolution=300"} {
+		if !strings.Contains(request, param) {
+			t.Errorf("expected request to contain '%s', but got: %s", param, request)
+		}
+	}
+
+	if series.Unit != "ops/s" || len(series.Points) != 2 || !series.Points[1].Time.Equal(time.Unix(1700000300, 0)) {
+		t.Fatalf("unexpected series: %+v", series)
+	}
+	if average := series.Average(); average.Read != 200 || average.Write != 100 {
+		t.Errorf("expected 200/100 average, but got: %+v", average)
+	}
+	if max := series.Max(); max.Total() != 450 {
+		t.Errorf("expected 450 max, but got: %+v", max)
+	}
+	if sum := series.Sum(); sum.Read != 120000 || sum.Write != 60000 {
+		t.Errorf("expected 120000/60000 operations, but got: %+v", sum)
+	}
+}
+
+func TestProvider_GetAnalytics_validation(t *testing.T) {
+	end := time.Now()
+	for name, query := range map[string]ns.AnalyticsQuery{
+		"unknown metric":   {Metric: "bandwidth", EntityType: ns.AnalyticsEntityPool, Entity: "pool1", Start: end.Add(-time.Hour)},
+		"unknown protocol": {Metric: ns.AnalyticsMetricIOPS, EntityType: ns.AnalyticsEntityProtocol, Entity: "ftp", Start: end.Add(-time.Hour)},
+		"empty entity":     {Metric: ns.AnalyticsMetricIOPS, EntityType: ns.AnalyticsEntityVolume, Start: end.Add(-time.Hour)},
+		"no start":         {Metric: ns.AnalyticsMetricIOPS, EntityType: ns.AnalyticsEntityPool, Entity: "pool1"},
+		"too many points": {
+			Metric:     ns.AnalyticsMetricIOPS,
+			EntityType: ns.AnalyticsEntityPool,
+			Entity:     "pool1",
+			Start:      end.Add(-30 * 24 * time.Hour),
+			Resolution: time.Second,
+		},
+	} {
+		t.Run("GetAnalytics() should reject "+name, func(t *testing.T) {
+			client := &fakeNef{}
+			nsp := newFakeProvider(client)
+			if _, err := nsp.GetAnalytics(query); !ns.IsBadArgNefError(err) {
+				t.Errorf("expected EBADARG error, but got: %v", err)
+			} else if len(client.requests) != 0 {
+				t.Errorf("expected no requests, but got: %v", client.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package provider_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+// Create* methods return the resource read back after creation
+func TestProvider_CreateReturnsResource(t *testing.T) {
+	newFakeCreateNef := func() *fakeNef {
+		return &fakeNef{
+			filesystems: map[string][]ns.Filesystem{
+				"p": {{Path: "p/fs", ReferencedQuotaSize: 1024}, {Path: "p/clone"}},
+			},
+			volumes: map[string][]ns.Volume{
+				"p/vg": {{Path: "p/vg/v"}, {Path: "p/vg/vclone", VolumeSize: 8192}},
+			},
+			snapshots: map[string][]ns.Snapshot{
+				"p/fs": {{Path: "p/fs@s", Name: "s", Parent: "p/fs"}},
+			},
+			hostGroups: map[string]*ns.HostGroup{},
+			responses: map[string][]fakeResponse{
+				"GET nas/nfs/p%2Ffs": {{http.StatusOK, map[string]interface{}{
+					"filesystem": "p/fs",
+					"securityContexts": []map[string]interface{}{{
+						"readWriteList": []ns.NfsRuleList{{Etype: "fqdn", Entity: "*"}},
+						"readOnlyList":  []ns.NfsRuleList{{Etype: "fqdn", Entity: "none"}},
+					}},
+				}}},
+				"GET nas/smb/p%2Ffs": {{http.StatusOK, map[string]string{"shareName": "p_fs"}}},
+			},
+		}
+	}
+
+	t.Run("CreateFilesystem() should return created filesystem", func(t *testing.T) {
+		client := newFakeCreateNef()
+		filesystem, err := newFakeProvider(client).CreateFilesystem(ns.CreateFilesystemParams{Path: "p/fs"})
+		if err != nil {
+			t.Fatal(err)
+		} else if filesystem != client.filesystems["p"][0] {
+			t.Errorf("expected %+v, but got %+v", client.filesystems["p"][0], filesystem)
+		}
+	})
+
+	t.Run("CreateFilesystem() should not read filesystem if creation fails", func(t *testing.T) {
+		client := newFakeCreateNef()
+		client.responses["POST storage/filesystems"] = []fakeResponse{nefErrorResponse(http.StatusConflict, "EEXIST")}
+		_, err := newFakeProvider(client).CreateFilesystem(ns.CreateFilesystemParams{Path: "p/fs"})
+		if !ns.IsAlreadyExistNefError(err) {
+			t.Errorf("expected EEXIST error, but got: %v", err)
+		} else if len(client.requests) != 1 {
+			t.Errorf("expected create request only, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("CreateFilesystem() should return read error", func(t *testing.T) {
+		client := newFakeCreateNef()
+		_, err := newFakeProvider(client).CreateFilesystem(ns.CreateFilesystemParams{Path: "p/missing"})
+		if !ns.IsNotExistNefError(err) {
+			t.Errorf("expected ENOENT error, but got: %v", err)
+		}
+	})
+
+	t.Run("CreateSnapshot() should return created snapshot", func(t *testing.T) {
+		client := newFakeCreateNef()
+		snapshot, err := newFakeProvider(client).CreateSnapshot(ns.CreateSnapshotParams{Path: "p/fs@s"})
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(snapshot, client.snapshots["p/fs"][0]) {
+			t.Errorf("expected %+v, but got %+v", client.snapshots["p/fs"][0], snapshot)
+		}
+	})
+
+	t.Run("CloneSnapshot() should return created filesystem", func(t *testing.T) {
+		client := newFakeCreateNef()
+		result, err := newFakeProvider(client).CloneSnapshot("p/fs@s", ns.CloneSnapshotParams{TargetPath: "p/clone"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.CloneSnapshotResult{
+			Path:       "p/clone",
+			Type:       ns.DatasetTypeFilesystem,
+			Filesystem: &client.filesystems["p"][1],
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, result)
+		}
+	})
+
+	t.Run("CloneSnapshot() should return created volume for volume snapshot", func(t *testing.T) {
+		client := newFakeCreateNef()
+		result, err := newFakeProvider(client).CloneSnapshot("p/vg/v@s", ns.CloneSnapshotParams{TargetPath: "p/vg/vclone"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.CloneSnapshotResult{
+			Path:   "p/vg/vclone",
+			Type:   ns.DatasetTypeVolume,
+			Volume: &client.volumes["p/vg"][1],
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, result)
+		}
+	})
+
+	t.Run("CreateNfsShare() should return created share", func(t *testing.T) {
+		client := newFakeCreateNef()
+		share, err := newFakeProvider(client).CreateNfsShare(ns.CreateNfsShareParams{Filesystem: "p/fs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.NfsShare{
+			Filesystem:    "p/fs",
+			ReadWriteList: []ns.NfsRuleList{{Etype: "fqdn", Entity: "*"}},
+			ReadOnlyList:  []ns.NfsRuleList{{Etype: "fqdn", Entity: "none"}},
+		}
+		if !reflect.DeepEqual(share, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, share)
+		}
+	})
+
+	t.Run("CreateSmbShare() should return created share with name generated by NS", func(t *testing.T) {
+		client := newFakeCreateNef()
+		share, err := newFakeProvider(client).CreateSmbShare(ns.CreateSmbShareParams{Filesystem: "p/fs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.SmbShare{Filesystem: "p/fs", ShareName: "p_fs"}
+		if share != expected {
+			t.Errorf("expected %+v, but got %+v", expected, share)
+		}
+	})
+
+	t.Run("CreateHostGroup() should return created hostGroup", func(t *testing.T) {
+		client := newFakeCreateNef()
+		hostGroup, err := newFakeProvider(client).CreateHostGroup(ns.CreateHostGroupParams{
+			Name:    "hg",
+			Members: []string{"iqn.a"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.HostGroup{Name: "hg", Members: []string{"iqn.a"}}
+		if !reflect.DeepEqual(hostGroup, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, hostGroup)
+		}
+	})
+
+	t.Run("CreateFCHostGroup() should return created hostGroup with formatted WWNs", func(t *testing.T) {
+		client := newFakeCreateNef()
+		hostGroup, err := newFakeProvider(client).CreateFCHostGroup(ns.CreateFCHostGroupParams{
+			Name: "fc",
+			Wwns: []string{"21:00:00:24:ff:4b:8c:1a"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.HostGroup{Name: "fc", Members: []string{"wwn.21000024FF4B8C1A"}}
+		if !reflect.DeepEqual(hostGroup, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, hostGroup)
+		}
+	})
+	t.Run("CreateFCTargetGroup() should return targetGroup with added WWNs", func(t *testing.T) {
+		client := newFakeCreateNef()
+		client.targetGroups = map[string]*ns.TargetGroup{
+			"fc": {Name: "fc", Members: []string{"wwn.21000024FF4B8C1A"}},
+		}
+		targetGroup, err := newFakeProvider(client).CreateFCTargetGroup(ns.CreateFCTargetGroupParams{
+			Name: "fc",
+			Wwns: []string{"21:00:00:24:ff:4b:8c:1b"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.TargetGroup{Name: "fc", Members: []string{"wwn.21000024FF4B8C1A", "wwn.21000024FF4B8C1B"}}
+		if !reflect.DeepEqual(targetGroup, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, targetGroup)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package provider_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func newFakeTenantNef() *fakeNef {
+	return &fakeNef{
+		filesystems: map[string][]ns.Filesystem{
+			"": {{Path: "p/t", SharedOverNfs: true}},
+			"p/t": {
+				{Path: "p/t/a"},
+				{Path: "p/t/b"},
+			},
+		},
+		volumeGroups: map[string][]ns.VolumeGroup{
+			"p/t": {{Path: "p/t/vg"}},
+		},
+		volumes: map[string][]ns.Volume{
+			"p/t/vg": {{Path: "p/t/vg/v1"}},
+		},
+		snapshots: map[string][]ns.Snapshot{
+			"p/t/a": {{Path: "p/t/a@s1", Clones: []string{"p/t/b"}, CreationTxg: "1"}},
+		},
+		lunMappings: map[string][]ns.LunMapping{
+			"p/t/vg/v1": {{Id: "m1", Volume: "p/t/vg/v1"}},
+		},
+		failed: map[string]bool{},
+	}
+}
+
+func TestProvider_DestroyTree(t *testing.T) {
+	expectedPlan := []string{
+		"delete NFS share of filesystem 'p/t'",
+		"delete LUN mapping 'm1' of volume 'p/t/vg/v1'",
+		"destroy volume 'p/t/vg/v1'",
+		"destroy filesystem 'p/t/b'",
+		"destroy volumeGroup 'p/t/vg'",
+		"destroy snapshot 'p/t/a@s1'",
+		"destroy filesystem 'p/t/a'",
+		"destroy filesystem 'p/t'",
+	}
+
+	t.Run("DestroyTree() dry run should return plan in dependency order", func(t *testing.T) {
+		client := newFakeTenantNef()
+		result, err := newFakeProvider(client).DestroyTree("p/t", ns.DestroyTreeParams{DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Plan) != len(expectedPlan) {
+			t.Fatalf("expected plan:\n%v\nbut got:\n%v", expectedPlan, result.Plan)
+		}
+		for i, action := range result.Plan {
+			if action.String() != expectedPlan[i] {
+				t.Errorf("step %d: expected '%s', but got '%s'", i, expectedPlan[i], action)
+			}
+		}
+		for _, request := range client.requests {
+			if !strings.HasPrefix(request, http.MethodGet) {
+				t.Errorf("dry run should not change anything, but sent: %s", request)
+			}
+		}
+	})
+
+	t.Run("DestroyTree() should skip actions depending on failed ones", func(t *testing.T) {
+		client := newFakeTenantNef()
+		client.failed["DELETE storage/filesystems/p%2Ft%2Fb"] = true
+
+		result, err := newFakeProvider(client).DestroyTree("p/t", ns.DestroyTreeParams{})
+		if err == nil {
+			t.Fatal("expected an error, but got nil")
+		}
+		if len(result.Failed) != 1 || result.Failed[0].Action.Path != "p/t/b" {
+			t.Errorf("expected 'p/t/b' destroy to fail, but got: %v", result.Failed)
+		}
+		if len(result.Skipped) != 3 {
+			t.Errorf("expected 3 skipped actions, but got: %v", result.Skipped)
+		}
+		if len(result.Done) != 4 {
+			t.Errorf("expected 4 done actions, but got: %v", result.Done)
+		}
+	})
+}
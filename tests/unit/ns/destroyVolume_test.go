@@ -0,0 +1,122 @@
+package provider_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_DestroyVolume(t *testing.T) {
+	const volumeURI = "DELETE storage/volumes/p%2Fvg%2Fv"
+
+	newFakeDestroyVolumeNef := func(lunMappings []ns.LunMapping, sessions []ns.ISCSISession) *fakeNef {
+		return &fakeNef{
+			lunMappings: map[string][]ns.LunMapping{"p/vg/v": lunMappings},
+			responses: map[string][]fakeResponse{
+				"GET san/iscsi/sessions": {dataResponse(sessions)},
+				"GET san/hostgroups": {dataResponse([]ns.HostGroup{
+					{Name: "hg", Members: []string{"iqn.host1"}},
+				})},
+				"GET san/targetgroups/tg": {{http.StatusOK, ns.TargetGroup{Name: "tg", Members: []string{"iqn.target1"}}}},
+				"GET san/logicalUnits":    {dataResponse([]ns.LogicalUnit{{Guid: "600144F0", Volume: "p/vg/v"}})},
+			},
+		}
+	}
+
+	params := ns.DestroyVolumeParams{DestroyLunMappings: true, DestroyLogicalUnit: true}
+
+	t.Run("DestroyVolume() should remove LUN mappings and logical unit before the volume", func(t *testing.T) {
+		lunMappings := []ns.LunMapping{{Id: "m1", Volume: "p/vg/v", HostGroup: "hg", TargetGroup: "tg"}}
+		client := newFakeDestroyVolumeNef(lunMappings, nil)
+		result, err := newFakeProvider(client).DestroyVolume("p/vg/v", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result.RemovedLunMappings, lunMappings) {
+			t.Errorf("expected removed LUN mappings %+v, but got %+v", lunMappings, result.RemovedLunMappings)
+		}
+		if len(result.RemovedLogicalUnits) != 1 || result.RemovedLogicalUnits[0].Guid != "600144F0" {
+			t.Errorf("expected removed logical unit '600144F0', but got %+v", result.RemovedLogicalUnits)
+		}
+		expected := []string{"DELETE san/lunMappings/m1", "DELETE san/logicalUnits/600144F0", volumeURI}
+		deleted := []string{}
+		for _, request := range client.requests {
+			if strings.HasPrefix(request, http.MethodDelete) {
+				deleted = append(deleted, strings.SplitN(request, "?", 2)[0])
+			}
+		}
+		if !reflect.DeepEqual(deleted, expected) {
+			t.Errorf("expected delete requests %v, but got %v", expected, deleted)
+		}
+	})
+
+	t.Run("DestroyVolume() should not report LUN mappings which are already deleted", func(t *testing.T) {
+		client := newFakeDestroyVolumeNef([]ns.LunMapping{
+			{Id: "m1", Volume: "p/vg/v", HostGroup: "hg", TargetGroup: "tg"},
+			{Id: "m2", Volume: "p/vg/v", HostGroup: "All", TargetGroup: "All"},
+		}, nil)
+		client.responses["DELETE san/lunMappings/m1"] = []fakeResponse{nefErrorResponse(http.StatusNotFound, "ENOENT")}
+		client.responses["DELETE san/logicalUnits/600144F0"] = []fakeResponse{nefErrorResponse(http.StatusNotFound, "ENOENT")}
+		result, err := newFakeProvider(client).DestroyVolume("p/vg/v", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.RemovedLunMappings) != 1 || result.RemovedLunMappings[0].Id != "m2" {
+			t.Errorf("expected only 'm2' LUN mapping to be reported, but got %+v", result.RemovedLunMappings)
+		}
+		if len(result.RemovedLogicalUnits) != 0 {
+			t.Errorf("expected no logical units to be reported, but got %+v", result.RemovedLogicalUnits)
+		}
+	})
+
+	for name, lunMapping := range map[string]ns.LunMapping{
+		"named groups": {Id: "m1", Volume: "p/vg/v", HostGroup: "hg", TargetGroup: "tg"},
+		"All groups":   {Id: "m1", Volume: "p/vg/v", HostGroup: "All", TargetGroup: "All"},
+	} {
+		lunMappings := []ns.LunMapping{lunMapping}
+		sessions := []ns.ISCSISession{{Initiator: "iqn.host1", Target: "iqn.target1"}}
+
+		t.Run("DestroyVolume() should refuse to destroy volume with active sessions, "+name, func(t *testing.T) {
+			client := newFakeDestroyVolumeNef(lunMappings, sessions)
+			result, err := newFakeProvider(client).DestroyVolume("p/vg/v", params)
+			if !ns.IsBusyNefError(err) {
+				t.Fatalf("expected EBUSY error, but got: %v", err)
+			}
+			if len(result.RemovedLunMappings) != 0 || len(client.payloads) != 0 {
+				t.Errorf("nothing should be deleted, but sent: %v", client.requests)
+			}
+		})
+
+		t.Run("DestroyVolume() should destroy volume with active sessions if forced, "+name, func(t *testing.T) {
+			client := newFakeDestroyVolumeNef(lunMappings, sessions)
+			forceParams := params
+			forceParams.Force = true
+			result, err := newFakeProvider(client).DestroyVolume("p/vg/v", forceParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.RemovedLunMappings, lunMappings) {
+				t.Errorf("expected removed LUN mappings %+v, but got %+v", lunMappings, result.RemovedLunMappings)
+			}
+			if _, ok := client.payloads[volumeURI]; !ok {
+				t.Errorf("expected volume to be destroyed, but sent: %v", client.requests)
+			}
+		})
+	}
+
+	t.Run("DestroyVolume() should ignore sessions of other initiators", func(t *testing.T) {
+		client := newFakeDestroyVolumeNef(
+			[]ns.LunMapping{{Id: "m1", Volume: "p/vg/v", HostGroup: "hg", TargetGroup: "tg"}},
+			[]ns.ISCSISession{{Initiator: "iqn.host2", Target: "iqn.target1"}},
+		)
+		if _, err := newFakeProvider(client).DestroyVolume("p/vg/v", params); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads[volumeURI]; !ok {
+			t.Errorf("expected volume to be destroyed, but sent: %v", client.requests)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package provider_test
+
+import (
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func newDiskFakeNef() *fakeNef {
+	return &fakeNef{
+		pools: []ns.Pool{{
+			Name: "pool1",
+			Topology: ns.PoolTopology{
+				Data: []ns.Vdev{{
+					Name: "mirror-0",
+					Type: ns.VdevTypeMirror,
+					Children: []ns.Vdev{
+						{Name: "c1t1d0", State: "ONLINE"},
+						{Name: "c1t2d0", State: "FAULTED"},
+					},
+				}},
+				Log:   []ns.Vdev{{Name: "c2t1d0", State: "ONLINE"}},
+				Spare: []ns.Vdev{{Name: "c1t3d0", State: "AVAIL"}},
+			},
+		}},
+		disks: []ns.Disk{
+			{Name: "c1t1d0", Serial: "S1", Enclosure: "jbod0", Slot: 1, SmartHealth: "OK"},
+			{Name: "c1t2d0", Serial: "S2", Enclosure: "jbod0", Slot: 2, SmartHealth: "OK"},
+			{Name: "c1t3d0", Serial: "S3", Enclosure: "jbod0", Slot: 3, SmartHealth: "OK"},
+			{Name: "c1t4d0", Serial: "S4", Enclosure: "jbod0", Slot: 4, SmartHealth: "FAILING"},
+			{Name: "c2t1d0", Serial: "S5", Enclosure: "jbod1", Slot: 0, SmartHealth: "OK"},
+		},
+	}
+}
+
+func TestProvider_GetDisks(t *testing.T) {
+	nsp := newFakeProvider(newDiskFakeNef())
+
+	disks, err := nsp.GetDisks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ns.DiskUsage{
+		"c1t1d0": ns.DiskUsageVdev,
+		"c1t2d0": ns.DiskUsageVdev,
+		"c1t3d0": ns.DiskUsageSpare,
+		"c1t4d0": ns.DiskUsageUnused,
+		"c2t1d0": ns.DiskUsageLog,
+	}
+	if len(disks) != len(expected) {
+		t.Fatalf("expected %d disks, but got: %+v", len(expected), disks)
+	}
+	for _, disk := range disks {
+		if disk.Usage != expected[disk.Name] {
+			t.Errorf("disk '%s': expected usage '%s', but got '%s'", disk.Name, expected[disk.Name], disk.Usage)
+		}
+		if disk.Usage != ns.DiskUsageUnused && disk.Pool != "pool1" {
+			t.Errorf("disk '%s': expected pool 'pool1', but got '%s'", disk.Name, disk.Pool)
+		}
+	}
+}
+
+func TestProvider_GetFailedDisks(t *testing.T) {
+	nsp := newFakeProvider(newDiskFakeNef())
+
+	failed, err := nsp.GetFailedDisks()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(failed) != 2 || failed[0].Name != "c1t2d0" || failed[1].Name != "c1t4d0" {
+		t.Fatalf("expected disks c1t2d0 and c1t4d0 to be failed, but got: %+v", failed)
+	}
+
+	if location := failed[0].Location(); location != "enclosure jbod0 slot 2" {
+		t.Errorf("expected 'enclosure jbod0 slot 2' location, but got '%s'", location)
+	}
+}
+
+func TestProvider_GetDisk(t *testing.T) {
+	nsp := newFakeProvider(newDiskFakeNef())
+
+	if disk, err := nsp.GetDisk("s5"); err != nil || disk.Name != "c2t1d0" {
+		t.Errorf("expected disk c2t1d0 found by serial, but got %+v and error: %v", disk, err)
+	}
+	if _, err := nsp.GetDisk("c9t9d0"); !ns.IsNotExistNefError(err) {
+		t.Errorf("expected ENOENT error, but got: %v", err)
+	}
+}
+
+func TestProvider_AddPoolSpares(t *testing.T) {
+	client := newDiskFakeNef()
+	nsp := newFakeProvider(client)
+
+	if err := nsp.AddPoolSpares("pool1", []string{"c1t3d0", "c1t4d0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"devices":["c1t4d0"]}`
+	if payload := string(client.payloads["POST storage/pools/pool1/spares"]); payload != expected {
+		t.Errorf("expected only new spares to be added %s, but got: %s", expected, payload)
+	}
+}
+
+func TestProvider_RemovePoolSpare(t *testing.T) {
+	client := newDiskFakeNef()
+	nsp := newFakeProvider(client)
+
+	if err := nsp.RemovePoolSpare("pool1", "c1t4d0"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := client.payloads["DELETE storage/pools/pool1/spares/c1t4d0"]; ok {
+		t.Error("expected no request to remove not a spare disk")
+	}
+
+	if err := nsp.RemovePoolSpare("pool1", "c1t3d0"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := client.payloads["DELETE storage/pools/pool1/spares/c1t3d0"]; !ok {
+		t.Errorf("expected spare to be removed, but got requests: %v", client.requests)
+	}
+}
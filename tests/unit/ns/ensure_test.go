@@ -0,0 +1,228 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_Ensure(t *testing.T) {
+	const blockSize = 8192
+
+	newFakeEnsureNef := func() *fakeNef {
+		return &fakeNef{
+			filesystems: map[string][]ns.Filesystem{
+				"":  {{Path: "p"}},
+				"p": {{Path: "p/fs", ReferencedQuotaSize: 1024}},
+			},
+			volumes: map[string][]ns.Volume{
+				"p/vg": {{Path: "p/vg/v", VolumeSize: 10 * blockSize, VolumeBlockSize: blockSize, SyncMode: "standard"}},
+			},
+		}
+	}
+
+	noChanges := func(t *testing.T, client *fakeNef) {
+		for _, request := range client.requests {
+			if !strings.HasPrefix(request, http.MethodGet) {
+				t.Errorf("unchanged resource should not be updated, but sent: %s", request)
+			}
+		}
+	}
+
+	t.Run("EnsureFilesystem() should not update filesystem in desired state", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		quota := int64(1024)
+		_, result, err := newFakeProvider(client).EnsureFilesystem(ns.EnsureFilesystemParams{
+			Path:                "p/fs",
+			ReferencedQuotaSize: &quota,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != ns.EnsureActionUnchanged || len(result.Diff) != 0 {
+			t.Errorf("expected unchanged filesystem, but got: %s", result)
+		}
+		noChanges(t, client)
+	})
+
+	t.Run("EnsureFilesystem() should remove quota", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		quota := int64(0)
+		_, result, err := newFakeProvider(client).EnsureFilesystem(ns.EnsureFilesystemParams{
+			Path:                "p/fs",
+			ReferencedQuotaSize: &quota,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.String() != "updated (referencedQuotaSize: 1024 -> 0)" {
+			t.Errorf("unexpected result: %s", result)
+		}
+		if payload := string(client.payloads["PUT storage/filesystems/p%2Ffs"]); payload != `{"referencedQuotaSize":0}` {
+			t.Errorf("unexpected update request: %s", payload)
+		}
+	})
+
+	t.Run("EnsureVolume() should update changed fields only", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		_, result, err := newFakeProvider(client).EnsureVolume(ns.EnsureVolumeParams{
+			Path:       "p/vg/v",
+			VolumeSize: 10 * blockSize,
+			SyncMode:   "always",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != ns.EnsureActionUpdated || len(result.Diff) != 1 || result.Diff[0].Field != "syncMode" {
+			t.Errorf("expected syncMode update, but got: %s", result)
+		}
+		payload := ns.UpdateVolumeParams{}
+		if err := json.Unmarshal(client.payloads["PUT storage/volumes/p%2Fvg%2Fv"], &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload != (ns.UpdateVolumeParams{SyncMode: "always"}) {
+			t.Errorf("unexpected update request: %+v", payload)
+		}
+	})
+
+	t.Run("EnsureVolume() should resize and update volume in a single request", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		_, result, err := newFakeProvider(client).EnsureVolume(ns.EnsureVolumeParams{
+			Path:       "p/vg/v",
+			VolumeSize: 12 * blockSize,
+			SyncMode:   "always",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.String() != "updated (volumeSize: 81920 -> 98304, syncMode: standard -> always)" {
+			t.Errorf("unexpected result: %s", result)
+		}
+		updates := 0
+		for _, request := range client.requests {
+			if strings.HasPrefix(request, http.MethodPut) {
+				updates++
+			}
+		}
+		if updates != 1 {
+			t.Errorf("expected a single update request, but sent: %v", client.requests)
+		}
+		payload := ns.UpdateVolumeParams{}
+		if err := json.Unmarshal(client.payloads["PUT storage/volumes/p%2Fvg%2Fv"], &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload != (ns.UpdateVolumeParams{VolumeSize: 12 * blockSize, SyncMode: "always"}) {
+			t.Errorf("unexpected update request: %+v", payload)
+		}
+	})
+
+	t.Run("EnsureVolume() should not resize volume if update fails", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		client.failed = map[string]bool{"PUT storage/volumes/p%2Fvg%2Fv": true}
+		_, result, err := newFakeProvider(client).EnsureVolume(ns.EnsureVolumeParams{
+			Path:       "p/vg/v",
+			VolumeSize: 12 * blockSize,
+			SyncMode:   "always",
+		})
+		if err == nil {
+			t.Fatal("expected an error, but got nil")
+		} else if !reflect.DeepEqual(result, ns.EnsureResult{}) {
+			t.Errorf("expected empty result on error, but got: %s", result)
+		}
+		if len(client.payloads) != 1 {
+			t.Errorf("expected the only update request, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("EnsureNfsShare() and EnsureSmbShare() should update existing shares", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		client.filesystems["p"][0].SharedOverNfs = true
+		client.filesystems["p"][0].SharedOverSmb = true
+		client.responses = map[string][]fakeResponse{
+			"GET nas/nfs/p%2Ffs": {{http.StatusOK, map[string]interface{}{"filesystem": "p/fs"}}},
+			"GET nas/smb/p%2Ffs": {{http.StatusOK, map[string]string{"shareName": "p_fs"}}},
+		}
+		nsp := newFakeProvider(client)
+
+		result, err := nsp.EnsureNfsShare(ns.CreateNfsShareParams{Filesystem: "p/fs"})
+		if err != nil {
+			t.Fatal(err)
+		} else if result.Action != ns.EnsureActionUpdated {
+			t.Errorf("expected updated NFS share, but got: %s", result)
+		}
+		if _, ok := client.payloads["PUT nas/nfs/p%2Ffs"]; !ok {
+			t.Errorf("expected NFS share update, but sent: %v", client.requests)
+		}
+
+		result, err = nsp.EnsureSmbShare(ns.CreateSmbShareParams{Filesystem: "p/fs", ShareName: "fs"})
+		if err != nil {
+			t.Fatal(err)
+		} else if result.String() != "updated (shareName: p_fs -> fs)" {
+			t.Errorf("unexpected result: %s", result)
+		}
+		if payload := string(client.payloads["PUT nas/smb/p%2Ffs"]); payload != `{"shareName":"fs"}` {
+			t.Errorf("unexpected SMB share update request: %s", payload)
+		}
+	})
+
+	t.Run("EnsureVolume() should fail if volume block size differs", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		_, _, err := newFakeProvider(client).EnsureVolume(ns.EnsureVolumeParams{
+			Path:            "p/vg/v",
+			VolumeBlockSize: 2 * blockSize,
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		}
+		noChanges(t, client)
+	})
+
+	t.Run("Ensure*() should return empty result if creation fails", func(t *testing.T) {
+		client := newFakeEnsureNef()
+		client.failed = map[string]bool{
+			"POST storage/filesystems": true,
+			"POST storage/volumes":     true,
+			"POST storage/snapshots":   true,
+			"POST nas/nfs":             true,
+			"POST nas/smb":             true,
+		}
+		client.responses = map[string][]fakeResponse{
+			"GET storage/snapshots/p%2Ffs@s": {nefErrorResponse(http.StatusNotFound, "ENOENT")},
+		}
+		nsp := newFakeProvider(client)
+		quota := int64(1024)
+
+		results := map[string]func() (ns.EnsureResult, error){
+			"EnsureFilesystem": func() (ns.EnsureResult, error) {
+				_, result, err := nsp.EnsureFilesystem(ns.EnsureFilesystemParams{Path: "p/fs2", ReferencedQuotaSize: &quota})
+				return result, err
+			},
+			"EnsureVolume": func() (ns.EnsureResult, error) {
+				_, result, err := nsp.EnsureVolume(ns.EnsureVolumeParams{Path: "p/vg/v2", VolumeSize: blockSize})
+				return result, err
+			},
+			"EnsureNfsShare": func() (ns.EnsureResult, error) {
+				return nsp.EnsureNfsShare(ns.CreateNfsShareParams{Filesystem: "p/fs"})
+			},
+			"EnsureSmbShare": func() (ns.EnsureResult, error) {
+				return nsp.EnsureSmbShare(ns.CreateSmbShareParams{Filesystem: "p/fs", ShareName: "fs"})
+			},
+			"EnsureSnapshot": func() (ns.EnsureResult, error) {
+				_, result, err := nsp.EnsureSnapshot(ns.CreateSnapshotParams{Path: "p/fs@s"})
+				return result, err
+			},
+		}
+		for name, ensure := range results {
+			result, err := ensure()
+			if err == nil {
+				t.Errorf("%s(): expected an error, but got nil", name)
+			} else if !reflect.DeepEqual(result, ns.EnsureResult{}) {
+				t.Errorf("%s(): expected empty result on error, but got: %s", name, result)
+			}
+		}
+	})
+}
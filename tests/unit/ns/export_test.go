@@ -0,0 +1,211 @@
+package provider_test
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_ExportVolumeISCSI(t *testing.T) {
+	const target = "iqn.2005-07.com.nexenta:01:test"
+	const targetGroup = "tg-" + target
+
+	initiators := []string{"iqn.host2", "iqn.host1"}
+	// hostGroup name is the same for any order of initiators
+	hostGroup := fmt.Sprintf("hg-%x", sha1.Sum([]byte("iqn.host1,iqn.host2")))[:11]
+	portals := []ns.Portal{{Address: "10.0.0.1", Port: 3260}}
+
+	newFakeExportNef := func() *fakeNef {
+		return &fakeNef{
+			volumes: map[string][]ns.Volume{
+				"p/vg": {{Path: "p/vg/v1"}, {Path: "p/vg/v2"}},
+			},
+			hostGroups:   map[string]*ns.HostGroup{},
+			targetGroups: map[string]*ns.TargetGroup{},
+			lunMappings:  map[string][]ns.LunMapping{},
+			responses: map[string][]fakeResponse{
+				"POST san/iscsi/targets": {
+					{http.StatusCreated, map[string]string{}},
+					nefErrorResponse(http.StatusConflict, "EEXIST"),
+				},
+				"GET san/iscsi/targets": {dataResponse([]ns.ISCSITarget{{Name: target, Portals: portals}})},
+				"GET san/logicalUnits?volume=p%2Fvg%2Fv1": {
+					dataResponse([]ns.LogicalUnit{{Guid: "600144F0AAAA", Volume: "p/vg/v1"}}),
+				},
+				"GET san/logicalUnits?volume=p%2Fvg%2Fv2": {
+					dataResponse([]ns.LogicalUnit{{Guid: "600144F0BBBB", Volume: "p/vg/v2"}}),
+				},
+			},
+		}
+	}
+
+	exportParams := ns.ExportVolumeISCSIParams{Target: target, Portals: portals}
+
+	checkExportResult := func(t *testing.T, client *fakeNef, result ns.ExportVolumeISCSIResult) {
+		t.Helper()
+		expected := ns.ExportVolumeISCSIResult{
+			TargetIQN: target,
+			Portals:   portals,
+			DeviceWWN: "600144f0aaaa",
+			LunMapping: ns.LunMapping{
+				Id:          "m1",
+				Volume:      "p/vg/v1",
+				TargetGroup: targetGroup,
+				HostGroup:   hostGroup,
+			},
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, result)
+		}
+		if hg, ok := client.hostGroups[hostGroup]; !ok || !reflect.DeepEqual(hg.Members, initiators) {
+			t.Errorf("expected hostGroup '%s' with members %v, but got: %v", hostGroup, initiators, client.hostGroups)
+		}
+		if tg, ok := client.targetGroups[targetGroup]; !ok || !reflect.DeepEqual(tg.Members, []string{target}) {
+			t.Errorf("expected targetGroup '%s' with target, but got: %v", targetGroup, client.targetGroups)
+		}
+	}
+
+	t.Run("ExportVolumeISCSI() should create SAN objects with default group names", func(t *testing.T) {
+		client := newFakeExportNef()
+		result, err := newFakeProvider(client).ExportVolumeISCSI("p/vg/v1", initiators, exportParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkExportResult(t, client, result)
+	})
+
+	t.Run("ExportVolumeISCSI() should be idempotent", func(t *testing.T) {
+		client := newFakeExportNef()
+		nsp := newFakeProvider(client)
+		if _, err := nsp.ExportVolumeISCSI("p/vg/v1", initiators, exportParams); err != nil {
+			t.Fatal(err)
+		}
+		client.payloads = nil
+		// the same initiators in other order must resolve to the same hostGroup
+		result, err := nsp.ExportVolumeISCSI("p/vg/v1", []string{"iqn.host1", "iqn.host2"}, exportParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkExportResult(t, client, result)
+		for request := range client.payloads {
+			if !strings.HasPrefix(request, http.MethodPost) {
+				t.Errorf("existing objects should not be updated, but sent: %s", request)
+			}
+		}
+		if len(client.lunMappings["p/vg/v1"]) != 1 {
+			t.Errorf("expected a single LUN mapping, but got: %+v", client.lunMappings["p/vg/v1"])
+		}
+	})
+
+	t.Run("ExportVolumeISCSI() should complete export after a failure midway", func(t *testing.T) {
+		client := newFakeExportNef()
+		client.responses["POST san/lunMappings"] = []fakeResponse{nefErrorResponse(http.StatusInternalServerError, "EFAILED")}
+		nsp := newFakeProvider(client)
+		if _, err := nsp.ExportVolumeISCSI("p/vg/v1", initiators, exportParams); err == nil {
+			t.Fatal("expected LUN mapping creation error, but got nil")
+		}
+		if len(client.lunMappings["p/vg/v1"]) != 0 {
+			t.Fatalf("LUN mapping should not be created, but got: %+v", client.lunMappings["p/vg/v1"])
+		}
+
+		delete(client.responses, "POST san/lunMappings")
+		result, err := nsp.ExportVolumeISCSI("p/vg/v1", initiators, exportParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkExportResult(t, client, result)
+	})
+
+	t.Run("ExportVolumeISCSI() should refuse existing target with different portals", func(t *testing.T) {
+		client := newFakeExportNef()
+		client.responses["POST san/iscsi/targets"] = []fakeResponse{nefErrorResponse(http.StatusConflict, "EEXIST")}
+		params := exportParams
+		params.Portals = []ns.Portal{{Address: "10.0.0.2"}}
+		if _, err := newFakeProvider(client).ExportVolumeISCSI("p/vg/v1", initiators, params); err == nil {
+			t.Fatal("expected an error, but got nil")
+		}
+		if len(client.hostGroups) != 0 || len(client.targetGroups) != 0 {
+			t.Errorf("groups should not be created, but got: %v, %v", client.hostGroups, client.targetGroups)
+		}
+	})
+
+	t.Run("UnexportVolumeISCSI() should keep SAN objects shared with other volumes", func(t *testing.T) {
+		client := newFakeExportNef()
+		nsp := newFakeProvider(client)
+		for _, volume := range []string{"p/vg/v1", "p/vg/v2"} {
+			if _, err := nsp.ExportVolumeISCSI(volume, initiators, exportParams); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := nsp.UnexportVolumeISCSI("p/vg/v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.RemovedLunMappings) != 1 || result.RemovedLunMappings[0].Volume != "p/vg/v1" {
+			t.Errorf("expected LUN mapping of 'p/vg/v1' to be removed, but got: %+v", result.RemovedLunMappings)
+		}
+		if len(result.RemovedHostGroups) != 0 || len(result.RemovedTargetGroups) != 0 || len(result.RemovedTargets) != 0 {
+			t.Errorf("shared SAN objects should not be removed, but got: %+v", result)
+		}
+		if _, ok := client.hostGroups[hostGroup]; !ok {
+			t.Errorf("shared hostGroup '%s' should exist", hostGroup)
+		}
+
+		lunMappings := client.lunMappings["p/vg/v2"]
+		result, err = nsp.UnexportVolumeISCSI("p/vg/v2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.UnexportVolumeISCSIResult{
+			RemovedLunMappings:  lunMappings,
+			RemovedLogicalUnits: []ns.LogicalUnit{{Guid: "600144F0BBBB", Volume: "p/vg/v2"}},
+			RemovedHostGroups:   []string{hostGroup},
+			RemovedTargetGroups: []string{targetGroup},
+			RemovedTargets:      []string{target},
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, result)
+		}
+		if len(client.hostGroups) != 0 || len(client.targetGroups) != 0 {
+			t.Errorf("groups should be deleted, but got: %v, %v", client.hostGroups, client.targetGroups)
+		}
+	})
+
+	t.Run("UnexportVolumeISCSI() should complete cleanup after a failure midway", func(t *testing.T) {
+		client := newFakeExportNef()
+		nsp := newFakeProvider(client)
+		if _, err := nsp.ExportVolumeISCSI("p/vg/v1", initiators, exportParams); err != nil {
+			t.Fatal(err)
+		}
+
+		client.responses["DELETE san/logicalUnits/600144F0AAAA"] = []fakeResponse{
+			nefErrorResponse(http.StatusInternalServerError, "EFAILED"),
+			{http.StatusOK, map[string]string{}},
+		}
+		result, err := nsp.UnexportVolumeISCSI("p/vg/v1")
+		if err == nil {
+			t.Fatal("expected logical unit deletion error, but got nil")
+		}
+		expected := []string{hostGroup}
+		if len(result.RemovedLunMappings) != 1 || !reflect.DeepEqual(result.RemovedHostGroups, expected) {
+			t.Errorf("expected LUN mapping and hostGroup to be removed before the failure, but got: %+v", result)
+		}
+
+		result, err = nsp.UnexportVolumeISCSI("p/vg/v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.RemovedLogicalUnits) != 1 {
+			t.Errorf("expected logical unit to be removed on retry, but got: %+v", result)
+		}
+		if len(client.hostGroups) != 0 || len(client.targetGroups) != 0 {
+			t.Errorf("groups should be deleted, but got: %v, %v", client.hostGroups, client.targetGroups)
+		}
+	})
+}
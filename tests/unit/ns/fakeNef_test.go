@@ -0,0 +1,339 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/ns"
+)
+
+// fakeNef - handles NEF requests sent by provider, responds with `{"data": []}` to unknown GET requests
+type fakeNef struct {
+	filesystems  map[string][]ns.Filesystem
+	volumeGroups map[string][]ns.VolumeGroup
+	volumes      map[string][]ns.Volume
+	snapshots    map[string][]ns.Snapshot
+	// LUN mappings by volume, created and deleted by POST and DELETE requests
+	lunMappings  map[string][]ns.LunMapping
+	hostGroups   map[string]*ns.HostGroup
+	targetGroups map[string]*ns.TargetGroup
+	pools        []ns.Pool
+	disks        []ns.Disk
+	// analytics series points by entity
+	analytics map[string][]map[string]interface{}
+	// async job IDs started by "METHOD escaped/path" requests and job completion by ID
+	asyncJobs map[string]string
+	jobsDone  map[string]bool
+	failed    map[string]bool
+	// canned responses by "METHOD escaped/path?query" or "METHOD escaped/path" overriding default handling,
+	// each request takes the next response, the last one is repeated
+	responses map[string][]fakeResponse
+
+	requests []string
+	// request payloads by "METHOD escaped/path"
+	payloads map[string][]byte
+}
+
+// fakeResponse - canned NEF response, body is sent as JSON
+type fakeResponse struct {
+	statusCode int
+	body       interface{}
+}
+
+// nefErrorResponse returns NEF error response with the code, e.g. "ENOENT"
+func nefErrorResponse(statusCode int, code string) fakeResponse {
+	return fakeResponse{statusCode, map[string]string{"name": "Error", "message": code, "code": code}}
+}
+
+// dataResponse returns NEF list response
+func dataResponse(data interface{}) fakeResponse {
+	return fakeResponse{http.StatusOK, map[string]interface{}{"data": data}}
+}
+
+// nextResponse returns the next canned response for the request
+func (f *fakeNef) nextResponse(method string, u *url.URL) (fakeResponse, bool) {
+	keys := []string{fmt.Sprintf("%s %s", method, u.EscapedPath())}
+	if u.RawQuery != "" {
+		keys = append([]string{fmt.Sprintf("%s %s?%s", method, u.EscapedPath(), u.RawQuery)}, keys...)
+	}
+	for _, key := range keys {
+		responses := f.responses[key]
+		if len(responses) == 0 {
+			continue
+		}
+		if len(responses) > 1 {
+			f.responses[key] = responses[1:]
+		}
+		return responses[0], true
+	}
+	return fakeResponse{}, false
+}
+
+func (f *fakeNef) BuildURI(uri string, params map[string]string) string {
+	values := url.Values{}
+	for key, val := range params {
+		if val != "" {
+			values.Set(key, val)
+		}
+	}
+	if len(values) == 0 {
+		return uri
+	}
+	return fmt.Sprintf("%s?%s", uri, values.Encode())
+}
+
+func (f *fakeNef) SetAuthToken(token string) {}
+
+func (f *fakeNef) Send(method, path string, data interface{}) (int, []byte, error) {
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", method, path))
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	query := u.Query()
+
+	if method != http.MethodGet {
+		if f.payloads == nil {
+			f.payloads = map[string][]byte{}
+		}
+		f.payloads[fmt.Sprintf("%s %s", method, u.EscapedPath())], _ = json.Marshal(data)
+	}
+
+	if response, ok := f.nextResponse(method, u); ok {
+		body, err := json.Marshal(response.body)
+		return response.statusCode, body, err
+	}
+
+	if method != http.MethodGet {
+		if f.failed[fmt.Sprintf("%s %s", method, u.EscapedPath())] {
+			return http.StatusInternalServerError, []byte(`{"name":"Error","message":"failed","code":"EFAILED"}`), nil
+		}
+		if method == http.MethodPut && strings.HasPrefix(u.Path, "san/hostgroups/") {
+			hostGroup := f.hostGroups[strings.TrimPrefix(u.Path, "san/hostgroups/")]
+			hostGroup.Members = data.(ns.UpdateHostGroupParams).Members
+		}
+		if method == http.MethodPut && strings.HasPrefix(u.Path, "san/targetgroups/") {
+			targetGroup := f.targetGroups[strings.TrimPrefix(u.Path, "san/targetgroups/")]
+			targetGroup.Members = data.(ns.UpdateTargetGroupParams).Members
+		}
+		if method == http.MethodPost && u.Path == "san/targetgroups" && f.targetGroups != nil {
+			params := data.(ns.CreateTargetGroupParams)
+			f.targetGroups[params.Name] = &ns.TargetGroup{Name: params.Name, Members: params.Members}
+		}
+		if method == http.MethodPost && u.Path == "san/hostgroups" && f.hostGroups != nil {
+			params := data.(ns.CreateHostGroupParams)
+			f.hostGroups[params.Name] = &ns.HostGroup{Name: params.Name, Members: params.Members}
+		}
+		if method == http.MethodDelete && strings.HasPrefix(u.Path, "san/hostgroups/") {
+			delete(f.hostGroups, strings.TrimPrefix(u.Path, "san/hostgroups/"))
+		}
+		if method == http.MethodDelete && strings.HasPrefix(u.Path, "san/targetgroups/") {
+			delete(f.targetGroups, strings.TrimPrefix(u.Path, "san/targetgroups/"))
+		}
+		if method == http.MethodPost && u.Path == "san/lunMappings" && f.lunMappings != nil {
+			return f.createLunMapping(data.(ns.CreateLunMappingParams))
+		}
+		if method == http.MethodDelete && strings.HasPrefix(u.Path, "san/lunMappings/") {
+			f.deleteLunMapping(strings.TrimPrefix(u.Path, "san/lunMappings/"))
+		}
+		if jobID, ok := f.asyncJobs[fmt.Sprintf("%s %s", method, u.EscapedPath())]; ok {
+			return http.StatusAccepted, []byte(`{"links":[{"rel":"monitor","href":"/jobStatus/` + jobID + `"}]}`), nil
+		}
+		if method == http.MethodPost && u.Path == "storage/pools" {
+			pool := ns.Pool{}
+			json.Unmarshal(f.payloads["POST storage/pools"], &pool)
+			f.pools = append(f.pools, pool)
+		}
+		return http.StatusOK, []byte("{}"), nil
+	}
+
+	if strings.HasPrefix(u.Path, "jobStatus/") {
+		if f.jobsDone[strings.TrimPrefix(u.Path, "jobStatus/")] {
+			return http.StatusOK, []byte("{}"), nil
+		}
+		return http.StatusAccepted, []byte("{}"), nil
+	}
+
+	var response interface{}
+	switch {
+	case u.Path == "storage/filesystems" && query.Get("path") != "":
+		response = map[string]interface{}{"data": findFilesystem(f.filesystems, query.Get("path"))}
+	case u.Path == "storage/filesystems":
+		response = map[string]interface{}{"data": f.filesystems[query.Get("parent")]}
+	case u.Path == "storage/volumeGroups" && query.Get("path") != "":
+		response = map[string]interface{}{"data": findVolumeGroup(f.volumeGroups, query.Get("path"))}
+	case u.Path == "storage/volumeGroups":
+		volumeGroups := f.volumeGroups[query.Get("parent")]
+		if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			volumeGroups = volumeGroups[min(offset, len(volumeGroups)):min(offset+limit, len(volumeGroups))]
+		}
+		response = map[string]interface{}{"data": volumeGroups}
+	case u.Path == "storage/volumes" && query.Get("path") != "":
+		response = map[string]interface{}{"data": findVolume(f.volumes, query.Get("path"))}
+	case u.Path == "storage/volumes":
+		response = map[string]interface{}{"data": f.volumes[query.Get("parent")]}
+	case u.Path == "storage/snapshots":
+		response = map[string]interface{}{"data": f.snapshots[query.Get("parent")]}
+	case strings.HasPrefix(u.Path, "storage/snapshots/"):
+		snapshotPath := strings.TrimPrefix(u.Path, "storage/snapshots/")
+		for _, snapshots := range f.snapshots {
+			for _, snapshot := range snapshots {
+				if snapshot.Path == snapshotPath {
+					response = snapshot
+				}
+			}
+		}
+	case strings.HasPrefix(u.Path, "san/hostgroups/"):
+		hostGroup, ok := f.hostGroups[strings.TrimPrefix(u.Path, "san/hostgroups/")]
+		if !ok {
+			return http.StatusNotFound, []byte(`{"name":"Error","message":"not found","code":"ENOENT"}`), nil
+		}
+		response = hostGroup
+	case strings.HasPrefix(u.Path, "san/targetgroups/"):
+		targetGroup, ok := f.targetGroups[strings.TrimPrefix(u.Path, "san/targetgroups/")]
+		if !ok {
+			return http.StatusNotFound, []byte(`{"name":"Error","message":"not found","code":"ENOENT"}`), nil
+		}
+		response = targetGroup
+	case u.Path == "san/targetgroups":
+		targetGroups := []ns.TargetGroup{}
+		for _, name := range sortedNames(f.targetGroups) {
+			targetGroups = append(targetGroups, *f.targetGroups[name])
+		}
+		response = map[string]interface{}{"data": targetGroups}
+	case u.Path == "storage/pools":
+		response = map[string]interface{}{"data": f.pools}
+	case strings.HasPrefix(u.Path, "storage/pools/"):
+		for _, pool := range f.pools {
+			if pool.Name == strings.TrimPrefix(u.Path, "storage/pools/") {
+				response = pool
+			}
+		}
+		if response == nil {
+			return http.StatusNotFound, []byte(`{"name":"Error","message":"not found","code":"ENOENT"}`), nil
+		}
+	case u.Path == "analytics/series":
+		response = map[string]interface{}{"data": f.analytics[query.Get("entity")]}
+	case u.Path == "inventory/disks":
+		response = map[string]interface{}{"data": f.disks}
+	case u.Path == "san/lunMappings":
+		response = map[string]interface{}{"data": f.findLunMappings(query)}
+	default:
+		response = map[string]interface{}{"data": []interface{}{}}
+	}
+
+	body, err := json.Marshal(response)
+	return http.StatusOK, body, err
+}
+
+func findFilesystem(filesystems map[string][]ns.Filesystem, path string) []ns.Filesystem {
+	for _, list := range filesystems {
+		for _, fs := range list {
+			if fs.Path == path {
+				return []ns.Filesystem{fs}
+			}
+		}
+	}
+	return []ns.Filesystem{}
+}
+
+func findVolumeGroup(volumeGroups map[string][]ns.VolumeGroup, path string) []ns.VolumeGroup {
+	for _, list := range volumeGroups {
+		for _, volumeGroup := range list {
+			if volumeGroup.Path == path {
+				return []ns.VolumeGroup{volumeGroup}
+			}
+		}
+	}
+	return []ns.VolumeGroup{}
+}
+
+func findVolume(volumes map[string][]ns.Volume, path string) []ns.Volume {
+	for _, list := range volumes {
+		for _, volume := range list {
+			if volume.Path == path {
+				return []ns.Volume{volume}
+			}
+		}
+	}
+	return []ns.Volume{}
+}
+
+// createLunMapping adds LUN mapping with the next free LUN if not set, responds with EEXIST to a duplicate
+func (f *fakeNef) createLunMapping(params ns.CreateLunMappingParams) (int, []byte, error) {
+	lun, count := 0, 0
+	for _, lunMappings := range f.lunMappings {
+		for _, lunMapping := range lunMappings {
+			if lunMapping.Volume == params.Volume && lunMapping.TargetGroup == params.TargetGroup &&
+				lunMapping.HostGroup == params.HostGroup {
+				return http.StatusConflict, []byte(`{"name":"Error","message":"exists","code":"EEXIST"}`), nil
+			}
+			if lunMapping.Lun >= lun {
+				lun = lunMapping.Lun + 1
+			}
+			count++
+		}
+	}
+	if params.Lun != nil {
+		lun = *params.Lun
+	}
+	f.lunMappings[params.Volume] = append(f.lunMappings[params.Volume], ns.LunMapping{
+		Id:          fmt.Sprintf("m%d", count+1),
+		Volume:      params.Volume,
+		TargetGroup: params.TargetGroup,
+		HostGroup:   params.HostGroup,
+		Lun:         lun,
+	})
+	return http.StatusCreated, []byte("{}"), nil
+}
+
+func (f *fakeNef) deleteLunMapping(id string) {
+	for volume, lunMappings := range f.lunMappings {
+		for i, lunMapping := range lunMappings {
+			if lunMapping.Id == id {
+				f.lunMappings[volume] = append(lunMappings[:i:i], lunMappings[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// findLunMappings returns LUN mappings matching "volume", "hostGroup" and "targetGroup" query params
+func (f *fakeNef) findLunMappings(query url.Values) []ns.LunMapping {
+	result := []ns.LunMapping{}
+	for _, volume := range sortedNames(f.lunMappings) {
+		for _, lunMapping := range f.lunMappings[volume] {
+			if (query.Get("volume") == "" || query.Get("volume") == lunMapping.Volume) &&
+				(query.Get("hostGroup") == "" || query.Get("hostGroup") == lunMapping.HostGroup) &&
+				(query.Get("targetGroup") == "" || query.Get("targetGroup") == lunMapping.TargetGroup) {
+				result = append(result, lunMapping)
+			}
+		}
+	}
+	return result
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newFakeProvider(client *fakeNef) *ns.Provider {
+	return &ns.Provider{
+		Address:    "fake",
+		RestClient: client,
+		Log:        logger.NewNoopLogger(),
+	}
+}
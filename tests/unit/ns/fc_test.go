@@ -0,0 +1,34 @@
+package provider_test
+
+import (
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestFormatWWN(t *testing.T) {
+	expected := "wwn.21000024FF4B8C1A"
+	for _, wwn := range []string{
+		"21:00:00:24:ff:4b:8c:1a",
+		"21000024ff4b8c1a",
+		"wwn.21000024FF4B8C1A",
+		" WWN.21000024ff4b8c1a ",
+	} {
+		t.Run("FormatWWN() should format '"+wwn+"'", func(t *testing.T) {
+			formatted, err := ns.FormatWWN(wwn)
+			if err != nil {
+				t.Error(err)
+			} else if formatted != expected {
+				t.Errorf("expected '%s', but got '%s'", expected, formatted)
+			}
+		})
+	}
+
+	for _, wwn := range []string{"", "21:00:00:24:ff:4b:8c", "21000024ff4b8c1z"} {
+		t.Run("FormatWWN() should reject '"+wwn+"'", func(t *testing.T) {
+			if formatted, err := ns.FormatWWN(wwn); err == nil {
+				t.Errorf("expected an error, but got '%s'", formatted)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/ns"
+)
+
+type recordingHooks struct {
+	ns.NoopHooks
+
+	nefErrors []string
+	jobWaits  int
+}
+
+func (h *recordingHooks) ObserveNefError(method, pathTemplate, code string) {
+	h.nefErrors = append(h.nefErrors, method+" "+pathTemplate+" "+code)
+}
+
+func (h *recordingHooks) ObserveJobWait(duration time.Duration, err error) {
+	h.jobWaits++
+}
+
+func TestProvider_Hooks(t *testing.T) {
+	client := &fakeNef{
+		hostGroups: map[string]*ns.HostGroup{},
+		asyncJobs:  map[string]string{"DELETE storage/filesystems/pool1%2Ffs": "job1"},
+		jobsDone:   map[string]bool{"job1": true},
+	}
+	hooks := &recordingHooks{}
+	nsp := newFakeProvider(client)
+	nsp.Hooks = hooks
+
+	t.Run("NEF error code should be reported with path template", func(t *testing.T) {
+		if _, err := nsp.GetHostGroup("hg1"); !ns.IsNotExistNefError(err) {
+			t.Fatalf("expected ENOENT error, but got: %v", err)
+		}
+		expected := "GET san/hostgroups/{id} ENOENT"
+		if len(hooks.nefErrors) != 1 || hooks.nefErrors[0] != expected {
+			t.Errorf("expected '%s' error to be reported, but got: %v", expected, hooks.nefErrors)
+		}
+	})
+
+	t.Run("async job wait should be reported", func(t *testing.T) {
+		if err := nsp.DestroyFilesystem("pool1/fs", ns.DestroyFilesystemParams{}); err != nil {
+			t.Fatal(err)
+		} else if hooks.jobWaits != 1 {
+			t.Errorf("expected 1 job wait to be reported, but got %d", hooks.jobWaits)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package provider_test
+
+import (
+	"reflect"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_HostGroupMembers(t *testing.T) {
+	newFakeHostGroupNef := func() *fakeNef {
+		return &fakeNef{
+			hostGroups: map[string]*ns.HostGroup{
+				"hg": {Name: "hg", Members: []string{"iqn.a", "iqn.b"}},
+			},
+		}
+	}
+
+	t.Run("AddHostGroupMembers() should keep existing members", func(t *testing.T) {
+		client := newFakeHostGroupNef()
+		err := newFakeProvider(client).AddHostGroupMembers("hg", []string{"iqn.b", "iqn.c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"iqn.a", "iqn.b", "iqn.c"}
+		if !reflect.DeepEqual(client.hostGroups["hg"].Members, expected) {
+			t.Errorf("expected members %v, but got %v", expected, client.hostGroups["hg"].Members)
+		}
+	})
+
+	t.Run("RemoveHostGroupMembers() should keep other members", func(t *testing.T) {
+		client := newFakeHostGroupNef()
+		err := newFakeProvider(client).RemoveHostGroupMembers("hg", []string{"iqn.a", "iqn.x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"iqn.b"}
+		if !reflect.DeepEqual(client.hostGroups["hg"].Members, expected) {
+			t.Errorf("expected members %v, but got %v", expected, client.hostGroups["hg"].Members)
+		}
+	})
+
+	t.Run("AddHostGroupMembers() should not update hostGroup if members are already there", func(t *testing.T) {
+		client := newFakeHostGroupNef()
+		err := newFakeProvider(client).AddHostGroupMembers("hg", []string{"iqn.a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(client.payloads) != 0 {
+			t.Errorf("expected no updates, but got: %v", client.payloads)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package provider_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_ISCSITarget(t *testing.T) {
+	const updateURI = "PUT san/iscsi/targets/iqn.target1"
+
+	existing := ns.ISCSITarget{
+		Name:    "iqn.target1",
+		Alias:   "old",
+		Portals: []ns.Portal{{Address: "10.0.0.1", Port: 3260}, {Address: "10.0.0.2", Port: 3261}},
+	}
+
+	// target creation fails with EEXIST, the existing target is returned on read
+	newFakeTargetNef := func() *fakeNef {
+		return &fakeNef{
+			responses: map[string][]fakeResponse{
+				"POST san/iscsi/targets": {nefErrorResponse(http.StatusConflict, "EEXIST")},
+				"GET san/iscsi/targets":  {dataResponse([]ns.ISCSITarget{existing})},
+			},
+		}
+	}
+
+	t.Run("CreateISCSITarget() should return created target", func(t *testing.T) {
+		client := newFakeTargetNef()
+		client.responses["POST san/iscsi/targets"] = []fakeResponse{{http.StatusCreated, map[string]string{}}}
+		target, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+			Name:    "iqn.target1",
+			Portals: existing.Portals,
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(target, existing) {
+			t.Errorf("expected %+v, but got %+v", existing, target)
+		}
+	})
+
+	t.Run("CreateISCSITarget() should require name", func(t *testing.T) {
+		client := newFakeTargetNef()
+		if _, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{}); err == nil {
+			t.Error("expected an error for empty name, but got nil")
+		}
+		if len(client.requests) != 0 {
+			t.Errorf("no requests expected, but sent: %v", client.requests)
+		}
+	})
+
+	for name, portals := range map[string][]ns.Portal{
+		"same order":      {{Address: "10.0.0.1", Port: 3260}, {Address: "10.0.0.2", Port: 3261}},
+		"different order": {{Address: "10.0.0.2", Port: 3261}, {Address: "10.0.0.1", Port: 3260}},
+		"default port":    {{Address: "10.0.0.1"}, {Address: "10.0.0.2", Port: 3261}},
+	} {
+		portals := portals
+		t.Run("CreateISCSITarget() should accept existing target with the same portals, "+name, func(t *testing.T) {
+			client := newFakeTargetNef()
+			target, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+				Name:    "iqn.target1",
+				Portals: portals,
+			})
+			if err != nil {
+				t.Fatal(err)
+			} else if target.Name != existing.Name {
+				t.Errorf("expected existing target to be returned, but got %+v", target)
+			}
+			if _, ok := client.payloads[updateURI]; ok {
+				t.Error("existing target should not be updated")
+			}
+		})
+	}
+
+	for name, portals := range map[string][]ns.Portal{
+		"different port":    {{Address: "10.0.0.1", Port: 3260}, {Address: "10.0.0.2", Port: 3260}},
+		"different address": {{Address: "10.0.0.1", Port: 3260}, {Address: "10.0.0.3", Port: 3261}},
+		"missing portal":    {{Address: "10.0.0.1", Port: 3260}},
+		"duplicated portal": {{Address: "10.0.0.1", Port: 3260}, {Address: "10.0.0.1", Port: 3260}},
+	} {
+		portals := portals
+		t.Run("CreateISCSITarget() should refuse existing target with different portals, "+name, func(t *testing.T) {
+			client := newFakeTargetNef()
+			_, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+				Name:    "iqn.target1",
+				Portals: portals,
+			})
+			if !ns.IsAlreadyExistNefError(err) {
+				t.Errorf("expected EEXIST error, but got: %v", err)
+			}
+			if _, ok := client.payloads[updateURI]; ok {
+				t.Error("existing target should not be updated")
+			}
+		})
+	}
+
+	t.Run("CreateISCSITarget() should update existing target with different portals if Ensure is set", func(t *testing.T) {
+		client := newFakeTargetNef()
+		_, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+			Name:    "iqn.target1",
+			Portals: []ns.Portal{{Address: "10.0.0.3"}},
+			Alias:   "new",
+			Ensure:  true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"portals":[{"address":"10.0.0.3","port":0}],"alias":"new"}`
+		if payload := string(client.payloads[updateURI]); payload != expected {
+			t.Errorf("expected update request %s, but got: %s", expected, payload)
+		}
+	})
+
+	t.Run("CreateISCSITarget() should not update matching target if Ensure is set", func(t *testing.T) {
+		client := newFakeTargetNef()
+		_, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+			Name:    "iqn.target1",
+			Portals: existing.Portals,
+			Alias:   "old",
+			Ensure:  true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads[updateURI]; ok {
+			t.Error("existing target should not be updated")
+		}
+	})
+
+	t.Run("CreateISCSITarget() should return an error if existing target cannot be read", func(t *testing.T) {
+		client := newFakeTargetNef()
+		client.responses["GET san/iscsi/targets"] = []fakeResponse{dataResponse([]ns.ISCSITarget{})}
+		_, err := newFakeProvider(client).CreateISCSITarget(ns.CreateISCSITargetParams{
+			Name:    "iqn.target1",
+			Portals: existing.Portals,
+			Ensure:  true,
+		})
+		if err == nil {
+			t.Error("expected an error, but got nil")
+		}
+	})
+
+	t.Run("DeleteISCSITarget() should delete target by name", func(t *testing.T) {
+		client := newFakeTargetNef()
+		nsp := newFakeProvider(client)
+		if err := nsp.DeleteISCSITarget("iqn.target1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads["DELETE san/iscsi/targets/iqn.target1"]; !ok {
+			t.Errorf("expected target to be deleted, but sent: %v", client.requests)
+		}
+		if err := nsp.DeleteISCSITarget(""); err == nil {
+			t.Error("expected an error for empty name, but got nil")
+		}
+	})
+
+	t.Run("DeleteISCSITarget() should return NEF error", func(t *testing.T) {
+		client := newFakeTargetNef()
+		client.responses["DELETE san/iscsi/targets/iqn.target1"] = []fakeResponse{nefErrorResponse(http.StatusNotFound, "ENOENT")}
+		if err := newFakeProvider(client).DeleteISCSITarget("iqn.target1"); !ns.IsNotExistNefError(err) {
+			t.Errorf("expected ENOENT error, but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package provider_test
+
+import (
+	"net/http"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_LogicalUnit(t *testing.T) {
+	logicalUnit := ns.LogicalUnit{Guid: "600144F0", Volume: "p/vg/v", Alias: "db", BlockSize: 4096}
+
+	newFakeLogicalUnitNef := func() *fakeNef {
+		return &fakeNef{
+			responses: map[string][]fakeResponse{
+				"GET san/logicalUnits?guid=600144F0":        {dataResponse([]ns.LogicalUnit{logicalUnit})},
+				"GET san/logicalUnits?volume=p%2Fvg%2Fv":    {dataResponse([]ns.LogicalUnit{logicalUnit})},
+				"GET san/logicalUnits?guid=unknown":         {dataResponse([]ns.LogicalUnit{})},
+				"GET san/logicalUnits?volume=p%2Fvg%2Fnolu": {dataResponse([]ns.LogicalUnit{})},
+			},
+		}
+	}
+
+	t.Run("GetLogicalUnit() should return logicalUnit by GUID", func(t *testing.T) {
+		result, err := newFakeProvider(newFakeLogicalUnitNef()).GetLogicalUnit("600144F0")
+		if err != nil {
+			t.Fatal(err)
+		} else if result != logicalUnit {
+			t.Errorf("expected %+v, but got %+v", logicalUnit, result)
+		}
+	})
+
+	t.Run("GetLogicalUnit() should return ENOENT error for unknown GUID", func(t *testing.T) {
+		_, err := newFakeProvider(newFakeLogicalUnitNef()).GetLogicalUnit("unknown")
+		if !ns.IsNotExistNefError(err) {
+			t.Errorf("expected ENOENT error, but got: %v", err)
+		}
+	})
+
+	t.Run("GetLogicalUnitByVolume() should return logicalUnit of the volume", func(t *testing.T) {
+		nsp := newFakeProvider(newFakeLogicalUnitNef())
+		result, err := nsp.GetLogicalUnitByVolume("p/vg/v")
+		if err != nil {
+			t.Fatal(err)
+		} else if result != logicalUnit {
+			t.Errorf("expected %+v, but got %+v", logicalUnit, result)
+		}
+		if _, err := nsp.GetLogicalUnitByVolume("p/vg/nolu"); !ns.IsNotExistNefError(err) {
+			t.Errorf("expected ENOENT error for volume without logicalUnit, but got: %v", err)
+		}
+	})
+
+	t.Run("CreateLogicalUnit() should send set params only and return created logicalUnit", func(t *testing.T) {
+		client := newFakeLogicalUnitNef()
+		result, err := newFakeProvider(client).CreateLogicalUnit(ns.CreateLogicalUnitParams{
+			Volume:    "p/vg/v",
+			BlockSize: 4096,
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if result != logicalUnit {
+			t.Errorf("expected %+v, but got %+v", logicalUnit, result)
+		}
+		if payload := string(client.payloads["POST san/logicalUnits"]); payload != `{"volume":"p/vg/v","blockSize":4096}` {
+			t.Errorf("unexpected create request: %s", payload)
+		}
+	})
+
+	t.Run("CreateLogicalUnit() should return NEF error", func(t *testing.T) {
+		client := newFakeLogicalUnitNef()
+		client.responses["POST san/logicalUnits"] = []fakeResponse{nefErrorResponse(http.StatusConflict, "EEXIST")}
+		_, err := newFakeProvider(client).CreateLogicalUnit(ns.CreateLogicalUnitParams{Volume: "p/vg/v"})
+		if !ns.IsAlreadyExistNefError(err) {
+			t.Errorf("expected EEXIST error, but got: %v", err)
+		}
+	})
+
+	t.Run("UpdateLogicalUnit() should send set params only", func(t *testing.T) {
+		client := newFakeLogicalUnitNef()
+		writeProtect := true
+		err := newFakeProvider(client).UpdateLogicalUnit("600144F0", ns.UpdateLogicalUnitParams{
+			WriteProtect: &writeProtect,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload := string(client.payloads["PUT san/logicalUnits/600144F0"]); payload != `{"writeProtect":true}` {
+			t.Errorf("unexpected update request: %s", payload)
+		}
+	})
+
+	t.Run("DeleteLogicalUnit() should delete logicalUnit by GUID", func(t *testing.T) {
+		client := newFakeLogicalUnitNef()
+		if err := newFakeProvider(client).DeleteLogicalUnit("600144F0"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads["DELETE san/logicalUnits/600144F0"]; !ok {
+			t.Errorf("expected logicalUnit to be deleted, but sent: %v", client.requests)
+		}
+		if err := newFakeProvider(client).DeleteLogicalUnit(""); err == nil {
+			t.Error("expected an error for empty GUID, but got nil")
+		}
+	})
+}
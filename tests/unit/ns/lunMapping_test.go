@@ -0,0 +1,131 @@
+package provider_test
+
+import (
+	"net/http"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_CreateLunMapping(t *testing.T) {
+	newFakeLunMappingNef := func() *fakeNef {
+		return &fakeNef{
+			lunMappings: map[string][]ns.LunMapping{
+				"p/vg/v1": {{Id: "m1", Volume: "p/vg/v1", TargetGroup: "tg", HostGroup: "hg", Lun: 3}},
+			},
+		}
+	}
+
+	lun := func(lun int) *int {
+		return &lun
+	}
+
+	t.Run("CreateLunMapping() should return created LUN mapping", func(t *testing.T) {
+		client := newFakeLunMappingNef()
+		lunMapping, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+			Volume:      "p/vg/v2",
+			TargetGroup: "tg",
+			HostGroup:   "hg",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ns.LunMapping{Id: "m2", Volume: "p/vg/v2", TargetGroup: "tg", HostGroup: "hg", Lun: 4}
+		if lunMapping != expected {
+			t.Errorf("expected %+v, but got %+v", expected, lunMapping)
+		}
+		if payload := string(client.payloads["POST san/lunMappings"]); payload != `{"hostGroup":"hg","volume":"p/vg/v2","targetGroup":"tg"}` {
+			t.Errorf("unexpected create request: %s", payload)
+		}
+	})
+
+	t.Run("CreateLunMapping() should send requested LUN", func(t *testing.T) {
+		client := newFakeLunMappingNef()
+		lunMapping, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+			Volume:      "p/vg/v2",
+			TargetGroup: "tg",
+			HostGroup:   "hg",
+			Lun:         lun(10),
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if lunMapping.Lun != 10 {
+			t.Errorf("expected LUN 10, but got %+v", lunMapping)
+		}
+		if payload := string(client.payloads["POST san/lunMappings"]); payload != `{"hostGroup":"hg","volume":"p/vg/v2","targetGroup":"tg","lun":10}` {
+			t.Errorf("unexpected create request: %s", payload)
+		}
+	})
+
+	for name, requestedLun := range map[string]*int{"any LUN": nil, "the same LUN": lun(3)} {
+		requestedLun := requestedLun
+		t.Run("CreateLunMapping() should return existing LUN mapping, "+name, func(t *testing.T) {
+			client := newFakeLunMappingNef()
+			lunMapping, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+				Volume:      "p/vg/v1",
+				TargetGroup: "tg",
+				HostGroup:   "hg",
+				Lun:         requestedLun,
+			})
+			if err != nil {
+				t.Fatal(err)
+			} else if lunMapping != client.lunMappings["p/vg/v1"][0] {
+				t.Errorf("expected %+v, but got %+v", client.lunMappings["p/vg/v1"][0], lunMapping)
+			}
+		})
+	}
+
+	t.Run("CreateLunMapping() should refuse existing LUN mapping with other LUN", func(t *testing.T) {
+		client := newFakeLunMappingNef()
+		lunMapping, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+			Volume:      "p/vg/v1",
+			TargetGroup: "tg",
+			HostGroup:   "hg",
+			Lun:         lun(5),
+		})
+		if !ns.IsAlreadyExistNefError(err) {
+			t.Errorf("expected EEXIST error, but got: %v", err)
+		} else if lunMapping.Id != "m1" {
+			t.Errorf("expected existing LUN mapping to be returned, but got %+v", lunMapping)
+		}
+	})
+
+	t.Run("CreateLunMapping() should return NEF error if no LUN mapping of the volume exists", func(t *testing.T) {
+		client := newFakeLunMappingNef()
+		client.responses = map[string][]fakeResponse{
+			// requested LUN is used by other volume
+			"POST san/lunMappings": {nefErrorResponse(http.StatusConflict, "EEXIST")},
+		}
+		_, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+			Volume:      "p/vg/v2",
+			TargetGroup: "tg",
+			HostGroup:   "hg",
+			Lun:         lun(3),
+		})
+		if !ns.IsAlreadyExistNefError(err) {
+			t.Errorf("expected EEXIST error, but got: %v", err)
+		}
+	})
+
+	t.Run("CreateLunMapping() should return ENOENT error if created LUN mapping is not found", func(t *testing.T) {
+		client := &fakeNef{}
+		_, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{
+			Volume:      "p/vg/v2",
+			TargetGroup: "tg",
+			HostGroup:   "hg",
+		})
+		if !ns.IsNotExistNefError(err) {
+			t.Errorf("expected ENOENT error, but got: %v", err)
+		}
+	})
+
+	t.Run("CreateLunMapping() should require volume and groups", func(t *testing.T) {
+		client := newFakeLunMappingNef()
+		_, err := newFakeProvider(client).CreateLunMapping(ns.CreateLunMappingParams{Volume: "p/vg/v2"})
+		if err == nil {
+			t.Error("expected an error, but got nil")
+		} else if len(client.requests) != 0 {
+			t.Errorf("no requests expected, but sent: %v", client.requests)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_ReplacePoolDevice(t *testing.T) {
+	lastResilverTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	resilverTime := lastResilverTime.Add(time.Hour)
+	client := &fakeNef{
+		pools: []ns.Pool{{
+			Name: "pool1",
+			Scan: ns.PoolScan{Function: "resilver", State: ns.PoolScanStateFinished, StartTime: &lastResilverTime},
+		}},
+		asyncJobs: map[string]string{
+			"POST storage/pools/pool1/vdevs/c1t2d0/replace": "job1",
+		},
+		jobsDone: map[string]bool{},
+	}
+	nsp := newFakeProvider(client)
+
+	job, err := nsp.ReplacePoolDevice("pool1", "c1t2d0", "c1t9d0")
+	if err != nil {
+		t.Fatal(err)
+	} else if job.JobID != "job1" || job.Pool != "pool1" {
+		t.Fatalf("expected job 'job1' of pool 'pool1', but got: %+v", job)
+	}
+
+	expected := `{"device":"c1t9d0"}`
+	if payload := string(client.payloads["POST storage/pools/pool1/vdevs/c1t2d0/replace"]); payload != expected {
+		t.Errorf("expected payload %s, but got: %s", expected, payload)
+	}
+
+	t.Run("job should not be done while NS job is running", func(t *testing.T) {
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if status.Done {
+			t.Errorf("expected job not to be done, but got: %+v", status)
+		}
+	})
+
+	t.Run("job should not be done until new resilver is started", func(t *testing.T) {
+		client.jobsDone["job1"] = true
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if status.Done {
+			t.Errorf("expected job not to be done, but got: %+v", status)
+		}
+	})
+
+	t.Run("job should report resilver progress", func(t *testing.T) {
+		client.pools[0].Scan = ns.PoolScan{
+			Function:  "resilver",
+			State:     ns.PoolScanStateScanning,
+			StartTime: &resilverTime,
+			ToExamine: 400,
+			Examined:  100,
+		}
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if status.Done || status.Progress != 25 {
+			t.Errorf("expected job at 25%% progress, but got: %+v", status)
+		}
+	})
+
+	t.Run("job should be done when resilver is finished", func(t *testing.T) {
+		client.pools[0].Scan.State = ns.PoolScanStateFinished
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if !status.Done || status.Progress != 100 {
+			t.Errorf("expected job to be done, but got: %+v", status)
+		}
+	})
+}
+
+func TestProvider_AttachPoolDevice(t *testing.T) {
+	scrubTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := &fakeNef{
+		pools: []ns.Pool{{
+			Name: "pool1",
+			Scan: ns.PoolScan{Function: "scrub", State: ns.PoolScanStateScanning, StartTime: &scrubTime},
+		}},
+	}
+	nsp := newFakeProvider(client)
+
+	job, err := nsp.AttachPoolDevice("pool1", "c1t1d0", "c1t9d0")
+	if err != nil {
+		t.Fatal(err)
+	} else if job.JobID != "" {
+		t.Fatalf("expected synchronous request, but got job: %+v", job)
+	}
+
+	t.Run("job should not be done while scrub is running", func(t *testing.T) {
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if status.Done {
+			t.Errorf("expected job not to be done, but got: %+v", status)
+		}
+	})
+
+	t.Run("job should be done when resilver started after attach is finished", func(t *testing.T) {
+		resilverTime := scrubTime.Add(time.Minute)
+		client.pools[0].Scan = ns.PoolScan{
+			Function:  "resilver",
+			State:     ns.PoolScanStateFinished,
+			StartTime: &resilverTime,
+		}
+		status, err := job.Status()
+		if err != nil {
+			t.Fatal(err)
+		} else if !status.Done {
+			t.Errorf("expected job to be done, but got: %+v", status)
+		}
+	})
+}
+
+func TestProvider_RemovePoolDevice(t *testing.T) {
+	client := &fakeNef{
+		pools: []ns.Pool{{
+			Name: "pool1",
+			Topology: ns.PoolTopology{
+				Data:  []ns.Vdev{{Name: "c1t1d0"}},
+				Cache: []ns.Vdev{{Name: "c2t1d0"}},
+			},
+		}},
+	}
+	nsp := newFakeProvider(client)
+
+	if err := nsp.RemovePoolDevice("pool1", "c1t1d0"); !ns.IsBadArgNefError(err) {
+		t.Errorf("expected EBADARG error for data device, but got: %v", err)
+	}
+
+	if err := nsp.RemovePoolDevice("pool1", "c9t9d0"); err != nil {
+		t.Errorf("expected no error for device not in the pool, but got: %v", err)
+	}
+
+	if err := nsp.RemovePoolDevice("pool1", "c2t1d0"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := client.payloads["DELETE storage/pools/pool1/vdevs/c2t1d0"]; !ok {
+		t.Errorf("expected cache device to be removed, but got requests: %v", client.requests)
+	}
+}
+
+func TestProvider_AddPoolVdevs(t *testing.T) {
+	client := &fakeNef{}
+	nsp := newFakeProvider(client)
+
+	if err := nsp.AddPoolVdevs("pool1", ns.PoolLayout{}); !ns.IsBadArgNefError(err) {
+		t.Errorf("expected EBADARG error for empty layout, but got: %v", err)
+	}
+
+	if err := nsp.AddPoolVdevs("pool1", ns.PoolLayout{Cache: []string{"c2t1d0"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"topology":{"cache":["c2t1d0"]}}`
+	if payload := string(client.payloads["POST storage/pools/pool1/vdevs"]); payload != expected {
+		t.Errorf("expected payload %s, but got: %s", expected, payload)
+	}
+}
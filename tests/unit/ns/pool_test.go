@@ -0,0 +1,121 @@
+package provider_test
+
+import (
+	"net/http"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestPoolLayout_Validate(t *testing.T) {
+	valid := ns.PoolLayout{
+		Data: []ns.VdevSpec{
+			{Type: ns.VdevTypeMirror, Devices: []string{"c1t1d0", "c1t2d0"}},
+			{Type: ns.VdevTypeRaidz2, Devices: []string{"c1t3d0", "c1t4d0", "c1t5d0"}},
+		},
+		Log:   []ns.VdevSpec{{Type: ns.VdevTypeDisk, Devices: []string{"c2t1d0"}}},
+		Cache: []string{"c2t2d0"},
+		Spare: []string{"c2t3d0"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid layout should pass validation, but got: %s", err)
+	}
+
+	for name, layout := range map[string]ns.PoolLayout{
+		"no data vdevs": {Spare: []string{"c1t1d0"}},
+		"unknown type":  {Data: []ns.VdevSpec{{Type: "raid5", Devices: []string{"c1t1d0", "c1t2d0"}}}},
+		"short mirror":  {Data: []ns.VdevSpec{{Type: ns.VdevTypeMirror, Devices: []string{"c1t1d0"}}}},
+		"short raidz3":  {Data: []ns.VdevSpec{{Type: ns.VdevTypeRaidz3, Devices: []string{"a", "b", "c"}}}},
+		"multi disk":    {Data: []ns.VdevSpec{{Type: ns.VdevTypeDisk, Devices: []string{"a", "b"}}}},
+		"reused device": {
+			Data:  []ns.VdevSpec{{Type: ns.VdevTypeMirror, Devices: []string{"a", "b"}}},
+			Spare: []string{"b"},
+		},
+	} {
+		t.Run("Validate() should reject "+name, func(t *testing.T) {
+			if err := layout.Validate(); err == nil {
+				t.Error("expected an error, but got nil")
+			}
+		})
+	}
+}
+
+func TestPool_Errors(t *testing.T) {
+	pool := ns.Pool{
+		Topology: ns.PoolTopology{
+			Data: []ns.Vdev{{
+				Name: "mirror-0",
+				Type: ns.VdevTypeMirror,
+				Children: []ns.Vdev{
+					{Name: "c1t1d0", VdevErrors: ns.VdevErrors{Read: 1, Checksum: 2}},
+					{Name: "c1t2d0", VdevErrors: ns.VdevErrors{Write: 3}},
+				},
+			}},
+			Log: []ns.Vdev{{Name: "c2t1d0", VdevErrors: ns.VdevErrors{Read: 4}}},
+		},
+	}
+
+	expected := ns.VdevErrors{Read: 5, Write: 3, Checksum: 2}
+	if errors := pool.Errors(); errors != expected {
+		t.Errorf("expected %+v, but got %+v", expected, errors)
+	} else if errors.Total() != 10 {
+		t.Errorf("expected 10 errors total, but got %d", errors.Total())
+	}
+}
+
+func TestProvider_CreatePool(t *testing.T) {
+	t.Run("should send vdev layout as pool topology", func(t *testing.T) {
+		client := &fakeNef{}
+		nsp := newFakeProvider(client)
+
+		_, err := nsp.CreatePool(ns.CreatePoolParams{
+			Name: "pool1",
+			Layout: ns.PoolLayout{
+				Data:  []ns.VdevSpec{{Type: ns.VdevTypeMirror, Devices: []string{"c1t1d0", "c1t2d0"}}},
+				Spare: []string{"c1t3d0"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `{"poolName":"pool1","topology":{"group":[{"type":"mirror","devices":["c1t1d0","c1t2d0"]}],"spare":["c1t3d0"]}}`
+		if payload := string(client.payloads["POST storage/pools"]); payload != expected {
+			t.Errorf("expected payload %s, but got: %s", expected, payload)
+		}
+	})
+
+	t.Run("should not send request if layout is invalid", func(t *testing.T) {
+		client := &fakeNef{}
+		nsp := newFakeProvider(client)
+
+		_, err := nsp.CreatePool(ns.CreatePoolParams{
+			Name:   "pool1",
+			Layout: ns.PoolLayout{Data: []ns.VdevSpec{{Type: ns.VdevTypeRaidz1, Devices: []string{"c1t1d0"}}}},
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		} else if len(client.requests) != 0 {
+			t.Errorf("expected no requests, but got: %v", client.requests)
+		}
+	})
+}
+
+func TestProvider_StartPoolScrub(t *testing.T) {
+	for function, expectedErr := range map[string]bool{"scrub": false, "resilver": true} {
+		t.Run("StartPoolScrub() should handle busy pool with running "+function, func(t *testing.T) {
+			client := &fakeNef{responses: map[string][]fakeResponse{
+				"POST storage/pools/pool1/scrub": {nefErrorResponse(http.StatusConflict, "EBUSY")},
+				"GET storage/pools/pool1?fields=scan": {{http.StatusOK, map[string]interface{}{
+					"scan": ns.PoolScan{Function: function, State: ns.PoolScanStateScanning},
+				}}},
+			}}
+			err := newFakeProvider(client).StartPoolScrub("pool1")
+			if expectedErr && !ns.IsBusyNefError(err) {
+				t.Errorf("expected EBUSY error, but got: %v", err)
+			} else if !expectedErr && err != nil {
+				t.Errorf("expected running scrub not to be an error, but got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package provider_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_RemoteInitiatorChapSecret(t *testing.T) {
+	for _, secret := range []string{"short", "secret-longer-than-16"} {
+		t.Run("CreateRemoteInitiator() should reject '"+secret+"' secret", func(t *testing.T) {
+			client := &fakeNef{}
+			err := newFakeProvider(client).CreateRemoteInitiator(ns.CreateRemoteInitiatorParams{
+				Name:       "iqn.initiator",
+				ChapSecret: secret,
+			})
+			if !ns.IsBadArgNefError(err) {
+				t.Errorf("expected EBADARG error, but got: %v", err)
+			} else if !strings.Contains(err.Error(), "ChapSecret") {
+				t.Errorf("error should contain field name: %s", err)
+			} else if len(client.requests) != 0 {
+				t.Errorf("no request should be sent, but got: %v", client.requests)
+			}
+		})
+	}
+
+	t.Run("CreateRemoteInitiator() should count secret length in characters", func(t *testing.T) {
+		client := &fakeNef{}
+		err := newFakeProvider(client).CreateRemoteInitiator(ns.CreateRemoteInitiatorParams{
+			Name:       "iqn.initiator",
+			ChapSecret: "секрет-секрет", // 13 characters, 25 bytes
+		})
+		if err != nil {
+			t.Errorf("expected secret to be accepted, but got: %v", err)
+		}
+	})
+
+	t.Run("CreateRemoteInitiator() should add field name to NS secret error", func(t *testing.T) {
+		client := &fakeNef{responses: map[string][]fakeResponse{
+			"POST san/iscsi/remoteInitiators": {{http.StatusBadRequest, map[string]string{
+				"name":    "ValidationError",
+				"message": "chapSecret: invalid characters",
+				"code":    "EBADARG",
+			}}},
+		}}
+		err := newFakeProvider(client).CreateRemoteInitiator(ns.CreateRemoteInitiatorParams{
+			Name:       "iqn.initiator",
+			ChapSecret: "secret-secret",
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		} else if !strings.Contains(err.Error(), "CreateRemoteInitiatorParams.ChapSecret") {
+			t.Errorf("error should contain field name: %s", err)
+		}
+	})
+
+	t.Run("CreateRemoteInitiator() should return NS error not related to secret as is", func(t *testing.T) {
+		client := &fakeNef{responses: map[string][]fakeResponse{
+			"POST san/iscsi/remoteInitiators": {{http.StatusBadRequest, map[string]string{
+				"name":    "ValidationError",
+				"message": "name: invalid iSCSI name",
+				"code":    "EBADARG",
+			}}},
+		}}
+		err := newFakeProvider(client).CreateRemoteInitiator(ns.CreateRemoteInitiatorParams{
+			Name:       "bad name",
+			ChapSecret: "secret-secret",
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		} else if strings.Contains(err.Error(), "ChapSecret") {
+			t.Errorf("error should not refer to the secret: %s", err)
+		}
+	})
+
+	t.Run("SetMutualChap() should reject equal secrets", func(t *testing.T) {
+		client := &fakeNef{}
+		err := newFakeProvider(client).SetMutualChap(ns.SetMutualChapParams{
+			Initiator:           "iqn.initiator",
+			InitiatorChapSecret: "secret-secret",
+			Target:              "iqn.target",
+			TargetChapSecret:    "secret-secret",
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		}
+	})
+}
+
+func TestProvider_RemoteInitiator(t *testing.T) {
+	t.Run("remote initiator methods should use versionless API path", func(t *testing.T) {
+		client := &fakeNef{}
+		nsp := newFakeProvider(client)
+		params := ns.CreateRemoteInitiatorParams{Name: "iqn.initiator", ChapSecret: "secret-secret"}
+		if err := nsp.CreateRemoteInitiator(params); err != nil {
+			t.Fatal(err)
+		}
+		err := nsp.UpdateRemoteInitiator("iqn.initiator", ns.UpdateRemoteInitiatorParams{ChapSecret: "secret-secret2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := nsp.ListRemoteInitiators(); err != nil {
+			t.Fatal(err)
+		}
+		if err := nsp.DeleteRemoteInitiator("iqn.initiator"); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"POST san/iscsi/remoteInitiators",
+			"PUT san/iscsi/remoteInitiators/iqn.initiator",
+			"GET san/iscsi/remoteInitiators?fields=name%2CchapUser%2CchapSecretSet",
+			"DELETE san/iscsi/remoteInitiators/iqn.initiator",
+		}
+		if !reflect.DeepEqual(client.requests, expected) {
+			t.Errorf("expected requests %v, but got %v", expected, client.requests)
+		}
+	})
+}
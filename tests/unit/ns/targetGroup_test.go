@@ -0,0 +1,126 @@
+package provider_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_TargetGroup(t *testing.T) {
+	newFakeTargetGroupNef := func() *fakeNef {
+		return &fakeNef{
+			targetGroups: map[string]*ns.TargetGroup{
+				"tg": {Name: "tg", Members: []string{"iqn.a", "iqn.b"}},
+			},
+		}
+	}
+
+	t.Run("UpdateTargetGroup() should replace members", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		err := newFakeProvider(client).UpdateTargetGroup("tg", ns.UpdateTargetGroupParams{Members: []string{"iqn.c"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload := string(client.payloads["PUT san/targetgroups/tg"]); payload != `{"members":["iqn.c"]}` {
+			t.Errorf("unexpected update request: %s", payload)
+		}
+		if err := newFakeProvider(client).UpdateTargetGroup("", ns.UpdateTargetGroupParams{}); err == nil {
+			t.Error("expected an error for empty name, but got nil")
+		}
+	})
+
+	t.Run("AddTargetGroupMembers() should keep existing members", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		err := newFakeProvider(client).AddTargetGroupMembers("tg", []string{"iqn.b", "iqn.c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"iqn.a", "iqn.b", "iqn.c"}
+		if !reflect.DeepEqual(client.targetGroups["tg"].Members, expected) {
+			t.Errorf("expected members %v, but got %v", expected, client.targetGroups["tg"].Members)
+		}
+	})
+
+	t.Run("AddTargetGroupMembers() should not update targetGroup if members are already there", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		if err := newFakeProvider(client).AddTargetGroupMembers("tg", []string{"iqn.a"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(client.payloads) != 0 {
+			t.Errorf("expected no updates, but got: %v", client.payloads)
+		}
+	})
+
+	t.Run("AddTargetGroupMembers() should create missing targetGroup", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		if err := newFakeProvider(client).AddTargetGroupMembers("tg2", []string{"iqn.c"}); err != nil {
+			t.Fatal(err)
+		}
+		if payload := string(client.payloads["POST san/targetgroups"]); payload != `{"name":"tg2","members":["iqn.c"]}` {
+			t.Errorf("unexpected create request: %s", payload)
+		}
+	})
+
+	t.Run("RemoveTargetGroupMembers() should keep other members", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		err := newFakeProvider(client).RemoveTargetGroupMembers("tg", []string{"iqn.a", "iqn.x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"iqn.b"}
+		if !reflect.DeepEqual(client.targetGroups["tg"].Members, expected) {
+			t.Errorf("expected members %v, but got %v", expected, client.targetGroups["tg"].Members)
+		}
+	})
+
+	t.Run("RemoveTargetGroupMembers() should ignore missing targetGroup", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		if err := newFakeProvider(client).RemoveTargetGroupMembers("tg2", []string{"iqn.a"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(client.payloads) != 0 {
+			t.Errorf("expected no updates, but got: %v", client.payloads)
+		}
+	})
+
+	t.Run("DeleteTargetGroup() should delete unused targetGroup", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		if err := newFakeProvider(client).DeleteTargetGroup("tg"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads["DELETE san/targetgroups/tg"]; !ok {
+			t.Errorf("expected targetGroup to be deleted, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("DeleteTargetGroup() should refuse to delete targetGroup used by LUN mappings", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		client.responses = map[string][]fakeResponse{
+			"GET san/lunMappings?fields=id%2Cvolume%2CtargetGroup%2ChostGroup%2Clun&targetGroup=tg": {dataResponse([]ns.LunMapping{
+				{Id: "m1", Volume: "p/vg/v", HostGroup: "hg", TargetGroup: "tg"},
+			})},
+		}
+		err := newFakeProvider(client).DeleteTargetGroup("tg")
+		if !ns.IsBusyNefError(err) {
+			t.Errorf("expected EBUSY error, but got: %v", err)
+		}
+		if _, ok := client.payloads["DELETE san/targetgroups/tg"]; ok {
+			t.Error("targetGroup should not be deleted")
+		}
+	})
+
+	t.Run("DeleteTargetGroup() should not delete targetGroup if LUN mappings cannot be read", func(t *testing.T) {
+		client := newFakeTargetGroupNef()
+		client.responses = map[string][]fakeResponse{
+			"GET san/lunMappings": {nefErrorResponse(http.StatusInternalServerError, "EFAILED")},
+		}
+		if err := newFakeProvider(client).DeleteTargetGroup("tg"); err == nil {
+			t.Error("expected an error, but got nil")
+		}
+		if _, ok := client.payloads["DELETE san/targetgroups/tg"]; ok {
+			t.Error("targetGroup should not be deleted")
+		}
+	})
+}
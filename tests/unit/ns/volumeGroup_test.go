@@ -0,0 +1,153 @@
+package provider_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_VolumeGroup(t *testing.T) {
+	newFakeVolumeGroupNef := func() *fakeNef {
+		return &fakeNef{
+			volumeGroups: map[string][]ns.VolumeGroup{
+				"p": {{Path: "p/vg", VolumeBlockSize: 8192, CompressionMode: "lz4"}},
+			},
+			volumes: map[string][]ns.Volume{
+				"p/vg": {{Path: "p/vg/v1"}, {Path: "p/vg/v2"}},
+			},
+		}
+	}
+
+	deleteRequests := func(client *fakeNef) []string {
+		requests := []string{}
+		for _, request := range client.requests {
+			if strings.HasPrefix(request, http.MethodDelete) {
+				requests = append(requests, request)
+			}
+		}
+		return requests
+	}
+
+	t.Run("GetVolumeGroups() should return all volumeGroups page by page", func(t *testing.T) {
+		client := &fakeNef{volumeGroups: map[string][]ns.VolumeGroup{"p": {}}}
+		for i := 0; i < 150; i++ {
+			client.volumeGroups["p"] = append(client.volumeGroups["p"], ns.VolumeGroup{Path: fmt.Sprintf("p/vg%d", i)})
+		}
+		volumeGroups, err := newFakeProvider(client).GetVolumeGroups("p")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(volumeGroups, client.volumeGroups["p"]) {
+			t.Errorf("expected %d volumeGroups, but got %d", len(client.volumeGroups["p"]), len(volumeGroups))
+		}
+		if len(client.requests) != 2 {
+			t.Errorf("expected 2 page requests, but got: %v", client.requests)
+		}
+	})
+
+	t.Run("CreateVolumeGroup() should send set params only and return created volumeGroup", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		volumeGroup, err := newFakeProvider(client).CreateVolumeGroup(ns.CreateVolumeGroupParams{
+			Path:            "p/vg",
+			CompressionMode: "lz4",
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if volumeGroup != client.volumeGroups["p"][0] {
+			t.Errorf("expected %+v, but got %+v", client.volumeGroups["p"][0], volumeGroup)
+		}
+		if payload := string(client.payloads["POST storage/volumeGroups"]); payload != `{"path":"p/vg","compressionMode":"lz4"}` {
+			t.Errorf("unexpected create request: %s", payload)
+		}
+	})
+
+	t.Run("UpdateVolumeGroup() should send set params only", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		err := newFakeProvider(client).UpdateVolumeGroup("p/vg", ns.UpdateVolumeGroupParams{VolumeBlockSize: 16384})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload := string(client.payloads["PUT storage/volumeGroups/p%2Fvg"]); payload != `{"volumeBlockSize":16384}` {
+			t.Errorf("unexpected update request: %s", payload)
+		}
+	})
+
+	t.Run("DestroyVolumeGroup() should not destroy volumes if not recursive", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		if err := newFakeProvider(client).DestroyVolumeGroup("p/vg", ns.DestroyVolumeGroupParams{}); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"DELETE storage/volumeGroups/p%2Fvg?snapshots=false"}
+		if requests := deleteRequests(client); !reflect.DeepEqual(requests, expected) {
+			t.Errorf("expected delete requests %v, but got %v", expected, requests)
+		}
+	})
+
+	t.Run("DestroyVolumeGroup() should destroy volumes and snapshots first if recursive", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		client.responses = map[string][]fakeResponse{
+			"DELETE storage/volumes/p%2Fvg%2Fv1": {nefErrorResponse(http.StatusNotFound, "ENOENT")},
+		}
+		err := newFakeProvider(client).DestroyVolumeGroup("p/vg", ns.DestroyVolumeGroupParams{
+			Recursive:        true,
+			DestroySnapshots: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"DELETE storage/volumes/p%2Fvg%2Fv1?snapshots=true",
+			"DELETE storage/volumes/p%2Fvg%2Fv2?snapshots=true",
+			"DELETE storage/volumeGroups/p%2Fvg?snapshots=true",
+		}
+		if requests := deleteRequests(client); !reflect.DeepEqual(requests, expected) {
+			t.Errorf("expected delete requests %v, but got %v", expected, requests)
+		}
+	})
+
+	t.Run("DestroyVolumeGroup() should promote clones of volume snapshots", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		client.volumes["p/vg"] = []ns.Volume{{Path: "p/vg/v1"}}
+		client.snapshots = map[string][]ns.Snapshot{
+			"p/vg/v1": {{Path: "p/vg/v1@s1", Clones: []string{"p/vg2/clone"}, CreationTxg: "10"}},
+		}
+		client.responses = map[string][]fakeResponse{
+			"DELETE storage/volumes/p%2Fvg%2Fv1": {
+				nefErrorResponse(http.StatusConflict, "EEXIST"),
+				{http.StatusOK, map[string]string{}},
+			},
+		}
+		err := newFakeProvider(client).DestroyVolumeGroup("p/vg", ns.DestroyVolumeGroupParams{
+			Recursive:                      true,
+			DestroySnapshots:               true,
+			PromoteMostRecentCloneIfExists: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.payloads["POST storage/volumes/p%2Fvg2%2Fclone/promote"]; !ok {
+			t.Errorf("expected clone to be promoted, but sent: %v", client.requests)
+		}
+		if _, ok := client.payloads["DELETE storage/volumeGroups/p%2Fvg"]; !ok {
+			t.Errorf("expected volumeGroup to be destroyed, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("DestroyVolumeGroup() should stop on volume deletion error", func(t *testing.T) {
+		client := newFakeVolumeGroupNef()
+		client.responses = map[string][]fakeResponse{
+			"DELETE storage/volumes/p%2Fvg%2Fv1": {nefErrorResponse(http.StatusConflict, "EEXIST")},
+		}
+		err := newFakeProvider(client).DestroyVolumeGroup("p/vg", ns.DestroyVolumeGroupParams{Recursive: true})
+		if !ns.IsAlreadyExistNefError(err) {
+			t.Errorf("expected EEXIST error, but got: %v", err)
+		}
+		if _, ok := client.payloads["DELETE storage/volumeGroups/p%2Fvg"]; ok {
+			t.Error("volumeGroup should not be destroyed")
+		}
+	})
+}
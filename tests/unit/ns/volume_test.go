@@ -0,0 +1,334 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/ns"
+)
+
+func TestProvider_ResizeVolume(t *testing.T) {
+	const blockSize = 8192
+	const size = 100 * blockSize
+
+	newFakeVolumeNef := func() *fakeNef {
+		return &fakeNef{
+			filesystems: map[string][]ns.Filesystem{
+				"": {{Path: "p", BytesAvailable: 10 * size}},
+			},
+			volumes: map[string][]ns.Volume{
+				"p/vg": {
+					{Path: "p/vg/thin", VolumeSize: size, VolumeBlockSize: blockSize},
+					{Path: "p/vg/thick", VolumeSize: size, VolumeBlockSize: blockSize, ReferencedReservationSize: size},
+				},
+			},
+		}
+	}
+
+	t.Run("ResizeVolume() should round size up to the volume block size", func(t *testing.T) {
+		client := newFakeVolumeNef()
+		result, err := newFakeProvider(client).ResizeVolume("p/vg/thin", ns.ResizeVolumeParams{VolumeSize: size + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.OldSize != size || result.NewSize != size+blockSize {
+			t.Errorf("expected resize from %d to %d, but got: %+v", size, size+blockSize, result)
+		}
+		payload := ns.UpdateVolumeParams{}
+		if err := json.Unmarshal(client.payloads["PUT storage/volumes/p%2Fvg%2Fthin"], &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.VolumeSize != size+blockSize || payload.ReferencedReservationSize != nil {
+			t.Errorf("unexpected update request: %+v", payload)
+		}
+	})
+
+	t.Run("ResizeVolume() should refuse to shrink volume", func(t *testing.T) {
+		client := newFakeVolumeNef()
+		_, err := newFakeProvider(client).ResizeVolume("p/vg/thin", ns.ResizeVolumeParams{VolumeSize: size / 2})
+		if err == nil {
+			t.Error("expected an error, but got nil")
+		}
+		if _, ok := client.payloads["PUT storage/volumes/p%2Fvg%2Fthin"]; ok {
+			t.Error("volume should not be updated")
+		}
+	})
+
+	t.Run("ResizeVolume() should check pool space for thick volume", func(t *testing.T) {
+		client := newFakeVolumeNef()
+		_, err := newFakeProvider(client).ResizeVolume("p/vg/thick", ns.ResizeVolumeParams{VolumeSize: 20 * size})
+		if err == nil {
+			t.Error("expected an error, but got nil")
+		}
+
+		result, err := newFakeProvider(client).ResizeVolume("p/vg/thick", ns.ResizeVolumeParams{VolumeSize: 2 * size})
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := ns.UpdateVolumeParams{}
+		if err := json.Unmarshal(client.payloads["PUT storage/volumes/p%2Fvg%2Fthick"], &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.ReferencedReservationSize == nil || *payload.ReferencedReservationSize != result.NewSize {
+			t.Errorf("reservation of thick volume should be updated to %d, got: %+v", result.NewSize, payload)
+		}
+	})
+}
+
+func TestVolume_JSON(t *testing.T) {
+	data := `{
+		"path": "p/vg/clone",
+		"bytesAvailable": 2048,
+		"bytesUsed": 1024,
+		"volumeSize": 8388608,
+		"volumeBlockSize": 8192,
+		"compressionMode": "lz4",
+		"dedupMode": "off",
+		"syncMode": "always",
+		"writebackCacheDisabled": true,
+		"referencedReservationSize": 8388608,
+		"creationTime": "2019-05-20T10:50:31.000Z",
+		"origin": "p/vg/v@snap"
+	}`
+
+	volume := ns.Volume{}
+	if err := json.Unmarshal([]byte(data), &volume); err != nil {
+		t.Fatal(err)
+	}
+	expected := ns.Volume{
+		Path:                      "p/vg/clone",
+		BytesAvailable:            2048,
+		BytesUsed:                 1024,
+		VolumeSize:                8388608,
+		VolumeBlockSize:           8192,
+		CompressionMode:           "lz4",
+		DedupMode:                 "off",
+		SyncMode:                  "always",
+		WritebackCacheDisabled:    true,
+		ReferencedReservationSize: 8388608,
+		CreationTime:              time.Date(2019, 5, 20, 10, 50, 31, 0, time.UTC),
+		Origin:                    "p/vg/v@snap",
+	}
+	if !reflect.DeepEqual(volume, expected) {
+		t.Errorf("expected decoded volume %+v, but got %+v", expected, volume)
+	}
+
+	encoded, err := json.Marshal(volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ns.Volume{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, volume) {
+		t.Errorf("expected round-trip volume %+v, but got %+v", volume, decoded)
+	}
+
+	if volume.String() != "p/vg/clone" {
+		t.Errorf("expected volume string to be its path, but got '%s'", volume.String())
+	}
+}
+
+func TestVolume_IsSparse(t *testing.T) {
+	if volume := (ns.Volume{VolumeSize: 1024}); !volume.IsSparse() {
+		t.Error("volume without reservation should be sparse")
+	}
+	if volume := (ns.Volume{VolumeSize: 1024, ReferencedReservationSize: 1024}); volume.IsSparse() {
+		t.Error("volume with reservation should not be sparse")
+	}
+}
+
+func TestProvider_CreateVolume(t *testing.T) {
+	t.Run("CreateVolume() should refuse reservation for sparse volume", func(t *testing.T) {
+		client := &fakeNef{}
+		reservation := int64(1024)
+		_, err := newFakeProvider(client).CreateVolume(ns.CreateVolumeParams{
+			Path:                      "p/vg/v",
+			VolumeSize:                1024,
+			SparseVolume:              true,
+			ReferencedReservationSize: &reservation,
+		})
+		if !ns.IsBadArgNefError(err) {
+			t.Errorf("expected EBADARG error, but got: %v", err)
+		}
+		if len(client.payloads) != 0 {
+			t.Errorf("volume should not be created, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("CreateVolume() should return created volume", func(t *testing.T) {
+		client := &fakeNef{volumes: map[string][]ns.Volume{"p/vg": {{Path: "p/vg/v", VolumeSize: 1024}}}}
+		reservation := int64(0)
+		volume, err := newFakeProvider(client).CreateVolume(ns.CreateVolumeParams{
+			Path:                      "p/vg/v",
+			VolumeSize:                1024,
+			SparseVolume:              true,
+			ReferencedReservationSize: &reservation,
+		})
+		if err != nil {
+			t.Fatal(err)
+		} else if volume.Path != "p/vg/v" || volume.VolumeSize != 1024 {
+			t.Errorf("unexpected created volume: %+v", volume)
+		}
+		expected := `{"path":"p/vg/v","volumeSize":1024,"sparseVolume":true,"referencedReservationSize":0}`
+		if payload := string(client.payloads["POST storage/volumes"]); payload != expected {
+			t.Errorf("expected create request %s, but got %s", expected, payload)
+		}
+	})
+}
+
+func TestProvider_CloneVolumeSnapshot(t *testing.T) {
+	const snapshotSize = 1024 * 1024
+	const clonePayloadKey = "PUT storage/volumes/p%2Fvg2%2Fclone"
+
+	// source volume has been grown after the snapshot, the clone gets the snapshot volume size
+	newFakeCloneNef := func() *fakeNef {
+		return &fakeNef{
+			volumes: map[string][]ns.Volume{
+				"p/vg":  {{Path: "p/vg/v", VolumeSize: 2 * snapshotSize}},
+				"p/vg2": {{Path: "p/vg2/clone", VolumeSize: snapshotSize, Origin: "p/vg/v@s"}},
+			},
+			responses: map[string][]fakeResponse{
+				"GET storage/snapshots/p%2Fvg%2Fv@s?fields=volumeSize": {
+					{http.StatusOK, map[string]int64{"volumeSize": snapshotSize}},
+				},
+			},
+		}
+	}
+	params := ns.CloneVolumeSnapshotParams{VolumeGroup: "p/vg2", Name: "clone"}
+
+	t.Run("CloneVolumeSnapshot() should compare requested size with snapshot volume size", func(t *testing.T) {
+		client := newFakeCloneNef()
+		sizeParams := params
+		sizeParams.VolumeSize = snapshotSize * 3 / 2
+		result, err := newFakeProvider(client).CloneVolumeSnapshot("p/vg/v@s", sizeParams)
+		if err != nil {
+			t.Fatal(err)
+		} else if result.Type != ns.DatasetTypeVolume || result.Volume == nil {
+			t.Fatalf("expected volume clone, but got: %+v", result)
+		}
+		payload := ns.UpdateVolumeParams{}
+		if err := json.Unmarshal(client.payloads[clonePayloadKey], &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.VolumeSize != sizeParams.VolumeSize ||
+			payload.ReferencedReservationSize == nil || *payload.ReferencedReservationSize != sizeParams.VolumeSize {
+			t.Errorf("expected clone to be grown and reserved to %d, but got: %s", sizeParams.VolumeSize,
+				client.payloads[clonePayloadKey])
+		}
+	})
+
+	t.Run("CloneVolumeSnapshot() should reserve snapshot volume size by default", func(t *testing.T) {
+		client := newFakeCloneNef()
+		if _, err := newFakeProvider(client).CloneVolumeSnapshot("p/vg/v@s", params); err != nil {
+			t.Fatal(err)
+		}
+		if payload := string(client.payloads[clonePayloadKey]); payload != `{"referencedReservationSize":1048576}` {
+			t.Errorf("expected reservation of snapshot volume size only, but got: %s", payload)
+		}
+	})
+
+	t.Run("CloneVolumeSnapshot() should reject size less than snapshot one before cloning", func(t *testing.T) {
+		client := newFakeCloneNef()
+		sizeParams := params
+		sizeParams.VolumeSize = snapshotSize / 2
+		_, err := newFakeProvider(client).CloneVolumeSnapshot("p/vg/v@s", sizeParams)
+		if !ns.IsBadArgNefError(err) {
+			t.Fatalf("expected EBADARG error, but got: %v", err)
+		}
+		for _, request := range client.requests {
+			if !strings.HasPrefix(request, "GET ") {
+				t.Errorf("snapshot should not be cloned, but sent: %s", request)
+			}
+		}
+	})
+
+	t.Run("CloneVolumeSnapshot() should destroy the clone if it cannot be updated", func(t *testing.T) {
+		client := newFakeCloneNef()
+		client.failed = map[string]bool{clonePayloadKey: true}
+		result, err := newFakeProvider(client).CloneVolumeSnapshot("p/vg/v@s", params)
+		if err == nil {
+			t.Fatal("expected update error, but got nil")
+		} else if result != (ns.CloneSnapshotResult{}) {
+			t.Errorf("expected empty result, but got: %+v", result)
+		}
+		if _, ok := client.payloads["DELETE storage/volumes/p%2Fvg2%2Fclone"]; !ok {
+			t.Errorf("expected clone to be destroyed, but sent: %v", client.requests)
+		}
+	})
+
+	t.Run("CloneVolumeSnapshot() should return clone path if failed clone cannot be destroyed", func(t *testing.T) {
+		client := newFakeCloneNef()
+		client.failed = map[string]bool{clonePayloadKey: true, "DELETE storage/volumes/p%2Fvg2%2Fclone": true}
+		result, err := newFakeProvider(client).CloneVolumeSnapshot("p/vg/v@s", params)
+		if err == nil {
+			t.Fatal("expected update error, but got nil")
+		} else if result.Path != "p/vg2/clone" {
+			t.Errorf("expected clone path in the result, but got: %+v", result)
+		}
+	})
+}
+
+func TestProvider_ResizeVolume_overcommit(t *testing.T) {
+	const size = 1024 * 1024
+
+	// pool capacity is 4 sizes, volumes provisioned: 1 size in "p/vg" and 3 sizes in nested "p/fs/vg"
+	newFakeOvercommitNef := func() *fakeNef {
+		return &fakeNef{
+			filesystems: map[string][]ns.Filesystem{
+				"":  {{Path: "p", BytesAvailable: 4 * size}},
+				"p": {{Path: "p/fs"}},
+			},
+			volumeGroups: map[string][]ns.VolumeGroup{
+				"p":    {{Path: "p/vg"}},
+				"p/fs": {{Path: "p/fs/vg"}},
+			},
+			volumes: map[string][]ns.Volume{
+				"p/vg":    {{Path: "p/vg/thin", VolumeSize: size}},
+				"p/fs/vg": {{Path: "p/fs/vg/big", VolumeSize: 3 * size}},
+			},
+		}
+	}
+
+	t.Run("ResizeVolume() should count volumes of nested volumeGroups", func(t *testing.T) {
+		client := newFakeOvercommitNef()
+		_, err := newFakeProvider(client).ResizeVolume("p/vg/thin", ns.ResizeVolumeParams{
+			VolumeSize:         2 * size,
+			MaxOvercommitRatio: 1,
+		})
+		if err == nil {
+			t.Fatal("expected overcommit error, but got nil")
+		}
+		if _, ok := client.payloads["PUT storage/volumes/p%2Fvg%2Fthin"]; ok {
+			t.Error("volume should not be resized")
+		}
+	})
+
+	t.Run("ResizeVolume() should allow overcommit within the ratio", func(t *testing.T) {
+		client := newFakeOvercommitNef()
+		_, err := newFakeProvider(client).ResizeVolume("p/vg/thin", ns.ResizeVolumeParams{
+			VolumeSize:         2 * size,
+			MaxOvercommitRatio: 1.25,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ResizeVolume() should not check overcommit if ratio is not set", func(t *testing.T) {
+		client := newFakeOvercommitNef()
+		if _, err := newFakeProvider(client).ResizeVolume("p/vg/thin", ns.ResizeVolumeParams{VolumeSize: 10 * size}); err != nil {
+			t.Fatal(err)
+		}
+		for _, request := range client.requests {
+			if strings.Contains(request, "storage/volumeGroups") {
+				t.Errorf("volumeGroups should not be requested, but sent: %s", request)
+			}
+		}
+	})
+}
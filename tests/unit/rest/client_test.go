@@ -3,7 +3,7 @@ package rest_test
 import (
 	"fmt"
 
-	"github.com/Nexenta/go-nexentastor/pkg/rest"
+	"go-nexentastor/pkg/rest"
 )
 
 func ExampleClient_BuildURI() {
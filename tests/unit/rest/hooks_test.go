@@ -0,0 +1,89 @@
+package rest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-nexentastor/pkg/logger"
+	"go-nexentastor/pkg/rest"
+)
+
+func ExampleDefaultPathTemplate() {
+	fmt.Println(rest.DefaultPathTemplate("storage/filesystems/pool%2Ffs?fields=path"))
+	fmt.Println(rest.DefaultPathTemplate("jobStatus/12"))
+
+	// Output:
+	// storage/filesystems/{id}
+	// jobStatus/{id}
+}
+
+type recordingSpan struct {
+	hooks *recordingHooks
+}
+
+func (s recordingSpan) End(statusCode int, err error) {
+	s.hooks.mux.Lock()
+	defer s.hooks.mux.Unlock()
+	s.hooks.spanStatusCodes = append(s.hooks.spanStatusCodes, statusCode)
+}
+
+type recordingHooks struct {
+	mux             sync.Mutex
+	started         []rest.RequestInfo
+	observed        []rest.RequestInfo
+	spanStatusCodes []int
+}
+
+func (h *recordingHooks) StartSpan(info rest.RequestInfo) rest.Span {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.started = append(h.started, info)
+	return recordingSpan{h}
+}
+
+func (h *recordingHooks) ObserveRequest(info rest.RequestInfo, statusCode int, duration time.Duration, err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.observed = append(h.observed, info)
+}
+
+func TestClient_Send_hooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	hooks := &recordingHooks{}
+	client := rest.NewClient(rest.ClientArgs{
+		Address: server.URL,
+		Log:     logger.NewNoopLogger(),
+		Hooks:   hooks,
+	})
+
+	statusCode, _, err := client.Send(http.MethodGet, "storage/filesystems/pool%2Ffs?fields=path", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if statusCode != http.StatusNotFound {
+		t.Fatalf("expected %d status code, but got %d", http.StatusNotFound, statusCode)
+	}
+
+	expected := rest.RequestInfo{
+		ID:           1,
+		Method:       http.MethodGet,
+		Path:         "storage/filesystems/pool%2Ffs?fields=path",
+		PathTemplate: "storage/filesystems/{id}",
+	}
+	if len(hooks.started) != 1 || hooks.started[0] != expected {
+		t.Errorf("expected span to be started for %+v, but got: %+v", expected, hooks.started)
+	}
+	if len(hooks.observed) != 1 || hooks.observed[0] != expected {
+		t.Errorf("expected request %+v to be observed, but got: %+v", expected, hooks.observed)
+	}
+	if len(hooks.spanStatusCodes) != 1 || hooks.spanStatusCodes[0] != http.StatusNotFound {
+		t.Errorf("expected span to be ended with %d, but got: %v", http.StatusNotFound, hooks.spanStatusCodes)
+	}
+}
@@ -0,0 +1,276 @@
+package state_test
+
+import (
+	"fmt"
+	"testing"
+
+	"go-nexentastor/pkg/ns"
+	"go-nexentastor/pkg/state"
+)
+
+// fakeProvider - read-only provider, any change request panics on nil embedded interface
+type fakeProvider struct {
+	ns.ProviderInterface
+
+	filesystems  map[string][]ns.Filesystem
+	volumeGroups map[string][]ns.VolumeGroup
+	volumes      map[string][]ns.Volume
+	hostGroups   map[string]ns.HostGroup
+	lunMappings  []ns.LunMapping
+}
+
+func notFound(kind, name string) error {
+	return &ns.NefError{Code: "ENOENT", Err: fmt.Errorf("%s '%s' not found", kind, name)}
+}
+
+func (f *fakeProvider) GetFilesystem(path string) (ns.Filesystem, error) {
+	for _, list := range f.filesystems {
+		for _, filesystem := range list {
+			if filesystem.Path == path {
+				return filesystem, nil
+			}
+		}
+	}
+	return ns.Filesystem{}, notFound("Filesystem", path)
+}
+
+func (f *fakeProvider) GetFilesystems(parent string) ([]ns.Filesystem, error) {
+	return f.filesystems[parent], nil
+}
+
+func (f *fakeProvider) GetVolumeGroups(parent string) ([]ns.VolumeGroup, error) {
+	return f.volumeGroups[parent], nil
+}
+
+func (f *fakeProvider) GetVolumes(parent string) ([]ns.Volume, error) {
+	return f.volumes[parent], nil
+}
+
+func (f *fakeProvider) GetVolume(path string) (ns.Volume, error) {
+	for _, list := range f.volumes {
+		for _, volume := range list {
+			if volume.Path == path {
+				return volume, nil
+			}
+		}
+	}
+	return ns.Volume{}, notFound("Volume", path)
+}
+
+func (f *fakeProvider) GetNfsShare(path string) (ns.NfsShare, error) {
+	return ns.NfsShare{
+		Filesystem:    path,
+		ReadWriteList: []ns.NfsRuleList{{Etype: "fqdn", Entity: "*"}},
+		ReadOnlyList:  []ns.NfsRuleList{{Etype: "fqdn", Entity: "none"}},
+	}, nil
+}
+
+func (f *fakeProvider) GetHostGroup(name string) (ns.HostGroup, error) {
+	hostGroup, ok := f.hostGroups[name]
+	if !ok {
+		return hostGroup, notFound("HostGroup", name)
+	}
+	return hostGroup, nil
+}
+
+func (f *fakeProvider) GetTargetGroup(name string) (ns.TargetGroup, error) {
+	return ns.TargetGroup{}, notFound("TargetGroup", name)
+}
+
+func (f *fakeProvider) GetLunMappings(params ns.GetLunMappingsParams) ([]ns.LunMapping, error) {
+	lunMappings := []ns.LunMapping{}
+	for _, lunMapping := range f.lunMappings {
+		if (params.Volume == "" || params.Volume == lunMapping.Volume) &&
+			(params.HostGroup == "" || params.HostGroup == lunMapping.HostGroup) &&
+			(params.TargetGroup == "" || params.TargetGroup == lunMapping.TargetGroup) {
+			lunMappings = append(lunMappings, lunMapping)
+		}
+	}
+	return lunMappings, nil
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{
+		filesystems: map[string][]ns.Filesystem{
+			"":    {{Path: "p"}},
+			"p":   {{Path: "p/t"}},
+			"p/t": {{Path: "p/t/data", ReferencedQuotaSize: 1024, SharedOverNfs: true}, {Path: "p/t/old"}},
+		},
+		volumeGroups: map[string][]ns.VolumeGroup{
+			"p/t": {{Path: "p/t/vg"}},
+		},
+		volumes: map[string][]ns.Volume{
+			"p/t/vg": {
+				{Path: "p/t/vg/db", VolumeSize: 8192, VolumeBlockSize: 8192},
+				{Path: "p/t/vg/tmp", VolumeSize: 8192, VolumeBlockSize: 8192},
+			},
+		},
+		hostGroups: map[string]ns.HostGroup{
+			"hosts": {Name: "hosts", Members: []string{"iqn.a", "iqn.old"}},
+		},
+		lunMappings: []ns.LunMapping{
+			{Id: "m1", Volume: "p/t/vg/db", HostGroup: "hosts", TargetGroup: "targets", Lun: 0},
+			{Id: "m2", Volume: "p/t/vg/db", HostGroup: "legacy", TargetGroup: "targets", Lun: 1},
+		},
+	}
+}
+
+const testState = `
+roots: [p/t]
+filesystems:
+  - path: p/t
+  - path: p/t/data
+    referencedQuotaSize: 2048
+    nfs: {}
+  - path: p/t/new
+    smb:
+      shareName: new
+volumes:
+  - path: p/t/vg/db
+    volumeSize: 16384
+hostGroups:
+  - name: hosts
+    members: [iqn.a, iqn.b]
+targetGroups:
+  - name: targets
+    members: [iqn.target]
+lunMappings:
+  - volume: p/t/vg/db
+    hostGroup: hosts
+    targetGroup: targets
+    lun: 0
+`
+
+func TestParse(t *testing.T) {
+	t.Run("Parse() should load YAML state", func(t *testing.T) {
+		s, err := state.Parse([]byte(testState), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Filesystems) != 3 || s.Filesystems[1].Nfs == nil || *s.Filesystems[1].ReferencedQuotaSize != 2048 {
+			t.Errorf("unexpected filesystems: %+v", s.Filesystems)
+		}
+		if len(s.LunMappings) != 1 || s.LunMappings[0].Lun == nil || *s.LunMappings[0].Lun != 0 {
+			t.Errorf("unexpected LUN mappings: %+v", s.LunMappings)
+		}
+	})
+
+	t.Run("Parse() should load JSON state", func(t *testing.T) {
+		s, err := state.Parse([]byte(`{"volumes": [{"path": "p/vg/v", "volumeSize": 1024}]}`), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Volumes) != 1 || s.Volumes[0].VolumeSize != 1024 {
+			t.Errorf("unexpected volumes: %+v", s.Volumes)
+		}
+	})
+
+	t.Run("Parse() should reject unknown fields and duplicates", func(t *testing.T) {
+		if _, err := state.Parse([]byte("filesystems: [{path: p/a, quota: 1}]"), false); err == nil {
+			t.Error("expected an error for unknown field, but got nil")
+		}
+		if _, err := state.Parse([]byte("filesystems: [{path: p/a}, {path: p/a}]"), false); err == nil {
+			t.Error("expected an error for duplicate filesystem, but got nil")
+		}
+	})
+}
+
+func TestNewPlan(t *testing.T) {
+	s, err := state.Parse([]byte(testState), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("NewPlan() should plan creates and updates in dependency order", func(t *testing.T) {
+		plan, err := state.NewPlan(newFakeProvider(), s, state.PlanParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"update filesystem 'p/t/data' (referencedQuotaSize: 1024 -> 2048)",
+			"create filesystem 'p/t/new'",
+			"create SMB share 'p/t/new' (shareName: new)",
+			"update volume 'p/t/vg/db' (volumeSize: 8192 -> 16384)",
+			"update hostGroup 'hosts' (members: [iqn.a iqn.old] -> [iqn.a iqn.b iqn.old])",
+			"create targetGroup 'targets' (members: [iqn.target])",
+		}
+		assertPlan(t, plan, expected)
+	})
+
+	t.Run("NewPlan() should plan deletes in prune mode", func(t *testing.T) {
+		plan, err := state.NewPlan(newFakeProvider(), s, state.PlanParams{Prune: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"update filesystem 'p/t/data' (referencedQuotaSize: 1024 -> 2048)",
+			"create filesystem 'p/t/new'",
+			"create SMB share 'p/t/new' (shareName: new)",
+			"update volume 'p/t/vg/db' (volumeSize: 8192 -> 16384)",
+			"update hostGroup 'hosts' (members: [iqn.a iqn.old] -> [iqn.a iqn.b])",
+			"create targetGroup 'targets' (members: [iqn.target])",
+			"delete LUN mapping 'p/t/vg/db:legacy:targets'",
+			"delete filesystem 'p/t/old'",
+			"delete volume 'p/t/vg/tmp'",
+			"delete hostGroup 'legacy'",
+		}
+		assertPlan(t, plan, expected)
+	})
+
+	t.Run("NewPlan() should prune groups of destroyed datasets and empty volumeGroups", func(t *testing.T) {
+		p := newFakeProvider()
+		p.volumeGroups["p/t"] = append(p.volumeGroups["p/t"], ns.VolumeGroup{Path: "p/t/empty"})
+		p.volumeGroups["p/t/old"] = []ns.VolumeGroup{{Path: "p/t/old/vg"}}
+		p.volumes["p/t/old/vg"] = []ns.Volume{{Path: "p/t/old/vg/v"}}
+		p.lunMappings = append(p.lunMappings,
+			ns.LunMapping{Id: "m3", Volume: "p/t/vg/tmp", HostGroup: "tmpHosts", TargetGroup: "tmpTargets"},
+			ns.LunMapping{Id: "m4", Volume: "p/t/old/vg/v", HostGroup: "oldHosts", TargetGroup: "targets"},
+			// hostGroup used by a volume outside of the roots is kept
+			ns.LunMapping{Id: "m5", Volume: "p/t/old/vg/v", HostGroup: "shared", TargetGroup: "targets"},
+			ns.LunMapping{Id: "m6", Volume: "p/other/vg/v", HostGroup: "shared", TargetGroup: "targets"},
+		)
+		plan, err := state.NewPlan(p, s, state.PlanParams{Prune: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"update filesystem 'p/t/data' (referencedQuotaSize: 1024 -> 2048)",
+			"create filesystem 'p/t/new'",
+			"create SMB share 'p/t/new' (shareName: new)",
+			"update volume 'p/t/vg/db' (volumeSize: 8192 -> 16384)",
+			"update hostGroup 'hosts' (members: [iqn.a iqn.old] -> [iqn.a iqn.b])",
+			"create targetGroup 'targets' (members: [iqn.target])",
+			"delete LUN mapping 'p/t/vg/db:legacy:targets'",
+			"delete filesystem 'p/t/old'",
+			"delete volume 'p/t/vg/tmp'",
+			"delete volumeGroup 'p/t/empty'",
+			"delete hostGroup 'legacy'",
+			"delete hostGroup 'oldHosts'",
+			"delete hostGroup 'tmpHosts'",
+			"delete targetGroup 'tmpTargets'",
+		}
+		assertPlan(t, plan, expected)
+	})
+
+	t.Run("Apply() should not change anything in dry run mode", func(t *testing.T) {
+		result, err := state.Apply(newFakeProvider(), s, state.ApplyParams{DryRun: true, Prune: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Done) != 0 || result.Plan.IsEmpty() {
+			t.Errorf("expected non-empty plan and no applied changes, but got: %+v", result)
+		}
+	})
+}
+
+func assertPlan(t *testing.T, plan state.Plan, expected []string) {
+	t.Helper()
+	if len(plan.Changes) != len(expected) {
+		t.Fatalf("expected plan:\n%v\nbut got:\n%s", expected, plan)
+	}
+	for i, change := range plan.Changes {
+		if change.String() != expected[i] {
+			t.Errorf("step %d: expected '%s', but got '%s'", i, expected[i], change)
+		}
+	}
+}